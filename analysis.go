@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// RequiredBankroll replays fills through the sizer with capital limits
+// disabled and returns the peak capital that following them at the given
+// leverage and base notional would have required without rejections
+func RequiredBankroll(fills []*Fill, leverage, baseNotional float64) float64 {
+	pt := NewPaperTrader(0, leverage, baseNotional)
+	pt.VolumeThreshold = 0 // copy every fill, no storage
+	pt.DisableLimits = true
+
+	peak := 0.0
+	for _, fill := range fills {
+		if err := fill.Normalize(); err != nil {
+			continue
+		}
+		pt.ProcessFill(fill)
+
+		pt.mu.Lock()
+		exposure := 0.0
+		for _, pos := range pt.Positions {
+			exposure += math.Abs(pos.Size * pos.LastPrice)
+		}
+		// Capital must cover exposure at leverage after absorbing PnL so far
+		required := exposure/leverage - pt.equity()
+		pt.mu.Unlock()
+
+		peak = math.Max(peak, required)
+	}
+
+	return peak
+}
+
+// runBankrollAnalysis prints the bankroll required to follow saved fills
+func runBankrollAnalysis(config *Config, files []string) error {
+	var fills []*Fill
+	for _, filename := range files {
+		records, err := LoadFills(filename)
+		if err != nil {
+			return fmt.Errorf("failed to load fills from %s: %v", filename, err)
+		}
+		for i := range records {
+			fills = append(fills, records[i].Fill())
+		}
+	}
+
+	limits := config.Limits()
+	required := RequiredBankroll(fills, limits.Leverage, limits.BaseNotional)
+	fmt.Printf("fills: %d\n", len(fills))
+	fmt.Printf("leverage: %.2fx\n", limits.Leverage)
+	fmt.Printf("base notional: $%.2f\n", limits.BaseNotional)
+	fmt.Printf("required bankroll: $%.2f\n", required)
+	return nil
+}
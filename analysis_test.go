@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequiredBankroll(t *testing.T) {
+	// Saved fills file in the fills/*.jl format
+	content := `{"action":"OPEN","coin":"BTC","price":50000,"realized_pnl":0,"side":"B","size":1,"time":1758113800000,"unrealized_pnl":0,"volume_usd":50000}
+{"action":"OPEN","coin":"ETH","price":4000,"realized_pnl":0,"side":"B","size":10,"time":1758113801000,"unrealized_pnl":0,"volume_usd":40000}
+{"action":"ADD","coin":"ETH","price":2000,"realized_pnl":0,"side":"B","size":5,"time":1758113802000,"unrealized_pnl":0,"volume_usd":10000}
+not json
+{"action":"CLOSE","coin":"BTC","price":50000,"realized_pnl":0,"side":"A","size":1,"time":1758113803000,"unrealized_pnl":0,"volume_usd":50000}
+`
+	filename := filepath.Join(t.TempDir(), "20250917.jl")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write fills: %v", err)
+	}
+
+	records, err := LoadFills(filename)
+	if err != nil {
+		t.Fatalf("LoadFills() error = %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Loaded %d records, want 4", len(records))
+	}
+
+	var fills []*Fill
+	for i := range records {
+		fills = append(fills, records[i].Fill())
+	}
+
+	// At 2x and $1000 per copy:
+	//   BTC open:        exposure 1000                     -> 500
+	//   ETH open:        exposure 2000                     -> 1000
+	//   ETH add at half: ETH 0.25@2000 + 0.5@2000 = 1500,
+	//                    BTC 1000, unrealized -500         -> 1250 + 500 = 1750
+	//   BTC close:       exposure 1500, unrealized -500    -> 1250
+	required := RequiredBankroll(fills, 2.0, 1000.0)
+	if math.Abs(required-1750.0) > 0.01 {
+		t.Errorf("Required bankroll = %.2f, want 1750.00", required)
+	}
+}
@@ -1,12 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultQueueSize    = 256
+	defaultPollInterval = 5 * time.Second
+	maxMonitorRestarts  = 10 // monitor loop relaunches after panics
+
+	fillLookback           = time.Hour            // how far back each poll fetches fills
+	processedFillRetention = 2 * fillLookback     // default processed-fill TTL
+	minProcessedFillTTL    = fillLookback * 3 / 2 // room past the lookback for server clock skew
+	defaultWinRateTrades   = 20                   // closing fills in the win-rate window
+)
+
 type Bot struct {
 	config         *Config
 	client         *Client
@@ -16,29 +35,196 @@ type Bot struct {
 	lastFillHash   string
 	processedFills map[string]int64 // hash -> timestamp for LRU cleanup
 	paperTrader    *PaperTrader
+	clock          Clock
+	rand           *rand.Rand
+	orders         *CopyOrderTracker   // real mode only
+	fingerprints   map[string]int64    // coin/side/price/size -> window start
+	targetPnL      map[string]pnlPoint // hash -> target's closed PnL within lookback
+	targetResults  map[string]pnlPoint // hash -> target's closing fills, for win rate
+	targetLeverage map[string]float64
+	leverageRead   time.Time             // when checkTargetLeverage last ran from a poll
+	fillData       map[string]fillDigest // hash -> fields first polled, to catch altered repeats
+	target         string                // account copied, config.TargetAccount until switched
+	targetPosition map[string]float64    // target's position per coin from its fills
+	alerter        *tradeAlerter
+	publisher      *tradePublisher
+
+	mu           sync.Mutex      // guards processedFills, fingerprints, target state, queued, near misses
+	fillQueue    chan *Fill      // poller -> processor
+	queued       map[string]bool // hashes waiting in fillQueue
+	queueDropped int64           // fills dropped by drop_oldest backpressure
+	queueBlocked int64           // enqueues that had to wait on a full queue
+	processor    func(*Fill) error
+
+	httpServer *http.Server
+
+	pollInterval  time.Duration // monitor tick, defaultPollInterval when 0
+	poll          func()        // one monitor tick, pollOnce by default
+	monitorPanics int64         // monitor loop panics recovered
+	pollOK        atomic.Bool   // the latest fill poll succeeded, for /readyz
+	paused        atomic.Bool   // fills are deduped but not copied, via /pause
+
+	clientMu sync.RWMutex // guards client across credential rotation
+
+	shadow        *ShadowTracker // real mode only
+	observer      *TargetTracker // observe mode only
+	ownFillsSince int64          // next own fill time to poll, millis
+
+	metrics        *metricsRegistry
+	processSeconds *histogram // per queued fill
+	latencySeconds *histogram // target fill time to processing
+
+	nearMisses       int // fills just below the copy threshold
+	nearMissNotional float64
 }
 
-func NewBot(config *Config) (*Bot, error) {
+func NewBot(config *Config, opts ...SimOptions) (*Bot, error) {
 	client, err := NewClient(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Bot{
+	sim := resolveSimOptions(opts)
+	limits := config.Limits()
+	bot := &Bot{
 		config:         config,
 		client:         client,
 		stopChan:       make(chan struct{}),
 		processedFills: make(map[string]int64),
-		paperTrader:    NewPaperTrader(config.Bankroll, config.Leverage, config.BaseNotional),
-	}, nil
+		paperTrader:    NewPaperTrader(limits.Bankroll, limits.Leverage, limits.BaseNotional, sim),
+		clock:          sim.Clock,
+		rand:           sim.Rand,
+	}
+	bot.paperTrader.CostBasis = config.Trading.CostBasis
+	bot.paperTrader.SubAccount = config.SubAccount
+	bot.paperTrader.MirrorTargetLev = config.Trading.MirrorTargetLeverage
+	bot.paperTrader.ImpactCoefficient = config.Trading.ImpactCoefficient
+	bot.paperTrader.SlippageBps = config.Trading.SlippageBps
+	bot.paperTrader.QuoteRate = config.Trading.QuoteRate
+	bot.paperTrader.QuoteCurrency = config.Trading.QuoteCurrency
+	bot.paperTrader.FullSnapshotEvery = config.Storage.FullSnapshotEvery
+	bot.paperTrader.StorageDecimals = config.Storage.Decimals
+	bot.paperTrader.MaxReversalsPerMin = config.Trading.MaxReversalsPerMinute
+	bot.paperTrader.SizeDecimals = config.Trading.SizeDecimals
+	bot.paperTrader.SizeRounding = config.Trading.SizeRounding
+	bot.paperTrader.SizeEpsilon = config.Trading.SizeEpsilon
+	bot.paperTrader.MinSizes = config.Trading.MinSizes
+	bot.paperTrader.MaxHold = time.Duration(config.Trading.MaxHoldSeconds) * time.Second
+	bot.paperTrader.StopLossPct = config.Trading.StopLossPct
+	bot.paperTrader.TakeProfitPct = config.Trading.TakeProfitPct
+	bot.paperTrader.TakeProfitLadder = config.Trading.TakeProfitLadder
+	bot.paperTrader.OnCapacityExhaust = config.Trading.OnCapacityExhausted
+	bot.paperTrader.TWAPSlices = config.Trading.TWAPSlices
+	bot.paperTrader.TWAPWindow = time.Duration(config.Trading.TWAPWindowSeconds) * time.Second
+	bot.paperTrader.FeeWarnPercent = config.Trading.FeeWarnPercent
+	bot.paperTrader.CompoundFraction = config.Trading.CompoundRealizedFraction
+	bot.paperTrader.FormHalfLife = time.Duration(config.Trading.PerformanceHalfLifeMinutes) * time.Minute
+	bot.paperTrader.FormWeight = config.Trading.PerformanceWeight
+	bot.paperTrader.TakerFeeBps = config.Trading.TakerFeeBps
+	bot.paperTrader.MakerFeeBps = config.Trading.MakerFeeBps
+	bot.paperTrader.CoinAliases = config.Trading.CoinAliases
+	bot.paperTrader.HedgeGroups = config.Trading.HedgeGroups
+	bot.paperTrader.PositionSort = config.Monitoring.PositionSort
+	bot.paperTrader.AggregateByOrder = config.Trading.AggregateByOrder
+	bot.paperTrader.FlushOnSideChange = config.Trading.FlushOnSideChange
+	bot.paperTrader.MaxPendingFills = config.Trading.MaxPendingFills
+	bot.paperTrader.EvictFlatAfter = time.Duration(config.Trading.EvictFlatAfterSeconds) * time.Second
+	bot.paperTrader.PerCoinBankroll = config.Trading.PerCoinBankroll
+	bot.paperTrader.CoinBankrolls = config.Trading.CoinBankrolls
+	bot.paperTrader.BankrollCoins = config.Trading.Coins
+	if config.Storage.PersistEquityPeak {
+		if peak, err := bot.paperTrader.RestoreEquityPeak(); err != nil {
+			log.Printf("Error restoring equity peak: %v", err)
+		} else if peak > 0 {
+			log.Printf("bot: restored equity peak $%.2f", peak)
+		}
+	}
+	bot.paperTrader.Tags = map[string]string{
+		"target": config.TargetAccount,
+		"mode":   copyMode(config),
+	}
+	if config.SubAccount != "" {
+		bot.paperTrader.Tags["sub_account"] = config.SubAccount
+		log.Printf("bot: copying into sub-account %s", config.SubAccount)
+	}
+	queueSize := config.Monitoring.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	bot.fillQueue = make(chan *Fill, queueSize)
+	bot.processor = bot.process
+	bot.poll = bot.pollOnce
+	bot.initMetrics()
+
+	if config.Monitoring.MaxMarkAgeSeconds > 0 {
+		bot.paperTrader.MaxMarkAge = time.Duration(config.Monitoring.MaxMarkAgeSeconds) * time.Second
+	}
+	if config.Mode == ModeObserve {
+		bot.observer = NewTargetTracker()
+	} else if !config.PaperTradingOnly {
+		bot.orders = NewCopyOrderTracker()
+		bot.paperTrader.QueueTWAPChildren = true
+		bot.shadow = NewShadowTracker()
+	}
+	if config.Notifications.WebhookURL != "" {
+		window := time.Duration(config.Notifications.BatchSeconds) * time.Second
+		bot.alerter = newTradeAlerter(newWebhookNotifier(config.Notifications.WebhookURL), window, bot.clock)
+		bot.paperTrader.OnTrade = bot.alerter.OnTrade
+		bot.paperTrader.OnAlert = bot.alerter.Alert
+	}
+	if config.Notifications.NATSURL != "" {
+		nats, err := newNATSPublisher(config.Notifications.NATSURL)
+		if err != nil {
+			return nil, err
+		}
+		bot.publisher = newTradePublisher(nats, config.Notifications.NATSSubject)
+		if prev := bot.paperTrader.OnTrade; prev != nil {
+			bot.paperTrader.OnTrade = func(trade *PaperTrade) {
+				prev(trade)
+				bot.publisher.OnTrade(trade)
+			}
+		} else {
+			bot.paperTrader.OnTrade = bot.publisher.OnTrade
+		}
+	}
+
+	return bot, nil
 }
 
 func (b *Bot) Start() error {
 	log.Println("bot: monitoring started")
 	b.running = true
 
-	b.wg.Add(1)
+	if b.config.Trading.BackfillPositions && b.observer == nil {
+		if err := b.backfillPositions(); err != nil {
+			log.Printf("Error backfilling target positions: %v", err)
+		}
+	}
+
+	b.wg.Add(2)
 	go b.monitorTrades()
+	go b.processFills()
+
+	if b.alerter != nil {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.alerter.run(b.stopChan)
+		}()
+	}
+	if b.publisher != nil {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.publisher.run(b.stopChan)
+		}()
+	}
+	if minutes := b.config.Trading.FundingIntervalMinutes; minutes > 0 && b.observer == nil {
+		b.wg.Add(1)
+		go b.runFunding(time.Duration(minutes) * time.Minute)
+	}
+
+	b.startHTTPServer()
 
 	return nil
 }
@@ -50,134 +236,881 @@ func (b *Bot) Stop() {
 
 	log.Println("bot: stopping")
 	b.running = false
+	b.stopHTTPServer()
 	close(b.stopChan)
 	b.wg.Wait()
-	b.client.Close()
+	b.api().Close()
+
+	if dropped, blocked := b.queueStats(); dropped > 0 || blocked > 0 {
+		log.Printf("bot: fill queue dropped %d, blocked %d", dropped, blocked)
+	}
 
 	// Show final paper trading summary
-	b.paperTrader.PrintPortfolioSummary()
+	b.printSummary()
 	b.paperTrader.PrintRecentTrades(10)
+	if b.config.Storage.PersistEquityPeak && b.observer == nil {
+		b.paperTrader.Checkpoint()
+	}
+
+	if format := b.config.Storage.WriteSessionReport; format != "" && b.observer == nil {
+		if path, err := b.paperTrader.WriteSessionReport(format); err != nil {
+			log.Printf("Error writing session report: %v", err)
+		} else {
+			log.Printf("bot: session report written to %s", path)
+		}
+	}
 }
 
 func (b *Bot) monitorTrades() {
 	defer b.wg.Done()
 
-	ticker := time.NewTicker(5 * time.Second) // Check every 5 seconds
-	defer ticker.Stop()
+	// Spread out instances started together so they don't poll in lockstep
+	if delay := b.startupDelay(); delay > 0 {
+		log.Printf("bot: delaying first poll by %v", delay)
+		select {
+		case <-b.stopChan:
+			return
+		case <-b.clock.After(delay):
+		}
+	}
+
+	log.Printf("bot: watching %s", b.targetAccount())
 
-	log.Printf("bot: watching %s", b.config.TargetAccount)
+	// A panic in one poll must not silently end following
+	for restarts := 0; b.runMonitorLoop(); restarts++ {
+		atomic.AddInt64(&b.monitorPanics, 1)
+		if restarts >= maxMonitorRestarts {
+			log.Printf("Error: monitor loop panicked %d times, no longer watching", restarts+1)
+			return
+		}
+		log.Printf("bot: restarting monitor loop (%d/%d)", restarts+1, maxMonitorRestarts)
+	}
+}
+
+// runMonitorLoop polls on every tick until stopped. It returns true if a
+// poll panicked.
+func (b *Bot) runMonitorLoop() (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: monitor loop panic: %v\n%s", r, debug.Stack())
+			panicked = true
+		}
+	}()
+
+	interval := b.pollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-b.stopChan:
-			return
+			return false
 		case <-ticker.C:
-			if err := b.checkTrades(); err != nil {
-				log.Printf("Error checking trades after retries: %v", err)
-				// Continue monitoring - API failures are expected and recoverable
-			}
+			b.poll()
+		}
+	}
+}
+
+// pollOnce is one monitor tick: fills, target leverage, then marks
+func (b *Bot) pollOnce() {
+	if err := b.checkTrades(); err != nil {
+		log.Printf("Error checking trades after retries: %v", err)
+		// Continue monitoring - API failures are expected and recoverable
+	}
+	if b.leverageDue() {
+		if err := b.checkTargetLeverage(); err != nil {
+			log.Printf("Error checking target leverage: %v", err)
+		}
+	}
+	b.refreshMarks()
+	if b.shadow != nil && b.config.AccountAddress != "" {
+		if err := b.checkOwnFills(); err != nil {
+			log.Printf("Error checking own fills: %v", err)
 		}
 	}
 }
 
+// copyMode names how positions are being copied, for position tags
+func copyMode(config *Config) string {
+	if config.Mode == ModeObserve {
+		return ModeObserve
+	}
+	if config.PaperTradingOnly {
+		return "paper"
+	}
+	return "live"
+}
+
+// startupDelay picks a random delay in [0, startup_jitter_ms)
+func (b *Bot) startupDelay() time.Duration {
+	jitter := b.config.Monitoring.StartupJitterMs
+	if jitter <= 0 || b.rand == nil {
+		return 0
+	}
+	return time.Duration(b.rand.Intn(jitter)) * time.Millisecond
+}
+
 func (b *Bot) checkForNewTrades() error {
-	// Get fills from last 1 hour only to avoid processing old data
-	endTime := time.Now().UnixMilli()
-	startTime := endTime - (60 * 60 * 1000) // 1 hour ago in milliseconds
+	return b.checkForNewTradesContext(context.Background())
+}
+
+func (b *Bot) checkForNewTradesContext(ctx context.Context) error {
+	// Get fills from the lookback window only to avoid processing old data
+	endTime := b.clock.Now().UnixMilli()
+	startTime := endTime - fillLookback.Milliseconds()
 
-	fills, err := b.client.GetUserFillsByTime(b.config.TargetAccount, startTime, endTime)
+	fills, err := b.api().GetUserFillsByTimeContext(ctx, b.targetAccount(), startTime, endTime)
+	b.pollOK.Store(err == nil)
 	if err != nil {
 		return err
 	}
 
-	// Clean up old processed fills (older than 2 hours)
-	b.cleanupProcessedFills(endTime - (2 * 60 * 60 * 1000))
+	// Forget processed fills only well outside the lookback, so a fill the
+	// API can still return is never cleaned and then reprocessed
+	b.cleanupProcessedFills(endTime - b.processedFillTTL().Milliseconds())
 
-	newFillsCount := 0
+	queuedCount, newCount := 0, 0
 	maxFillsPerCheck := 50 // Safety limit to prevent overloading
 
 	for _, fill := range fills {
-		// Safety limit check
-		if newFillsCount >= maxFillsPerCheck {
+		// Safety limit check, on fills new this poll only: ones polled
+		// before and queued again don't use it up
+		if newCount >= maxFillsPerCheck {
 			log.Printf("Reached maximum fills per check (%d), deferring remaining fills", maxFillsPerCheck)
 			break
 		}
 
-		// Skip if already processed (checked in process)
-		if _, exists := b.processedFills[fill.Hash]; exists {
+		// Skip if already processed or still waiting in the queue
+		firstSeen := b.checkFillData(fill)
+		if !b.markQueued(fill.DedupKey()) {
 			continue
 		}
 
 		log.Printf("fill: %s %s %.3f@%.2f %s",
-			fill.Side, fill.Coin, fill.Size, fill.Price, fill.Hash[:6])
+			fill.Side, fill.Coin, fill.Size, fill.Price, shortKey(fill.DedupKey()))
 
-		if err := b.process(fill); err != nil {
-			log.Printf("Error processing fill: %v", err)
-		} else {
-			// Only increment if actually processed (not skipped due to threshold)
-			if _, exists := b.processedFills[fill.Hash]; exists {
-				newFillsCount++
+		b.enqueueFill(fill)
+		queuedCount++
+		if firstSeen {
+			newCount++
+		}
+	}
+
+	if queuedCount > 0 {
+		log.Printf("bot: queued %d fills", queuedCount)
+	}
+
+	return nil
+}
+
+// fillDigest is the part of a fill that must not change between polls
+type fillDigest struct {
+	coin   string
+	side   Side
+	size   float64
+	price  float64
+	time   int64
+	warned bool
+}
+
+func (d fillDigest) differs(fill *Fill) bool {
+	same := func(a, b float64) bool {
+		return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+	}
+	return d.coin != fill.Coin || d.side != fill.Side ||
+		!same(d.size, fill.Size) || !same(d.price, fill.Price)
+}
+
+// checkFillData remembers each polled hash's fields and warns, once per
+// hash, when the API returns it again with materially different ones. The
+// repeat is still skipped as a duplicate: this only surfaces the glitch.
+// Returns whether this is the first time the fill was polled.
+func (b *Bot) checkFillData(fill *Fill) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := fill.DedupKey()
+	digest, seen := b.fillData[key]
+	if !seen {
+		if b.fillData == nil {
+			b.fillData = make(map[string]fillDigest)
+		}
+		b.fillData[key] = fillDigest{coin: fill.Coin, side: fill.Side,
+			size: fill.Size, price: fill.Price, time: fill.Time}
+		return true
+	}
+	if digest.warned || !digest.differs(fill) {
+		return false
+	}
+	log.Printf("bot: fill %s repeated with different data, ignoring: was %s %s %v@%v, now %s %s %v@%v",
+		key, digest.side, digest.coin, digest.size, digest.price,
+		fill.Side, fill.Coin, fill.Size, fill.Price)
+	digest.warned = true
+	b.fillData[key] = digest
+	return false
+}
+
+// markQueued records a hash as waiting for processing. Returns false if
+// the fill was already processed or is already queued.
+func (b *Bot) markQueued(hash string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.processedFills[hash]; exists {
+		return false
+	}
+	if b.queued[hash] {
+		return false
+	}
+	if b.queued == nil {
+		b.queued = make(map[string]bool)
+	}
+	b.queued[hash] = true
+	return true
+}
+
+// enqueueFill hands a fill to the processor. When the queue is full the
+// drop_oldest policy discards the oldest queued fill (it is re-fetched on a
+// later poll within the lookback), otherwise the poller blocks.
+func (b *Bot) enqueueFill(fill *Fill) {
+	select {
+	case b.fillQueue <- fill:
+		return
+	default:
+	}
+
+	if b.config.Monitoring.QueuePolicy == "drop_oldest" {
+		for {
+			select {
+			case oldest := <-b.fillQueue:
+				atomic.AddInt64(&b.queueDropped, 1)
+				b.mu.Lock()
+				delete(b.queued, oldest.DedupKey())
+				b.mu.Unlock()
+				log.Printf("bot: fill queue full, dropped %s %s", oldest.Coin, oldest.DedupKey())
+			default:
+			}
+			select {
+			case b.fillQueue <- fill:
+				return
+			default:
 			}
 		}
 	}
 
-	if newFillsCount > 0 {
-		log.Printf("bot: processed %d fills", newFillsCount)
+	atomic.AddInt64(&b.queueBlocked, 1)
+	select {
+	case b.fillQueue <- fill:
+	case <-b.stopChan:
+	}
+}
 
-		// Show summary every 10 trades
-		totalTrades := b.paperTrader.GetTotalTrades()
-		if totalTrades > 0 && totalTrades%10 == 0 {
-			b.paperTrader.PrintPortfolioSummary()
+// processFills consumes queued fills so slow processing (including disk
+// I/O) never stalls the poll loop
+func (b *Bot) processFills() {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-b.stopChan:
+			return
+		case fill := <-b.fillQueue:
+			b.processQueued(fill)
 		}
 	}
+}
 
-	return nil
+// processQueued processes one fill taken off the queue, timing it
+func (b *Bot) processQueued(fill *Fill) {
+	b.mu.Lock()
+	delete(b.queued, fill.DedupKey())
+	b.mu.Unlock()
+
+	start := time.Now()
+	before := b.paperTrader.GetTotalTrades()
+	if err := b.processor(fill); err != nil {
+		log.Printf("Error processing fill: %v", err)
+	}
+	b.processSeconds.Observe(time.Since(start).Seconds())
+
+	// Show summary every 10 trades
+	totalTrades := b.paperTrader.GetTotalTrades()
+	if totalTrades > before && totalTrades%10 == 0 {
+		b.printSummary()
+	}
+}
+
+// queueStats returns the dropped and blocked enqueue counts
+func (b *Bot) queueStats() (dropped, blocked int64) {
+	return atomic.LoadInt64(&b.queueDropped), atomic.LoadInt64(&b.queueBlocked)
 }
 
 func (b *Bot) process(fill *Fill) error {
-	// Skip if we've already processed this fill
-	if _, exists := b.processedFills[fill.Hash]; exists {
+	if b.observer != nil {
+		b.observe(fill)
 		return nil
 	}
 
-	// Calculate trade value
-	tradeValue := fill.Size * fill.Price
-	if tradeValue < b.config.CopyThreshold {
+	b.mu.Lock()
+	accepted := b.accept(fill)
+	b.mu.Unlock()
+	if !accepted {
 		return nil
 	}
-
-	// Mark as processed with timestamp
-	b.processedFills[fill.Hash] = fill.Time
+	b.observeLatency(fill)
 
 	// Process this trade in paper trader
 	b.paperTrader.ProcessFill(fill)
 
+	// In real mode mirror the simulated position on the exchange
+	if !b.config.PaperTradingOnly {
+		b.placeTWAPChildren()
+		b.syncRealPosition(fill.Coin, fill.Price)
+	}
+
 	return nil
 }
 
+// accept runs dedup and filters, marking the fill processed when it is
+// consumed. Caller must hold b.mu.
+func (b *Bot) accept(fill *Fill) bool {
+	// Skip if we've already processed this fill
+	key := fill.DedupKey()
+	if _, exists := b.processedFills[key]; exists {
+		return false
+	}
+
+	// Reject malformed records once, here, so nothing downstream has to guard
+	if err := fill.Normalize(); err != nil {
+		log.Printf("Skipping invalid fill %s: %v", key, err)
+		b.processedFills[key] = fill.Time
+		return false
+	}
+	fill.applyAlias(b.config.Trading.CoinAliases)
+	b.stampFillTime(fill)
+	b.trackTargetPosition(fill)
+
+	if b.isRepeatedFingerprint(fill) {
+		log.Printf("bot: skipping repeated fill %s %s %.4f@%.2f",
+			fill.Side, fill.Coin, fill.Size, fill.Price)
+		b.processedFills[key] = fill.Time
+		return false
+	}
+
+	if b.targetInDrawdown(fill) {
+		log.Printf("bot: target in drawdown, ignoring %s %s %.4f@%.2f",
+			fill.Side, fill.Coin, fill.Size, fill.Price)
+		b.processedFills[key] = fill.Time
+		return false
+	}
+
+	if rate, low := b.targetWinRateLow(fill); low {
+		log.Printf("bot: target win rate %.0f%% below %.0f%%, ignoring %s %s %.4f@%.2f",
+			rate*100, b.config.Trading.MinTargetWinRate*100,
+			fill.Side, fill.Coin, fill.Size, fill.Price)
+		b.processedFills[key] = fill.Time
+		return false
+	}
+
+	// Paused fills count as processed so resuming never copies them late
+	if b.paused.Load() {
+		log.Printf("bot: paused, not copying %s %s %.4f@%.2f",
+			fill.Side, fill.Coin, fill.Size, fill.Price)
+		b.processedFills[key] = fill.Time
+		return false
+	}
+
+	reason, belowThreshold := b.copyFilter(fill)
+	b.processedFills[key] = fill.Time
+	if belowThreshold {
+		b.noteNearMiss(fill)
+	}
+	if reason != "" {
+		log.Printf("bot: not copying %s %s %.4f@%.2f: %s",
+			fill.Side, fill.Coin, fill.Size, fill.Price, reason)
+		return false
+	}
+	b.trimForCopy(fill) // trim a reversal to the part we copy
+	return true
+}
+
+// defaultMaxFillTimeAge is how old a fill timestamp may be before it is
+// taken as missing or bogus
+const defaultMaxFillTimeAge = 7 * 24 * time.Hour
+
+// stampFillTime replaces a zero or implausibly old fill time, which would
+// otherwise be booked as 1970 and skew latency and age checks, with now
+func (b *Bot) stampFillTime(fill *Fill) {
+	maxAge := time.Duration(b.config.Monitoring.MaxFillTimeAgeHours) * time.Hour
+	if maxAge == 0 {
+		maxAge = defaultMaxFillTimeAge
+	}
+	now := b.clock.Now()
+	if fill.Time > 0 && now.Sub(time.UnixMilli(fill.Time)) <= maxAge {
+		return
+	}
+	log.Printf("bot: fill %s has implausible time %d, using processing time", fill.DedupKey(), fill.Time)
+	fill.Time = now.UnixMilli()
+}
+
+// wouldCopy reports whether fill passes the copy filters (fill age, the
+// coin whitelist, price deviation, uncopied closes, copy thresholds and
+// capacity) and why not when it doesn't. It changes nothing, so it also
+// answers what-if queries. fill must already be normalized.
+func (b *Bot) wouldCopy(fill *Fill) (bool, string) {
+	reason, _ := b.copyFilter(fill)
+	return reason == "", reason
+}
+
+// copyFilter is wouldCopy's check. belowThreshold marks rejections for the
+// copy threshold, which accept counts as near misses.
+func (b *Bot) copyFilter(fill *Fill) (reason string, belowThreshold bool) {
+	reduces := b.paperTrader.Reduces(fill)
+
+	if age, stale := b.fillStale(fill); stale && !reduces {
+		return fmt.Sprintf("stale, %s old", age.Round(time.Second)), false
+	}
+
+	if !b.coinAllowed(fill.Coin) {
+		return fmt.Sprintf("%s not in trading.coins", fill.Coin), false
+	}
+
+	if deviation, suspect := b.priceSuspect(fill); suspect {
+		return fmt.Sprintf("price %.1f%% from the mark, suspect", deviation), false
+	}
+
+	trimmed := *fill
+	if reason := b.trimForCopy(&trimmed); reason != "" {
+		return reason, false
+	}
+
+	// Calculate trade value in our quote currency. Small exits still go
+	// through so we never hold a position the target has closed.
+	tradeValue := trimmed.Size * trimmed.Price * b.paperTrader.quoteRate()
+	if threshold := b.copyThreshold(fill.Coin); tradeValue < threshold &&
+		(b.config.Trading.ThresholdOnCloses || !reduces) {
+		return fmt.Sprintf("$%.2f below the $%.2f copy threshold", tradeValue, threshold), true
+	}
+
+	if !reduces {
+		if reason := b.paperTrader.capacityProblem(&trimmed); reason != "" {
+			return reason, false
+		}
+	}
+	return "", false
+}
+
+// nearMissBand is the share of the copy threshold above which a rejected
+// fill counts as a near miss
+const nearMissBand = 0.8
+
+// noteNearMiss counts a sub-threshold fill within nearMissBand of the
+// threshold
+func (b *Bot) noteNearMiss(fill *Fill) {
+	trimmed := *fill
+	b.trimForCopy(&trimmed)
+	value := trimmed.Size * trimmed.Price * b.paperTrader.quoteRate()
+	threshold := b.copyThreshold(fill.Coin)
+	if value < threshold*nearMissBand {
+		return
+	}
+	b.nearMisses++
+	b.nearMissNotional += value
+	b.debugf("bot: near miss %s %s %.4f@%.2f: $%.2f is %.0f%% of the $%.2f copy threshold",
+		fill.Side, fill.Coin, fill.Size, fill.Price, value, value/threshold*100, threshold)
+}
+
+// nearMissStats returns the near-miss count and total notional
+func (b *Bot) nearMissStats() (int, float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nearMisses, b.nearMissNotional
+}
+
+// debugf logs only with logging.debug set
+func (b *Bot) debugf(format string, args ...interface{}) {
+	if b.config.Logging.Debug {
+		log.Printf(format, args...)
+	}
+}
+
+// fillStale reports the fill's age and whether it exceeds
+// trading.max_fill_age_seconds
+func (b *Bot) fillStale(fill *Fill) (time.Duration, bool) {
+	maxAge := b.config.Trading.MaxFillAgeSeconds
+	if maxAge <= 0 || fill.Time <= 0 {
+		return 0, false
+	}
+	age := b.clock.Now().Sub(time.UnixMilli(fill.Time))
+	return age, age > time.Duration(maxAge)*time.Second
+}
+
+// priceSuspect reports the fill price's % deviation from the coin's current
+// mark and whether it exceeds trading.max_price_deviation_pct. Without a
+// mark there is nothing to compare against and the fill passes.
+func (b *Bot) priceSuspect(fill *Fill) (float64, bool) {
+	limit := b.config.Trading.MaxPriceDeviationPct
+	if limit <= 0 {
+		return 0, false
+	}
+	mark, ok := b.paperTrader.FeedMark(fill.Coin)
+	if !ok {
+		return 0, false
+	}
+	deviation := math.Abs(fill.Price-mark) / mark * 100
+	return deviation, deviation > limit
+}
+
+// uncopiedClose reports whether fill closes a target position while we hold
+// nothing in its coin. A fill reversing the target through flat is trimmed
+// to the part opening their new side, which we do copy.
+func (b *Bot) uncopiedClose(fill *Fill) bool {
+	start := fill.StartPosition
+	if start == 0 || fill.Side.IsBuy() == (start > 0) {
+		return false // target opening or adding
+	}
+	if b.paperTrader.Holds(fill.Coin) {
+		return false
+	}
+	if fill.Size <= math.Abs(start) {
+		return true
+	}
+	fill.Size -= math.Abs(start)
+	return false
+}
+
+// trimForCopy cuts fill down to the part the copy rules let through,
+// returning why when nothing is left
+func (b *Bot) trimForCopy(fill *Fill) string {
+	if b.config.Trading.CopyMode == CopyModeAddsOnly && targetOpening(fill) {
+		return "target opening, copying adds only"
+	}
+	if b.config.Trading.ClosesNeedCopiedOpen && b.uncopiedClose(fill) {
+		return "closes a position we never copied"
+	}
+	return ""
+}
+
+// targetOpening reports whether fill opens a target position from flat. A
+// reversal is trimmed to its closing part, the new side being an open.
+func targetOpening(fill *Fill) bool {
+	start := fill.StartPosition
+	if start == 0 {
+		return true
+	}
+	if fill.Side.IsBuy() != (start > 0) && fill.Size > math.Abs(start) {
+		fill.Size = math.Abs(start)
+	}
+	return false
+}
+
+// coinAllowed reports whether coin is on the trading.coins whitelist
+func (b *Bot) coinAllowed(coin string) bool {
+	if len(b.config.Trading.Coins) == 0 {
+		return true
+	}
+	for _, allowed := range b.config.Trading.Coins {
+		if allowed == coin {
+			return true
+		}
+	}
+	return false
+}
+
+// copyThreshold returns the minimum copied trade value for coin
+func (b *Bot) copyThreshold(coin string) float64 {
+	if threshold, exists := b.config.Trading.CoinThresholds[coin]; exists {
+		return threshold
+	}
+	return b.config.CopyThreshold
+}
+
+// pnlPoint is one target fill's closed PnL
+type pnlPoint struct {
+	time int64
+	pnl  float64
+}
+
+// targetInDrawdown records the fill's closed PnL and, when gated by
+// trading.require_positive_target_pnl, reports whether the target's trailing
+// realized PnL over the lookback is negative
+func (b *Bot) targetInDrawdown(fill *Fill) bool {
+	if !b.config.Trading.RequirePositiveTargetPnL {
+		return false
+	}
+
+	if b.targetPnL == nil {
+		b.targetPnL = make(map[string]pnlPoint)
+	}
+	pnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+	b.targetPnL[fill.DedupKey()] = pnlPoint{time: fill.Time, pnl: pnl}
+
+	lookback := b.config.Trading.TargetPnLLookbackMinutes
+	if lookback <= 0 {
+		lookback = 24 * 60
+	}
+	cutoff := fill.Time - int64(lookback)*60*1000
+	trailing := 0.0
+	for hash, point := range b.targetPnL {
+		if point.time < cutoff {
+			delete(b.targetPnL, hash)
+			continue
+		}
+		trailing += point.pnl
+	}
+	return trailing < 0
+}
+
+// targetWinRateLow records the fill's result when it closed PnL and, when
+// gated by trading.min_target_win_rate, reports whether the target's win
+// rate over their last target_win_rate_trades closing fills is below the
+// minimum. Fewer closing fills than that never gate.
+func (b *Bot) targetWinRateLow(fill *Fill) (rate float64, low bool) {
+	minRate := b.config.Trading.MinTargetWinRate
+	if minRate <= 0 {
+		return 0, false
+	}
+
+	if b.targetResults == nil {
+		b.targetResults = make(map[string]pnlPoint)
+	}
+	if pnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64); pnl != 0 {
+		b.targetResults[fill.DedupKey()] = pnlPoint{time: fill.Time, pnl: pnl}
+	}
+
+	window := b.config.Trading.TargetWinRateTrades
+	if window <= 0 {
+		window = defaultWinRateTrades
+	}
+
+	hashes := make([]string, 0, len(b.targetResults))
+	for hash := range b.targetResults {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		ti, tj := b.targetResults[hashes[i]].time, b.targetResults[hashes[j]].time
+		if ti != tj {
+			return ti > tj
+		}
+		return hashes[i] < hashes[j]
+	})
+
+	// Beyond the window only keep results a re-poll could still return
+	cutoff := fill.Time - b.processedFillTTL().Milliseconds()
+	for _, hash := range hashes[min(window, len(hashes)):] {
+		if b.targetResults[hash].time < cutoff {
+			delete(b.targetResults, hash)
+		}
+	}
+
+	if len(hashes) < window {
+		return 0, false
+	}
+	wins := 0
+	for _, hash := range hashes[:window] {
+		if b.targetResults[hash].pnl > 0 {
+			wins++
+		}
+	}
+	rate = float64(wins) / float64(window)
+	return rate, rate < minRate
+}
+
+// isRepeatedFingerprint reports whether an identical coin/side/price/size
+// fill was first seen within the configured window. Repeats don't move the
+// window; the next fill after it expires starts a new one.
+func (b *Bot) isRepeatedFingerprint(fill *Fill) bool {
+	window := int64(b.config.Trading.FingerprintDedupSeconds) * 1000
+	if window <= 0 {
+		return false
+	}
+	if b.fingerprints == nil {
+		b.fingerprints = make(map[string]int64)
+	}
+
+	key := fmt.Sprintf("%s|%s|%g|%g", fill.Coin, fill.Side, fill.Price, fill.Size)
+	first, seen := b.fingerprints[key]
+	if seen && fill.Time >= first && fill.Time-first <= window {
+		return true
+	}
+	b.fingerprints[key] = fill.Time
+	return false
+}
+
+// refreshMarks pulls mid prices and re-runs risk checks. If the feed fails
+// positions keep their last marks, and risk checks flag them once stale.
+func (b *Bot) refreshMarks() {
+	mids, err := b.api().GetAllMids()
+	if err != nil {
+		log.Printf("Error fetching marks: %v", err)
+	} else {
+		b.paperTrader.UpdateMarks(mids)
+	}
+	b.paperTrader.CheckRisk()
+	b.placeTWAPChildren()
+}
+
+// defaultLeveragePoll is how often the target's leverage is read when a
+// mirroring option needs it and monitoring.leverage_poll_seconds is unset
+const defaultLeveragePoll = time.Minute
+
+// leverageDue reports whether this poll should read the target's leverage:
+// once per monitoring.leverage_poll_seconds, or defaultLeveragePoll when
+// mirroring needs it, and never when neither is set
+func (b *Bot) leverageDue() bool {
+	interval := time.Duration(b.config.Monitoring.LeveragePollSeconds) * time.Second
+	if interval == 0 && (b.config.Trading.MirrorLeverage || b.config.Trading.MirrorTargetLeverage) {
+		interval = defaultLeveragePoll
+	}
+	if interval <= 0 {
+		return false
+	}
+	now := b.clock.Now()
+	if !b.leverageRead.IsZero() && now.Sub(b.leverageRead) < interval {
+		return false
+	}
+	b.leverageRead = now
+	return true
+}
+
+// checkTargetLeverage polls the target's positions and records leverage
+// changes, which happen without any fill we could see
+func (b *Bot) checkTargetLeverage() error {
+	state, err := b.api().GetClearinghouseState(b.targetAccount())
+	if err != nil {
+		return err
+	}
+	if b.targetLeverage == nil {
+		b.targetLeverage = make(map[string]float64)
+	}
+
+	for _, asset := range state.AssetPositions {
+		pos := asset.Position
+		leverage := pos.Leverage.Value
+		old, known := b.targetLeverage[pos.Coin]
+		if known && old == leverage {
+			continue
+		}
+		b.targetLeverage[pos.Coin] = leverage
+		b.paperTrader.RecordTargetLeverage(pos.Coin, leverage)
+
+		if !known {
+			continue // first sighting is not a change
+		}
+		log.Printf("bot: target leverage %s %.0fx -> %.0fx", pos.Coin, old, leverage)
+
+		if b.config.Trading.MirrorLeverage && !b.config.PaperTradingOnly {
+			isCross := pos.Leverage.Type == "cross"
+			if err := b.api().UpdateLeverage(pos.Coin, leverage, isCross); err != nil {
+				log.Printf("Error mirroring leverage for %s: %v", pos.Coin, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backfillPositions copies the target's open positions before the first
+// poll. Each is marked at the current mid, never left at the target's
+// possibly old entry.
+func (b *Bot) backfillPositions() error {
+	state, err := b.api().GetClearinghouseState(b.targetAccount())
+	if err != nil {
+		return err
+	}
+	mids, err := b.api().GetAllMids()
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range state.AssetPositions {
+		pos := asset.Position
+		if pos.Size == 0 {
+			continue
+		}
+		fill := &Fill{Coin: pos.Coin}
+		fill.applyAlias(b.config.Trading.CoinAliases)
+		if !b.coinAllowed(fill.Coin) {
+			continue
+		}
+		mark, ok := mids[pos.Coin]
+		if !ok {
+			log.Printf("Skipping backfill of %s: no mark", pos.Coin)
+			continue
+		}
+		size := b.paperTrader.Backfill(fill.Coin, pos.Size > 0, pos.EntryPx, mark)
+		if size != 0 {
+			log.Printf("bot: backfilled %s %.6f @ %.4f, marked %.4f",
+				fill.Coin, size, pos.EntryPx, mark)
+		}
+	}
+	return nil
+}
+
+// processedFillTTL is how long processed fill hashes are remembered
+func (b *Bot) processedFillTTL() time.Duration {
+	if ttl := b.config.Monitoring.ProcessedFillTTLMinutes; ttl > 0 {
+		return time.Duration(ttl) * time.Minute
+	}
+	return processedFillRetention
+}
+
 // cleanupProcessedFills removes entries older than cutoffTime to prevent memory growth
 func (b *Bot) cleanupProcessedFills(cutoffTime int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	for hash, timestamp := range b.processedFills {
 		if timestamp < cutoffTime {
 			delete(b.processedFills, hash)
 		}
 	}
+	for key, timestamp := range b.fingerprints {
+		if timestamp < cutoffTime {
+			delete(b.fingerprints, key)
+		}
+	}
+	for hash, digest := range b.fillData {
+		if digest.time < cutoffTime {
+			delete(b.fillData, hash)
+		}
+	}
 }
 
+// checkTrades polls for new fills, retrying failures with linear backoff.
+// With monitoring.poll_deadline_seconds the attempts, backoff included,
+// give up once the deadline passes so the next tick polls on time.
 func (b *Bot) checkTrades() error {
+	ctx := context.Background()
+	if deadline := b.config.Monitoring.PollDeadlineSeconds; deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(deadline)*time.Second)
+		defer cancel()
+	}
+
 	maxRetries := 3
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := b.checkForNewTrades()
+		err := b.checkForNewTradesContext(ctx)
 		if err == nil {
 			return nil
 		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("poll deadline exceeded after %d attempts: %v", attempt, err)
+		}
 
 		log.Printf("Attempt %d/%d failed: %v", attempt, maxRetries, err)
 
 		if attempt < maxRetries {
 			waitTime := time.Duration(attempt) * 2 * time.Second
 			log.Printf("retrying in %v...", waitTime)
-			time.Sleep(waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return fmt.Errorf("poll deadline exceeded after %d attempts: %v", attempt, err)
+			}
 		}
 	}
 
@@ -1,23 +1,39 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// testAssets stands in for the perp universe, so mock exchanges placing
+// orders needn't also serve meta
+var testAssets = map[string]int{"BTC": 0, "ETH": 1, "SOL": 2}
+
 // createTestConfig creates a config with proper bankroll for testing
 func createTestConfig() *Config {
 	return &Config{
-		TargetAccount: "0x1234567890abcdef1234567890abcdef12345678",
-		APIKey:        "test_key",
-		PrivateKey:    "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
-		CopyThreshold: 1000.0,
-		Bankroll:      1000000.0, // $1M for tests to avoid limit issues
-		Leverage:      10.0,      // 10x leverage
-		BaseNotional:  1000.0,    // $1k base trade size
+		TargetAccount:    "0x1234567890abcdef1234567890abcdef12345678",
+		APIKey:           "test_key",
+		PrivateKey:       "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		CopyThreshold:    1000.0,
+		PaperTradingOnly: true,
+		Bankroll:         1000000.0, // $1M for tests to avoid limit issues
+		Leverage:         10.0,      // 10x leverage
+		BaseNotional:     1000.0,    // $1k base trade size
 	}
 }
 
@@ -212,6 +228,167 @@ func TestClientAPI(t *testing.T) {
 	}
 }
 
+func TestClientFillsResponseShapes(t *testing.T) {
+	const fills = `[{"coin":"BTC","side":"B","sz":"1.0","px":"50000.0","time":1700000000000,"hash":"0xshape1"},` +
+		`{"coin":"ETH","side":"A","sz":"2.0","px":"3000.0","time":1700000001000,"hash":"0xshape2"}]`
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"Bare array", fills},
+		{"Wrapped in fills", `{"fills":` + fills + `}`},
+		{"Wrapped in data", ` {"status":"ok","data":` + fills + `}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			client, err := NewClient(createTestConfig())
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			client.baseURL = server.URL
+
+			got, err := client.GetUserFillsByTime("0xtarget", 0, 1700000002000)
+			if err != nil {
+				t.Fatalf("GetUserFillsByTime() error = %v", err)
+			}
+			if len(got) != 2 || got[0].Hash != "0xshape1" || got[1].Coin != "ETH" || got[1].Size != 2.0 {
+				t.Errorf("Fills = %+v, want BTC and ETH", got)
+			}
+		})
+	}
+
+	if _, err := decodeFills([]byte(`{"error":"rate limited"}`)); err == nil {
+		t.Errorf("decodeFills() should reject an object without fills")
+	}
+}
+
+func TestTargetLeverageChange(t *testing.T) {
+	leverage := 10
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["type"] != "clearinghouseState" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"assetPositions":[{"position":{"coin":"ETH","szi":"12.5",`+
+			`"entryPx":"4000.0","leverage":{"type":"cross","value":%d}},"type":"oneWay"}]}`, leverage)
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	if err := bot.checkTargetLeverage(); err != nil {
+		t.Fatalf("checkTargetLeverage() error = %v", err)
+	}
+	if got := bot.paperTrader.TargetLeverage["ETH"]; got != 10 {
+		t.Errorf("Recorded leverage = %.0f, want 10", got)
+	}
+
+	// Target bumps leverage with no fill
+	leverage = 20
+	if err := bot.checkTargetLeverage(); err != nil {
+		t.Fatalf("checkTargetLeverage() error = %v", err)
+	}
+	if got := bot.targetLeverage["ETH"]; got != 20 {
+		t.Errorf("Detected leverage = %.0f, want 20", got)
+	}
+	if got := bot.paperTrader.TargetLeverage["ETH"]; got != 20 {
+		t.Errorf("Recorded leverage = %.0f, want 20", got)
+	}
+}
+
+func TestMirrorTargetLeverage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"assetPositions":[{"position":{"coin":"ETH","szi":"12.5",`+
+			`"entryPx":"4000.0","leverage":{"type":"isolated","value":5}},"type":"oneWay"}]}`)
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Trading.MirrorTargetLeverage = true
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	if err := bot.checkTargetLeverage(); err != nil {
+		t.Fatalf("checkTargetLeverage() error = %v", err)
+	}
+
+	pt := bot.paperTrader
+	pt.Bankroll = 10000.0
+	pt.Leverage = 1.0
+	pt.BaseNotional = 100000.0 // capped by capacity, not base size
+
+	// ETH is capped at the target's 5x, BTC at our own 1x
+	eth := createTestFill("ETH", "B", 1.0, 4000.0, "0.0", time.Now().Unix())
+	if size := pt.calculateDynamicTradeSize(eth); math.Abs(size-12.5) > 1e-9 {
+		t.Errorf("ETH copy = %.4f, want 12.5 ($50k at 5x)", size)
+	}
+	if !pt.validatePositionSize("ETH", 12.5, 4000.0) || pt.validatePositionSize("ETH", 12.6, 4000.0) {
+		t.Error("ETH exposure cap should be $50k at the target's 5x")
+	}
+	btc := createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix())
+	if size := pt.calculateDynamicTradeSize(btc); math.Abs(size-0.2) > 1e-9 {
+		t.Errorf("BTC copy = %.4f, want 0.2 ($10k at 1x)", size)
+	}
+
+	// Off, the target's leverage is only reported
+	pt.MirrorTargetLev = false
+	if size := pt.calculateDynamicTradeSize(eth); math.Abs(size-2.5) > 1e-9 {
+		t.Errorf("Unmirrored ETH copy = %.4f, want 2.5 ($10k at 1x)", size)
+	}
+}
+
+func TestLeveragePollInterval(t *testing.T) {
+	due := func(config *Config, clock *fakeClock, steps ...time.Duration) []bool {
+		bot, err := NewBot(config)
+		if err != nil {
+			t.Fatalf("Failed to create bot: %v", err)
+		}
+		bot.clock = clock
+		var got []bool
+		for _, step := range steps {
+			clock.Advance(step)
+			got = append(got, bot.leverageDue())
+		}
+		return got
+	}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Plain paper trading never reads it
+	if got := due(createTestConfig(), newFakeClock(start), 0, time.Hour); got[0] || got[1] {
+		t.Errorf("Leverage polled with nothing reading it: %v", got)
+	}
+
+	// Mirroring reads it once a minute, not every 5s poll
+	config := createTestConfig()
+	config.Trading.MirrorTargetLeverage = true
+	got := due(config, newFakeClock(start), 0, 5*time.Second, 50*time.Second, 5*time.Second)
+	if want := []bool{true, false, false, true}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Polls due = %v, want %v", got, want)
+	}
+
+	// An explicit interval applies without mirroring
+	config = createTestConfig()
+	config.Monitoring.LeveragePollSeconds = 10
+	got = due(config, newFakeClock(start), 0, 5*time.Second, 5*time.Second)
+	if want := []bool{true, false, true}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Polls due = %v, want %v", got, want)
+	}
+}
+
 func TestBotDuplicateFillHandling(t *testing.T) {
 	config := createTestConfig()
 	config.CopyThreshold = 100.0
@@ -251,7 +428,81 @@ func TestBotDuplicateFillHandling(t *testing.T) {
 	}
 }
 
+func TestFingerprintDedup(t *testing.T) {
+	now := time.Now().UnixMilli()
+	newFills := func() []*Fill {
+		return []*Fill{
+			{Coin: "ETH", Side: "B", Size: 1.0, Price: 4000.0, Hash: "iceberg1", Time: now},
+			{Coin: "ETH", Side: "B", Size: 1.0, Price: 4000.0, Hash: "iceberg2", Time: now + 2000},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		window   int
+		expected int
+	}{
+		{"Disabled processes both", 0, 2},
+		{"Within window collapses to one", 5, 1},
+		{"Outside window processes both", 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestConfig()
+			config.CopyThreshold = 100.0
+			config.Trading.FingerprintDedupSeconds = tt.window
+			bot := &Bot{
+				config:         config,
+				paperTrader:    NewTestPaperTrader(),
+				processedFills: make(map[string]int64),
+				clock:          systemClock{},
+			}
+
+			for _, fill := range newFills() {
+				bot.process(fill)
+			}
+
+			if bot.paperTrader.GetTotalTrades() != tt.expected {
+				t.Errorf("Trades = %d, want %d", bot.paperTrader.GetTotalTrades(), tt.expected)
+			}
+			// Both hashes are tracked so neither is reconsidered next poll
+			if len(bot.processedFills) != 2 {
+				t.Errorf("Tracked hashes = %d, want 2", len(bot.processedFills))
+			}
+		})
+	}
+}
+
+func TestFingerprintWindowDoesNotSlide(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	config.Trading.FingerprintDedupSeconds = 5
+	bot := &Bot{
+		config:         config,
+		paperTrader:    NewTestPaperTrader(),
+		processedFills: make(map[string]int64),
+		clock:          systemClock{},
+	}
+
+	// Repeats every 3s: the one at 6s is past the window opened at 0s even
+	// though it's within 5s of the repeat at 3s, and opens a new window
+	now := time.Now().UnixMilli()
+	for i, offset := range []int64{0, 3000, 6000, 9000} {
+		bot.process(&Fill{Coin: "ETH", Side: "B", Size: 1.0, Price: 4000.0, Hash: fmt.Sprintf("iceberg%d", i), Time: now + offset})
+	}
+
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 2 {
+		t.Errorf("Trades = %d, want 2 (fills at 0s and 6s)", trades)
+	}
+}
+
 func TestConfigEnvironmentDefaults(t *testing.T) {
+	// Run from an empty directory so a local config.toml isn't picked up
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
 	// Test with missing environment variables (should use defaults/fail gracefully)
 	_, err := loadConfig("")
 
@@ -305,6 +556,233 @@ func TestBotStartStop(t *testing.T) {
 	bot.Stop()
 }
 
+func TestStartupJitter(t *testing.T) {
+	config := createTestConfig()
+	config.Monitoring.StartupJitterMs = 2000
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	clock := newFakeClock(time.Now())
+	bot.clock = clock
+	bot.rand = rand.New(rand.NewSource(7))
+
+	expected := time.Duration(rand.New(rand.NewSource(7)).Intn(2000)) * time.Millisecond
+
+	bot.Start()
+	defer bot.Stop()
+
+	// Wait for the monitor goroutine to request its startup delay
+	deadline := time.Now().Add(time.Second)
+	for len(clock.Waits()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	waits := clock.Waits()
+	if len(waits) != 1 {
+		t.Fatalf("Startup delay requests = %d, want 1", len(waits))
+	}
+	if waits[0] < 0 || waits[0] >= 2*time.Second {
+		t.Errorf("Startup delay = %v, want within [0, 2s)", waits[0])
+	}
+	if waits[0] != expected {
+		t.Errorf("Startup delay = %v, want %v from injected source", waits[0], expected)
+	}
+
+	// No jitter configured means no delay
+	bot.config.Monitoring.StartupJitterMs = 0
+	if delay := bot.startupDelay(); delay != 0 {
+		t.Errorf("Startup delay without jitter = %v, want 0", delay)
+	}
+}
+
+func queueTestFill(i int) *Fill {
+	return &Fill{
+		Coin:  "BTC",
+		Side:  SideBuy,
+		Size:  1.0,
+		Price: 50000.0,
+		Time:  time.Now().UnixMilli(),
+		Hash:  fmt.Sprintf("queue_hash_%d", i),
+	}
+}
+
+func TestFillQueueDropOldest(t *testing.T) {
+	config := createTestConfig()
+	config.Monitoring.QueueSize = 2
+	config.Monitoring.QueuePolicy = "drop_oldest"
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	// No processor running: the poller must still never block
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			fill := queueTestFill(i)
+			if bot.markQueued(fill.Hash) {
+				bot.enqueueFill(fill)
+			}
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Poller blocked on a full drop_oldest queue")
+	}
+
+	dropped, blocked := bot.queueStats()
+	if dropped != 3 || blocked != 0 {
+		t.Errorf("Queue stats = dropped %d blocked %d, want 3 and 0", dropped, blocked)
+	}
+	if len(bot.fillQueue) != 2 {
+		t.Fatalf("Queue length = %d, want 2", len(bot.fillQueue))
+	}
+	for _, want := range []string{"queue_hash_3", "queue_hash_4"} {
+		if got := (<-bot.fillQueue).Hash; got != want {
+			t.Errorf("Queued fill = %s, want %s", got, want)
+		}
+	}
+
+	// Dropped fills are forgotten so the next poll picks them up again
+	if !bot.markQueued("queue_hash_0") {
+		t.Errorf("Dropped fill should be re-queueable")
+	}
+}
+
+func TestFillQueueBlockPolicy(t *testing.T) {
+	config := createTestConfig()
+	config.Monitoring.QueueSize = 1
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	bot.enqueueFill(queueTestFill(0))
+
+	done := make(chan struct{})
+	go func() {
+		bot.enqueueFill(queueTestFill(1))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("Enqueue on a full block queue returned without a consumer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-bot.fillQueue
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Enqueue did not resume after the consumer read")
+	}
+
+	if _, blocked := bot.queueStats(); blocked != 1 {
+		t.Errorf("Blocked enqueues = %d, want 1", blocked)
+	}
+}
+
+func TestFillQueueSlowProcessor(t *testing.T) {
+	config := createTestConfig()
+	config.Monitoring.QueueSize = 4
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	release := make(chan struct{})
+	processed := make(chan string, 4)
+	bot.processor = func(fill *Fill) error {
+		<-release
+		processed <- fill.Hash
+		return nil
+	}
+
+	bot.wg.Add(1)
+	go bot.processFills()
+
+	// Enqueueing returns immediately while the processor is stuck
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		fill := queueTestFill(i)
+		bot.markQueued(fill.Hash)
+		bot.enqueueFill(fill)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Enqueue took %v with a slow processor", elapsed)
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case hash := <-processed:
+			if want := fmt.Sprintf("queue_hash_%d", i); hash != want {
+				t.Errorf("Processed %s, want %s", hash, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Processor did not drain the queue")
+		}
+	}
+
+	close(bot.stopChan)
+	bot.wg.Wait()
+}
+
+func TestFillCapCountsNewFills(t *testing.T) {
+	var fills []*Fill
+	for i := 0; i < 60; i++ {
+		fills = append(fills, queueTestFill(i))
+	}
+	body, _ := json.Marshal(fills)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	// drain empties the queue the way a processor that left every fill
+	// unprocessed would, so each poll queues them all again
+	drain := func() int {
+		n := 0
+		for len(bot.fillQueue) > 0 {
+			fill := <-bot.fillQueue
+			bot.mu.Lock()
+			delete(bot.queued, fill.DedupKey())
+			bot.mu.Unlock()
+			n++
+		}
+		return n
+	}
+
+	if err := bot.checkForNewTrades(); err != nil {
+		t.Fatalf("checkForNewTrades() error = %v", err)
+	}
+	if n := drain(); n != 50 {
+		t.Fatalf("First poll queued %d fills, want the 50 cap", n)
+	}
+
+	// Fills polled before don't use up the cap, so the deferred ten get in
+	if err := bot.checkForNewTrades(); err != nil {
+		t.Fatalf("checkForNewTrades() error = %v", err)
+	}
+	if n := drain(); n != 60 {
+		t.Errorf("Second poll queued %d fills, want all 60", n)
+	}
+}
+
 func TestRealWorldTradingScenario(t *testing.T) {
 	// Simulate The White Whale's actual trading pattern
 	config := createTestConfig()
@@ -441,6 +919,35 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestMissingFillTimestamps(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = NewTestPaperTrader()
+
+	// No timestamp at all, and one from 1970
+	output := captureLog(t, func() {
+		bot.process(&Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: 50000.0, Hash: "notime"})
+		bot.process(&Fill{Coin: "ETH", Side: "B", Size: 1.0, Price: 3000.0, Hash: "epoch", Time: 1000})
+	})
+	if !strings.Contains(output, "implausible time") {
+		t.Errorf("Log %q should warn about the timestamps", output)
+	}
+
+	trades := bot.paperTrader.TradeHistory
+	if len(trades) != 2 {
+		t.Fatalf("Trades = %d, want 2", len(trades))
+	}
+	for _, trade := range trades {
+		if age := time.Since(trade.Timestamp); age < 0 || age > time.Minute {
+			t.Errorf("%s trade recorded at %v, want about now", trade.Coin, trade.Timestamp)
+		}
+	}
+}
+
 // Benchmark for bot performance under load
 func BenchmarkBotProcessFill(b *testing.B) {
 	config := createTestConfig()
@@ -469,3 +976,974 @@ func BenchmarkBotProcessFill(b *testing.B) {
 		bot.process(&testFill)
 	}
 }
+
+func TestQuoteCurrencyConversion(t *testing.T) {
+	config := createTestConfig()
+	config.Trading.QuoteCurrency = "EUR"
+	config.Trading.QuoteRate = 1.05
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	pt.DisableDynamicSize = false
+	pt.BaseNotional = 1000.0
+	pt.QuoteRate = config.Trading.QuoteRate
+	pt.QuoteCurrency = config.Trading.QuoteCurrency
+	bot.paperTrader = pt
+
+	// $975 USDC is 1023.75 in our quote, above the 1000 threshold
+	fill := &Fill{Coin: "BTC", Side: SideBuy, Size: 0.0195, Price: 50000.0,
+		Time: time.Now().UnixMilli(), Hash: "quote_hash_1"}
+	bot.process(fill)
+	if pt.GetTotalTrades() != 1 {
+		t.Fatalf("Converted fill above threshold should be copied, trades = %d", pt.GetTotalTrades())
+	}
+	if summary := captureStdout(t, pt.PrintPortfolioSummary); !strings.Contains(summary, "Quote: EUR at 1.0500 per USDC") {
+		t.Errorf("Summary doesn't label the quote currency:\n%s", summary)
+	}
+
+	// Base notional of 1000 in our quote buys 1000 / (50000 * 1.05) BTC
+	want := 1000.0 / (50000.0 * 1.05)
+	if got := pt.Positions["BTC"].Size; got < want-1e-9 || got > want+1e-9 {
+		t.Errorf("Copy size = %.8f, want %.8f", got, want)
+	}
+
+	// $960 USDC is 1008 ours; at 1:1 it would be below the threshold
+	bot.config.CopyThreshold = 1000.0
+	pt.QuoteRate = 1.0
+	bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 0.32, Price: 3000.0,
+		Time: time.Now().UnixMilli(), Hash: "quote_hash_2"})
+	if pt.GetTotalTrades() != 1 {
+		t.Errorf("Fill below threshold at 1:1 should be skipped, trades = %d", pt.GetTotalTrades())
+	}
+}
+
+func TestTargetWinRateGate(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	config.Trading.MinTargetWinRate = 0.5
+	config.Trading.TargetWinRateTrades = 4
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	bot.paperTrader = pt
+
+	start := time.Now().Add(-30 * time.Minute).UnixMilli()
+	n := 0
+	send := func(closedPnl string) {
+		n++
+		bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0,
+			Time: start + int64(n)*60*1000, ClosedPnl: closedPnl,
+			Hash: fmt.Sprintf("winrate_hash_%d", n)})
+	}
+
+	// Too few closing fills to judge, then 3 of 4 and 2 of 4 winners
+	for _, pnl := range []string{"100", "100", "100", "-100", "-100"} {
+		send(pnl)
+	}
+	if pt.GetTotalTrades() != 5 {
+		t.Fatalf("Signals at or above the win rate should be copied, trades = %d", pt.GetTotalTrades())
+	}
+
+	// 1 of the last 4 wins: paused, and opening fills don't count as trades
+	send("-100")
+	send("0")
+	send("100")
+	if pt.GetTotalTrades() != 5 {
+		t.Errorf("Signals below the win rate should be ignored, trades = %d", pt.GetTotalTrades())
+	}
+
+	// Back to 2 of 4
+	send("100")
+	if pt.GetTotalTrades() != 6 {
+		t.Errorf("Signals after recovery should be copied, trades = %d", pt.GetTotalTrades())
+	}
+}
+
+func TestTargetDrawdownGate(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	config.Trading.RequirePositiveTargetPnL = true
+	config.Trading.TargetPnLLookbackMinutes = 60
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	bot.paperTrader = pt
+
+	start := time.Now().Add(-30 * time.Minute).UnixMilli()
+	n := 0
+	send := func(offsetMin int, closedPnl string) {
+		n++
+		bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0,
+			Time: start + int64(offsetMin)*60*1000, ClosedPnl: closedPnl,
+			Hash: fmt.Sprintf("drawdown_hash_%d", n)})
+	}
+
+	send(0, "0.0")
+	if pt.GetTotalTrades() != 1 {
+		t.Fatalf("Flat target should be copied, trades = %d", pt.GetTotalTrades())
+	}
+
+	// Target books a loss: its signals are ignored, including the losing fill
+	send(1, "-500.0")
+	send(2, "0.0")
+	if pt.GetTotalTrades() != 1 {
+		t.Errorf("Signals during drawdown should be ignored, trades = %d", pt.GetTotalTrades())
+	}
+
+	// Recovery above zero resumes copying
+	send(3, "800.0")
+	send(4, "0.0")
+	if pt.GetTotalTrades() != 3 {
+		t.Errorf("Signals after recovery should be copied, trades = %d", pt.GetTotalTrades())
+	}
+
+	// A loss that ages out of the lookback stops counting
+	send(5, "-1000.0")
+	if pt.GetTotalTrades() != 3 {
+		t.Errorf("Signal in new drawdown should be ignored, trades = %d", pt.GetTotalTrades())
+	}
+	send(70, "0.0")
+	if pt.GetTotalTrades() != 4 {
+		t.Errorf("Losses outside the lookback should not gate, trades = %d", pt.GetTotalTrades())
+	}
+}
+
+func TestCoinWhitelistAndThresholds(t *testing.T) {
+	config := createTestConfig()
+	config.Trading.Coins = []string{"BTC", "ETH"}
+	config.Trading.CoinThresholds = map[string]float64{"ETH": 5000.0}
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	bot.paperTrader = pt
+
+	now := time.Now().UnixMilli()
+	bot.process(&Fill{Coin: "SOL", Side: SideBuy, Size: 100, Price: 150, Time: now, Hash: "wl_1"})
+	bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 1, Price: 3000, Time: now, Hash: "wl_2"})
+	bot.process(&Fill{Coin: "BTC", Side: SideBuy, Size: 0.1, Price: 50000, Time: now, Hash: "wl_3"})
+
+	if _, exists := pt.Positions["SOL"]; exists {
+		t.Errorf("Coin outside whitelist should not be copied")
+	}
+	if _, exists := pt.Positions["ETH"]; exists {
+		t.Errorf("ETH below its 5000 threshold should not be copied")
+	}
+	if _, exists := pt.Positions["BTC"]; !exists {
+		t.Errorf("BTC above the default threshold should be copied")
+	}
+}
+
+func TestSubThresholdClosesAreCopied(t *testing.T) {
+	for _, thresholdOnCloses := range []bool{false, true} {
+		config := createTestConfig()
+		config.CopyThreshold = 1000.0
+		config.Trading.ThresholdOnCloses = thresholdOnCloses
+
+		bot, err := NewBot(config)
+		if err != nil {
+			t.Fatalf("Failed to create bot: %v", err)
+		}
+		pt := NewTestPaperTrader()
+		bot.paperTrader = pt
+
+		now := time.Now().UnixMilli()
+		bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0, Time: now, Hash: "close_open"})
+
+		// Target scales out in $750 clips, each below the threshold
+		for i := 0; i < 4; i++ {
+			bot.process(&Fill{Coin: "ETH", Side: SideSell, Size: 0.25, Price: 3000.0,
+				Time: now + int64(i+1), Hash: fmt.Sprintf("close_%d", i)})
+		}
+
+		size := pt.Positions["ETH"].Size
+		if !thresholdOnCloses && math.Abs(size) > 1e-9 {
+			t.Errorf("Small closes should flatten the copy, size = %.4f", size)
+		}
+		if thresholdOnCloses && size != 1.0 {
+			t.Errorf("threshold_on_closes should skip small closes, size = %.4f", size)
+		}
+
+		// Small opens stay filtered either way
+		bot.process(&Fill{Coin: "SOL", Side: SideBuy, Size: 1.0, Price: 150.0, Time: now + 10, Hash: "small_open"})
+		if _, exists := pt.Positions["SOL"]; exists {
+			t.Errorf("Sub-threshold open should not be copied")
+		}
+	}
+}
+
+func TestUncopiedCloseIsSkipped(t *testing.T) {
+	config := createTestConfig()
+	config.Trading.ClosesNeedCopiedOpen = true
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	bot.paperTrader = pt
+
+	// The target's 2 ETH long predates us; their close must not open a short
+	now := time.Now().UnixMilli()
+	bot.process(&Fill{Coin: "ETH", Side: SideSell, Size: 2.0, Price: 3000.0,
+		StartPosition: 2.0, Time: now, Hash: "uncopied_close"})
+	if pt.Holds("ETH") {
+		t.Fatalf("Close of an uncopied position opened %.4f ETH", pt.Positions["ETH"].Size)
+	}
+
+	// Reversing through flat copies only the new short
+	bot.process(&Fill{Coin: "BTC", Side: SideSell, Size: 1.5, Price: 50000.0,
+		StartPosition: 1.0, Time: now + 1, Hash: "uncopied_reverse"})
+	if pos := pt.Positions["BTC"]; pos == nil || math.Abs(pos.Size+0.5) > 1e-9 {
+		t.Errorf("Reversal should copy a 0.5 BTC short, got %+v", pos)
+	}
+
+	// Closes of positions we did copy go through
+	bot.process(&Fill{Coin: "SOL", Side: SideBuy, Size: 100.0, Price: 150.0,
+		Time: now + 2, Hash: "copied_open"})
+	bot.process(&Fill{Coin: "SOL", Side: SideSell, Size: 100.0, Price: 155.0,
+		StartPosition: 100.0, ClosedPnl: "500.0", Time: now + 3, Hash: "copied_close"})
+	if pt.Holds("SOL") {
+		t.Errorf("Copied position should be closed, size = %.4f", pt.Positions["SOL"].Size)
+	}
+}
+
+func TestAddsOnlyCopyMode(t *testing.T) {
+	config := createTestConfig()
+	config.Trading.CopyMode = CopyModeAddsOnly
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	bot.paperTrader = pt
+
+	// The target's open is ignored
+	now := time.Now().UnixMilli()
+	open := &Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0, Time: now, Hash: "adds_open"}
+	if ok, reason := bot.wouldCopy(open); ok || !strings.Contains(reason, "adds only") {
+		t.Errorf("wouldCopy(open) = %v, %q; want an adds only rejection", ok, reason)
+	}
+	bot.process(open)
+	if pt.Holds("ETH") {
+		t.Fatalf("Target open was copied: %.4f ETH", pt.Positions["ETH"].Size)
+	}
+
+	// Its add onto the open position is copied
+	bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 2.0, Price: 3050.0,
+		StartPosition: 1.0, Time: now + 1, Hash: "adds_add"})
+	if pos := pt.Positions["ETH"]; pos == nil || pos.Size != 2.0 || pos.AvgEntryPrice != 3050.0 {
+		t.Fatalf("Add should copy 2.0 ETH @ 3050, got %+v", pos)
+	}
+	if trades := pt.GetTotalTrades(); trades != 1 {
+		t.Errorf("Trades = %d, want only the add", trades)
+	}
+
+	// A reversal is copied only as far as the target's flat, its new side
+	// being an open. Sizes copy 1:1, so the 3.0 close takes our 2.0 to -1.0.
+	bot.process(&Fill{Coin: "ETH", Side: SideSell, Size: 5.0, Price: 3100.0,
+		StartPosition: 3.0, Time: now + 2, Hash: "adds_reverse"})
+	if pos := pt.Positions["ETH"]; pos.Size != -1.0 {
+		t.Errorf("Reversal should be trimmed to a 3.0 sell, position %.4f ETH, want -1.0", pos.Size)
+	}
+}
+
+func TestStaleFillsAreNotCopied(t *testing.T) {
+	config := createTestConfig()
+	config.Trading.MaxFillAgeSeconds = 60
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.clock = newFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	bot.paperTrader = pt
+
+	now := bot.clock.Now()
+	ancient := now.Add(-time.Hour).UnixMilli()
+	bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0, Time: ancient, Hash: "ancient"})
+	if pt.Holds("ETH") {
+		t.Errorf("Hour-old fill should not open a position")
+	}
+	if _, seen := bot.processedFills["ancient"]; !seen {
+		t.Errorf("Stale fill should still be marked processed")
+	}
+
+	bot.process(&Fill{Coin: "BTC", Side: SideBuy, Size: 1.0, Price: 50000.0,
+		Time: now.Add(-10 * time.Second).UnixMilli(), Hash: "fresh"})
+	if !pt.Holds("BTC") {
+		t.Fatalf("Fresh fill should be copied")
+	}
+
+	// A stale exit still closes what we hold
+	bot.process(&Fill{Coin: "BTC", Side: SideSell, Size: 1.0, Price: 50500.0,
+		Time: now.Add(-5 * time.Minute).UnixMilli(), Hash: "stale_close"})
+	if pt.Holds("BTC") {
+		t.Errorf("Stale close should still flatten, size = %.4f", pt.Positions["BTC"].Size)
+	}
+}
+
+func TestWouldCopyReasons(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 1000.0
+	config.Trading.Coins = []string{"BTC", "ETH"}
+	config.Trading.CoinThresholds = map[string]float64{"ETH": 5000.0}
+	config.Trading.MaxFillAgeSeconds = 60
+	config.Trading.ClosesNeedCopiedOpen = true
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.clock = newFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.DisableDynamicSize = false
+	pt.Bankroll = 10000.0
+	pt.Leverage = 1.0
+	pt.BaseNotional = 10000.0
+	bot.paperTrader = pt
+
+	now := bot.clock.Now().UnixMilli()
+	tests := []struct {
+		name   string
+		fill   *Fill
+		reason string
+	}{
+		{"copyable", &Fill{Coin: "BTC", Side: SideBuy, Size: 1.0, Price: 50000.0, Time: now}, ""},
+		{"stale", &Fill{Coin: "BTC", Side: SideBuy, Size: 1.0, Price: 50000.0, Time: now - 3600000}, "stale"},
+		{"not whitelisted", &Fill{Coin: "SOL", Side: SideBuy, Size: 100.0, Price: 150.0, Time: now}, "not in trading.coins"},
+		{"uncopied close", &Fill{Coin: "BTC", Side: SideSell, Size: 1.0, Price: 50000.0,
+			StartPosition: 1.0, Time: now}, "never copied"},
+		{"below threshold", &Fill{Coin: "BTC", Side: SideBuy, Size: 0.01, Price: 50000.0, Time: now}, "below the $1000.00 copy threshold"},
+		{"below coin threshold", &Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0, Time: now}, "below the $5000.00 copy threshold"},
+	}
+	for _, tt := range tests {
+		ok, reason := bot.wouldCopy(tt.fill)
+		if ok != (tt.reason == "") || !strings.Contains(reason, tt.reason) {
+			t.Errorf("%s: wouldCopy = %v, %q; want reason containing %q", tt.name, ok, reason, tt.reason)
+		}
+	}
+
+	// Filling the bankroll leaves no capacity for opens, but exits still pass
+	pt.ProcessFill(createTestFill("BTC", "A", 1.0, 50000.0, "0.0", now/1000))
+	if ok, reason := bot.wouldCopy(&Fill{Coin: "ETH", Side: SideBuy, Size: 2.0, Price: 3000.0, Time: now}); ok ||
+		reason != "no capacity left" {
+		t.Errorf("Full book: wouldCopy = %v, %q; want no capacity left", ok, reason)
+	}
+	if ok, reason := bot.wouldCopy(&Fill{Coin: "BTC", Side: SideBuy, Size: 0.1, Price: 50000.0,
+		StartPosition: -1.0, Time: now}); !ok {
+		t.Errorf("Exit should be copyable without capacity: %q", reason)
+	}
+
+	// Depleted capital is reported as reduce-only
+	pt.UpdateMarks(map[string]float64{"BTC": 150000.0})
+	if ok, reason := bot.wouldCopy(&Fill{Coin: "ETH", Side: SideBuy, Size: 2.0, Price: 3000.0, Time: now}); ok ||
+		!strings.Contains(reason, "reduce-only") {
+		t.Errorf("Depleted: wouldCopy = %v, %q; want reduce-only", ok, reason)
+	}
+
+	// Queries change nothing: a reversal keeps its full size
+	reversal := &Fill{Coin: "ETH", Side: SideSell, Size: 3.0, Price: 3000.0, StartPosition: 1.0, Time: now}
+	bot.wouldCopy(reversal)
+	if reversal.Size != 3.0 || len(bot.processedFills) != 0 {
+		t.Errorf("wouldCopy mutated state: size %.2f, %d processed", reversal.Size, len(bot.processedFills))
+	}
+}
+
+func TestMonitorRecoversFromPanic(t *testing.T) {
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.pollInterval = 5 * time.Millisecond
+
+	var polls int64
+	bot.poll = func() {
+		if atomic.AddInt64(&polls, 1) == 2 {
+			var fill *Fill
+			_ = fill.Coin // nil fill, as a parse bug would produce
+		}
+	}
+
+	bot.wg.Add(1)
+	go bot.monitorTrades()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt64(&polls) < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(bot.stopChan)
+	bot.wg.Wait()
+
+	if n := atomic.LoadInt64(&polls); n < 5 {
+		t.Errorf("Polls after panic = %d, want the loop to keep polling", n)
+	}
+	if restarts := atomic.LoadInt64(&bot.monitorPanics); restarts != 1 {
+		t.Errorf("Monitor panics = %d, want 1", restarts)
+	}
+}
+
+func TestMonitorRestartsAreBounded(t *testing.T) {
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.pollInterval = time.Millisecond
+	bot.poll = func() { panic("always broken") }
+
+	done := make(chan struct{})
+	bot.wg.Add(1)
+	go func() {
+		bot.monitorTrades()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Monitor kept restarting past the limit")
+	}
+	if restarts := atomic.LoadInt64(&bot.monitorPanics); restarts != maxMonitorRestarts+1 {
+		t.Errorf("Monitor panics = %d, want %d", restarts, maxMonitorRestarts+1)
+	}
+}
+
+func TestProcessedFillsRetainedPastLookback(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	boundaryFill := &Fill{
+		Coin:      "BTC",
+		Side:      SideBuy,
+		Size:      1.0,
+		Price:     50000.0,
+		Time:      clock.Now().Add(-59 * time.Minute).UnixMilli(),
+		ClosedPnl: "0.0",
+		Hash:      "0xboundary",
+	}
+
+	// The server's clock runs a few minutes behind ours, so it keeps
+	// returning fills slightly older than our lookback start
+	const skew = 5 * 60 * 1000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			StartTime int64 `json:"startTime"`
+			EndTime   int64 `json:"endTime"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		fills := []*Fill{}
+		if boundaryFill.Time >= req.StartTime-skew && boundaryFill.Time <= req.EndTime {
+			copied := *boundaryFill
+			fills = append(fills, &copied)
+		}
+		json.NewEncoder(w).Encode(fills)
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.clock = clock
+	bot.paperTrader = NewTestPaperTrader()
+
+	poll := func() int {
+		if err := bot.checkForNewTrades(); err != nil {
+			t.Fatalf("checkForNewTrades() error = %v", err)
+		}
+		queued := len(bot.fillQueue)
+		for len(bot.fillQueue) > 0 {
+			fill := <-bot.fillQueue
+			bot.mu.Lock()
+			delete(bot.queued, fill.Hash)
+			bot.mu.Unlock()
+			bot.process(fill)
+		}
+		return queued
+	}
+
+	if queued := poll(); queued != 1 {
+		t.Fatalf("First poll queued %d fills, want 1", queued)
+	}
+
+	// Just past the lookback the fill is still returned but must be deduped
+	clock.Advance(3 * time.Minute)
+	if queued := poll(); queued != 0 {
+		t.Errorf("Boundary fill re-queued after lookback, queued %d", queued)
+	}
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 1 {
+		t.Errorf("Boundary fill processed %d times, want 1", trades)
+	}
+
+	// Its hash is only forgotten once well outside the lookback
+	clock.Advance(time.Hour)
+	bot.checkForNewTrades()
+	bot.mu.Lock()
+	_, retained := bot.processedFills[boundaryFill.Hash]
+	bot.mu.Unlock()
+	if retained {
+		t.Errorf("Hash should be cleaned after %v", processedFillRetention)
+	}
+}
+
+func TestProcessedFillTTL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Monitoring.ProcessedFillTTLMinutes = 180
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	clock := newFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	bot.clock = clock
+
+	now := clock.Now()
+	bot.processedFills["0xexpired"] = now.Add(-181 * time.Minute).UnixMilli()
+	bot.processedFills["0xkept"] = now.Add(-179 * time.Minute).UnixMilli()
+	if err := bot.checkForNewTrades(); err != nil {
+		t.Fatalf("checkForNewTrades() error = %v", err)
+	}
+	if _, exists := bot.processedFills["0xexpired"]; exists {
+		t.Errorf("Hash older than the 180m TTL should be purged")
+	}
+	if _, exists := bot.processedFills["0xkept"]; !exists {
+		t.Errorf("Hash within the 180m TTL should survive")
+	}
+
+	// A TTL inside the lookback would let re-polled fills be copied again
+	if _, err := loadConfig(writeTestConfig(t, testConfigBase+"[monitoring]\nprocessed_fill_ttl_minutes = 60\n")); err == nil {
+		t.Errorf("loadConfig() should reject a TTL within the poll lookback")
+	}
+}
+
+func TestNearMissFills(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 1000.0
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = NewTestPaperTrader()
+
+	now := time.Now().UnixMilli()
+	fills := []*Fill{
+		{Coin: "ETH", Side: SideBuy, Size: 0.3, Price: 3000.0, Hash: "near1", Time: now},    // $900
+		{Coin: "ETH", Side: SideBuy, Size: 0.3, Price: 3000.0, Hash: "near1", Time: now},    // same hash, seen again
+		{Coin: "BTC", Side: SideBuy, Size: 0.016, Price: 50000.0, Hash: "near2", Time: now}, // $800, on the band edge
+		{Coin: "BTC", Side: SideBuy, Size: 0.01, Price: 50000.0, Hash: "far", Time: now},    // $500
+	}
+	for _, fill := range fills {
+		if err := bot.process(fill); err != nil {
+			t.Fatalf("process(%s) error = %v", fill.Hash, err)
+		}
+	}
+
+	count, notional := bot.nearMissStats()
+	if count != 2 || math.Abs(notional-1700.0) > 1e-6 {
+		t.Errorf("Near misses = %d, $%.2f; want 2, $1700.00", count, notional)
+	}
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 0 {
+		t.Errorf("Near misses created %d trades", trades)
+	}
+
+	output := captureStdout(t, bot.printSummary)
+	if !strings.Contains(output, "Near misses: 2 fills, $1700.00") {
+		t.Errorf("Summary should report near misses, got:\n%s", output)
+	}
+}
+
+func TestBackfilledPositionUsesLiveMark(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		switch payload["type"] {
+		case "clearinghouseState":
+			fmt.Fprint(w, `{"assetPositions":[`+
+				`{"position":{"coin":"ETH","szi":"12.5","entryPx":"3000.0","leverage":{"type":"cross","value":5}}},`+
+				`{"position":{"coin":"BTC","szi":"-0.5","entryPx":"60000.0","leverage":{"type":"cross","value":5}}}]}`)
+		case "allMids":
+			fmt.Fprint(w, `{"ETH":"3300.0","BTC":"57000.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Trading.BackfillPositions = true
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	if err := bot.backfillPositions(); err != nil {
+		t.Fatalf("backfillPositions() error = %v", err)
+	}
+
+	pt := bot.paperTrader
+	eth, btc := pt.Positions["ETH"], pt.Positions["BTC"]
+	if eth == nil || eth.Size <= 0 || btc == nil || btc.Size >= 0 {
+		t.Fatalf("Backfill should open a long ETH and a short BTC, got %+v %+v", eth, btc)
+	}
+	if eth.AvgEntryPrice != 3000.0 || eth.LastPrice != 3300.0 {
+		t.Errorf("ETH entry %.2f, last %.2f; want the target's entry and the live mark",
+			eth.AvgEntryPrice, eth.LastPrice)
+	}
+	if pnl := pt.calculateUnrealizedPnL(eth); math.Abs(pnl-300.0*eth.Size) > 1e-6 {
+		t.Errorf("ETH unrealized = %.2f, want %.2f", pnl, 300.0*eth.Size)
+	}
+	if pnl := pt.calculateUnrealizedPnL(btc); pnl <= 0 {
+		t.Errorf("Short BTC marked below entry should be in profit, got %.2f", pnl)
+	}
+	if trades := pt.GetTotalTrades(); trades != 0 {
+		t.Errorf("Backfill booked %d trades", trades)
+	}
+}
+
+func TestSuspectPricesAreSkipped(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	config.Trading.MaxPriceDeviationPct = 10.0
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = NewTestPaperTrader()
+
+	now := time.Now().UnixMilli()
+	// No mark yet: nothing to compare against
+	bot.process(&Fill{Coin: "SOL", Side: SideBuy, Size: 10.0, Price: 140.0, Hash: "nomark", Time: now})
+	if !bot.paperTrader.Holds("SOL") {
+		t.Errorf("Fill with no mark to compare should be copied")
+	}
+
+	bot.paperTrader.UpdateMarks(map[string]float64{"ETH": 3000.0})
+	bot.process(&Fill{Coin: "ETH", Side: SideSell, Size: 1.0, Price: 2100.0, Hash: "crash", Time: now})
+	if bot.paperTrader.Holds("ETH") {
+		t.Errorf("Fill 30%% below the mark should be skipped")
+	}
+	if _, processed := bot.processedFills["crash"]; !processed {
+		t.Errorf("Suspect fill should be marked processed, not retried")
+	}
+
+	bot.process(&Fill{Coin: "ETH", Side: SideSell, Size: 1.0, Price: 2850.0, Hash: "dip", Time: now})
+	if !bot.paperTrader.Holds("ETH") {
+		t.Errorf("Fill 5%% from the mark should be copied")
+	}
+}
+
+func TestSetTargetCatchesUp(t *testing.T) {
+	const newTarget = "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd"
+	clock := newFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	now := clock.Now().UnixMilli()
+
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		requests = append(requests, payload)
+		if payload["user"] != newTarget {
+			w.Write([]byte(`[]`))
+			return
+		}
+		// Opened 3 ETH 40 minutes ago, then trimmed 1 twenty minutes later
+		fmt.Fprintf(w, `[
+			{"coin":"ETH","side":"B","sz":"3.0","px":"3000.0","time":%d,"startPosition":"0.0","closedPnl":"0.0","hash":"0xcatch1"},
+			{"coin":"ETH","side":"A","sz":"1.0","px":"3100.0","time":%d,"startPosition":"3.0","closedPnl":"100.0","hash":"0xcatch2"}
+		]`, now-40*60*1000, now-20*60*1000)
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Monitoring.TargetCatchUpMinutes = 12 * 60
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.clock = clock
+	bot.paperTrader = NewTestPaperTrader()
+
+	if err := bot.SetTarget(newTarget); err != nil {
+		t.Fatalf("SetTarget() error = %v", err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("Catch-up made %d requests, want 1", len(requests))
+	}
+	if start := int64(requests[0]["startTime"].(float64)); start != now-12*3600*1000 {
+		t.Errorf("Catch-up start = %d, want %d (12h back)", start, now-12*3600*1000)
+	}
+	if got := bot.TargetPositions()["ETH"]; got != 2.0 {
+		t.Errorf("Target ETH position = %.2f, want 2.00", got)
+	}
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 0 {
+		t.Errorf("Catch-up fills were copied: %d trades", trades)
+	}
+	for _, hash := range []string{"0xcatch1", "0xcatch2"} {
+		if _, processed := bot.processedFills[hash]; !processed {
+			t.Errorf("Catch-up fill %s should be marked processed", hash)
+		}
+	}
+
+	// Later polls follow the new target
+	if err := bot.checkForNewTrades(); err != nil {
+		t.Fatalf("checkForNewTrades() error = %v", err)
+	}
+	if user := requests[len(requests)-1]["user"]; user != newTarget {
+		t.Errorf("Poll after switch fetched %v, want %s", user, newTarget)
+	}
+	if len(bot.fillQueue) != 0 {
+		t.Errorf("Caught-up fills should not be queued again, got %d", len(bot.fillQueue))
+	}
+}
+
+// captureLog returns what f logs
+func captureLog(t *testing.T, f func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	f()
+	return buf.String()
+}
+
+func TestRepeatedHashWithDifferentData(t *testing.T) {
+	size := "1.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"coin":"BTC","side":"B","sz":"%s","px":"50000.0","time":%d,"hash":"0xglitch"}]`,
+			size, time.Now().UnixMilli())
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.paperTrader = NewTestPaperTrader()
+
+	poll := func() string {
+		return captureLog(t, func() {
+			if err := bot.checkForNewTrades(); err != nil {
+				t.Fatalf("checkForNewTrades() error = %v", err)
+			}
+			for len(bot.fillQueue) > 0 {
+				fill := <-bot.fillQueue
+				bot.mu.Lock()
+				delete(bot.queued, fill.Hash)
+				bot.mu.Unlock()
+				bot.process(fill)
+			}
+		})
+	}
+
+	if output := poll(); strings.Contains(output, "different data") {
+		t.Errorf("First sighting should not warn, got:\n%s", output)
+	}
+	if output := poll(); strings.Contains(output, "different data") {
+		t.Errorf("An identical repeat should not warn, got:\n%s", output)
+	}
+
+	size = "2.5"
+	output := poll()
+	if !strings.Contains(output, "fill 0xglitch repeated with different data") {
+		t.Errorf("Altered repeat should warn, got:\n%s", output)
+	}
+	if pos := bot.paperTrader.Positions["BTC"]; pos == nil || pos.Size != 1.0 {
+		t.Errorf("Altered repeat should not be reprocessed, position %+v", pos)
+	}
+
+	// Warned once per hash, not on every poll
+	if output := poll(); strings.Contains(output, "different data") {
+		t.Errorf("Mismatch should warn only once, got:\n%s", output)
+	}
+}
+
+func TestPollDeadline(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		json.NewDecoder(r.Body).Decode(&struct{}{}) // lets the server notice the client hang up
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+		http.Error(w, "slow", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Monitoring.PollDeadlineSeconds = 1
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	start := time.Now()
+	err = bot.checkTrades()
+	elapsed := time.Since(start)
+	if err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("checkTrades() error = %v, want a deadline error", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("Poll took %v, want it cut off at the 1s deadline", elapsed)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Made %d requests, want no retry past the deadline", n)
+	}
+}
+
+func TestMinRequestInterval(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		w.Write([]byte(`{"BTC":"50000"}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Monitoring.MinRequestIntervalMs = 50
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	// Cycles queued behind an outage all fire the moment it clears
+	const queued = 6
+	var wg sync.WaitGroup
+	for i := 0; i < queued; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := bot.api().GetAllMids(); err != nil {
+				t.Errorf("GetAllMids() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(arrivals) != queued {
+		t.Fatalf("Server saw %d requests, want %d", len(arrivals), queued)
+	}
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].Before(arrivals[j]) })
+	for i := 1; i < len(arrivals); i++ {
+		// A little slack for scheduling between the gate and the server
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Request %d came %v after the previous, want at least 50ms", i, gap)
+		}
+	}
+}
+
+func TestRequestIntervalCancelledWaiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Monitoring.MinRequestIntervalMs = 300
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	client := bot.api()
+
+	if _, err := client.GetUserFillsByTimeContext(context.Background(), "0xtarget", 0, 1); err != nil {
+		t.Fatalf("First request error = %v", err)
+	}
+
+	// A waiter cancelled before its turn gives its slot to the next caller
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := client.GetUserFillsByTimeContext(ctx, "0xtarget", 0, 1)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatalf("Cancelled waiter returned no error")
+	}
+
+	start := time.Now()
+	if _, err := client.GetUserFillsByTimeContext(context.Background(), "0xtarget", 0, 1); err != nil {
+		t.Fatalf("Next request error = %v", err)
+	}
+	if waited := time.Since(start); waited > 400*time.Millisecond {
+		t.Errorf("Next request waited %v, want the cancelled slot (under 300ms) not the one after", waited)
+	}
+
+	// A waiter doesn't hold the in-flight lock, so Close doesn't stall
+	// behind it, and the waiter then fails rather than sending
+	go func() {
+		_, err := client.GetUserFillsByTimeContext(context.Background(), "0xtarget", 0, 1)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		client.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(150 * time.Millisecond):
+		t.Errorf("Close blocked behind a request waiting its turn")
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), errClientClosed.Error()) {
+		t.Errorf("Waiter after Close error = %v, want %v", err, errClientClosed)
+	}
+}
+
+func TestRejectedFillsNotRetried(t *testing.T) {
+	config := createTestConfig()
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = NewTestPaperTrader()
+
+	// A small sell rejected before we hold ETH must not close a later copy
+	now := time.Now().UnixMilli()
+	small := &Fill{Coin: "ETH", Side: SideSell, Size: 0.1, Price: 3000.0, Hash: "small_sell", Time: now}
+	if err := bot.process(small); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+	if _, exists := bot.processedFills[small.DedupKey()]; !exists {
+		t.Errorf("Rejected fill should be marked processed")
+	}
+	if err := bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 1.0, Price: 3000.0, Hash: "open", Time: now + 1}); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+
+	again := *small
+	if err := bot.process(&again); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+	if size := bot.paperTrader.Positions["ETH"].Size; size != 1.0 {
+		t.Errorf("ETH size = %.4f, want 1: the stale sell was copied late", size)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultNATSSubject = "hype-copy-bot.trades"
+	natsDialTimeout    = 5 * time.Second
+	natsWriteTimeout   = 5 * time.Second
+	publishQueueSize   = 256
+)
+
+// Publisher delivers a message to a subject on a message bus
+type Publisher interface {
+	Publish(subject string, data []byte) error
+	Close() error
+}
+
+// natsPublisher speaks just enough of the NATS client protocol to publish:
+// CONNECT, PUB and answering the server's PINGs. It connects lazily and
+// reconnects on the next publish after a failure.
+type natsPublisher struct {
+	addr       string
+	user, pass string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newNATSPublisher parses a nats://[user:pass@]host[:port] URL
+func newNATSPublisher(rawURL string) (*natsPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats url: %w", err)
+	}
+	if u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid nats url %q: want nats://host[:port]", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "4222"
+	}
+	p := &natsPublisher{addr: net.JoinHostPort(u.Hostname(), port)}
+	if u.User != nil {
+		p.user = u.User.Username()
+		p.pass, _ = u.User.Password()
+	}
+	return p, nil
+}
+
+func (p *natsPublisher) Publish(subject string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		return fmt.Errorf("nats publish: %w", err)
+	}
+	return nil
+}
+
+// connect dials the server, reads its INFO and sends CONNECT. Caller must
+// hold p.mu.
+func (p *natsPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.addr, natsDialTimeout)
+	if err != nil {
+		return fmt.Errorf("nats connect: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(natsDialTimeout))
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO") {
+		conn.Close()
+		return fmt.Errorf("nats connect: no INFO from %s", p.addr)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "hype-copy-bot"}
+	if p.user != "" {
+		options["user"] = p.user
+		options["pass"] = p.pass
+	}
+	connect, _ := json.Marshal(options)
+	conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", connect); err != nil {
+		conn.Close()
+		return fmt.Errorf("nats connect: %w", err)
+	}
+	p.conn = conn
+	go p.readLoop(conn, reader)
+	return nil
+}
+
+// readLoop answers PINGs so the server keeps us connected and logs errors
+func (p *natsPublisher) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			p.mu.Lock()
+			if p.conn == conn {
+				p.conn.Close()
+				p.conn = nil
+			}
+			p.mu.Unlock()
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			p.mu.Lock()
+			conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+			conn.Write([]byte("PONG\r\n"))
+			p.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			log.Printf("Error from nats: %s", strings.TrimSpace(line))
+		}
+	}
+}
+
+func (p *natsPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// tradeMessage is a PaperTrade as published on the bus
+type tradeMessage struct {
+	Time          int64   `json:"time"` // millis
+	Coin          string  `json:"coin"`
+	Action        string  `json:"action"`
+	Side          string  `json:"side"`
+	Size          float64 `json:"size"`
+	Price         float64 `json:"price"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	PositionSize  float64 `json:"position_size"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	Fee           float64 `json:"fee"`
+}
+
+func newTradeMessage(trade *PaperTrade) tradeMessage {
+	return tradeMessage{
+		Time:          trade.Timestamp.UnixMilli(),
+		Coin:          trade.Coin,
+		Action:        trade.Action,
+		Side:          trade.Side,
+		Size:          trade.Size,
+		Price:         trade.Price,
+		RealizedPnL:   trade.RealizedPnL,
+		PositionSize:  trade.PositionSize,
+		UnrealizedPnL: trade.UnrealizedPnL,
+		Fee:           trade.Fee,
+	}
+}
+
+// tradePublisher publishes each trade as JSON off the trading path: OnTrade
+// only queues, and a full queue or failed publish drops the message
+type tradePublisher struct {
+	publisher Publisher
+	subject   string
+	queue     chan []byte
+}
+
+func newTradePublisher(publisher Publisher, subject string) *tradePublisher {
+	if subject == "" {
+		subject = defaultNATSSubject
+	}
+	return &tradePublisher{
+		publisher: publisher,
+		subject:   subject,
+		queue:     make(chan []byte, publishQueueSize),
+	}
+}
+
+// OnTrade is chained onto the paper trader's trade callback
+func (t *tradePublisher) OnTrade(trade *PaperTrade) {
+	data, err := json.Marshal(newTradeMessage(trade))
+	if err != nil {
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		log.Printf("Skipping trade publish for %s: queue full", trade.Coin)
+	}
+}
+
+// run publishes queued trades until stop closes, then drains the queue
+func (t *tradePublisher) run(stop <-chan struct{}) {
+	defer t.publisher.Close()
+	for {
+		select {
+		case data := <-t.queue:
+			t.publish(data)
+		case <-stop:
+			for {
+				select {
+				case data := <-t.queue:
+					t.publish(data)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *tradePublisher) publish(data []byte) {
+	// Publish failures must never affect trading
+	if err := t.publisher.Publish(t.subject, data); err != nil {
+		log.Printf("Error publishing trade: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockNATSServer accepts one client and sends each PUB it receives
+func mockNATSServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	published := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"mock\"}\r\n")
+
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var subject string
+			var size int
+			if _, err := fmt.Sscanf(line, "PUB %s %d", &subject, &size); err != nil {
+				continue // CONNECT, PONG
+			}
+			payload := make([]byte, size+2) // trailing \r\n
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			published <- subject + " " + string(payload[:size])
+		}
+	}()
+	return "nats://" + listener.Addr().String(), published
+}
+
+func TestTradesPublishedToNATS(t *testing.T) {
+	natsURL, published := mockNATSServer(t)
+
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	config.Notifications.NATSURL = natsURL
+	config.Notifications.NATSSubject = "copy.trades"
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	pt.OnTrade = bot.paperTrader.OnTrade
+	bot.paperTrader = pt
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		bot.publisher.run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 2.0, Price: 3000.0, Hash: "nats1", Time: time.Now().UnixMilli()})
+
+	var message string
+	select {
+	case message = <-published:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("No trade published")
+	}
+
+	subject, payload, _ := strings.Cut(message, " ")
+	if subject != "copy.trades" {
+		t.Errorf("Published to %q, want copy.trades", subject)
+	}
+	var got tradeMessage
+	if err := json.Unmarshal([]byte(payload), &got); err != nil {
+		t.Fatalf("Published payload %q is not JSON: %v", payload, err)
+	}
+	trade := pt.TradeHistory[0]
+	if want := newTradeMessage(trade); got != want {
+		t.Errorf("Published %+v, want %+v", got, want)
+	}
+	if got.Coin != "ETH" || got.Action != "OPEN" || got.Size != 2.0 || got.Price != 3000.0 {
+		t.Errorf("Published trade %+v does not match the ETH open", got)
+	}
+}
+
+func TestNATSPublishFailureDoesNotBlockTrading(t *testing.T) {
+	// Nothing listens here: every publish fails
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+	config.Notifications.NATSURL = "nats://" + addr
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := NewTestPaperTrader()
+	pt.OnTrade = bot.paperTrader.OnTrade
+	bot.paperTrader = pt
+
+	stop := make(chan struct{})
+	go bot.publisher.run(stop)
+	defer close(stop)
+
+	bot.process(&Fill{Coin: "ETH", Side: SideBuy, Size: 2.0, Price: 3000.0, Hash: "nats2", Time: time.Now().UnixMilli()})
+	if !pt.Holds("ETH") {
+		t.Errorf("Trade should be booked even when publishing fails")
+	}
+}
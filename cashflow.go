@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidAmount      = errors.New("amount must be positive")
+	ErrInsufficientMargin = errors.New("withdrawal would leave open positions under-margined")
+)
+
+// Cashflow is a deposit (positive) or withdrawal (negative) of capital
+type Cashflow struct {
+	Time   time.Time
+	Amount float64
+}
+
+// Deposit adds capital to the bankroll
+func (pt *PaperTrader) Deposit(amount float64) error {
+	if !(amount > 0) || math.IsInf(amount, 0) {
+		return ErrInvalidAmount
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.recordCashflow(amount)
+	log.Printf("cashflow: deposit $%.2f, bankroll $%.2f", amount, pt.Bankroll)
+	return nil
+}
+
+// Withdraw removes capital from the bankroll. It is rejected when the
+// remaining capital could no longer margin open positions at the
+// configured leverage.
+func (pt *PaperTrader) Withdraw(amount float64) error {
+	if !(amount > 0) || math.IsInf(amount, 0) {
+		return ErrInvalidAmount
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	openNotional := 0.0
+	for _, pos := range pt.Positions {
+		if pos.Size != 0 {
+			openNotional += math.Abs(pos.Size*pos.LastPrice) * pt.quoteRate()
+		}
+	}
+	leverage := pt.Leverage
+	if leverage <= 0 {
+		leverage = 1.0
+	}
+
+	remaining := pt.calculateAvailableCapital() - amount
+	if required := openNotional / leverage; remaining < required {
+		return fmt.Errorf("%w: $%.2f left, $%.2f required", ErrInsufficientMargin, remaining, required)
+	}
+
+	pt.recordCashflow(-amount)
+	log.Printf("cashflow: withdraw $%.2f, bankroll $%.2f", amount, pt.Bankroll)
+	return nil
+}
+
+// recordCashflow applies a signed amount. Caller must hold pt.mu.
+func (pt *PaperTrader) recordCashflow(amount float64) {
+	pt.Bankroll += amount
+	pt.Cashflows = append(pt.Cashflows, Cashflow{Time: pt.now(), Amount: amount})
+	pt.SaveAccount()
+}
+
+// NetCashflow returns total deposits minus withdrawals
+func (pt *PaperTrader) NetCashflow() float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	net := 0.0
+	for _, flow := range pt.Cashflows {
+		net += flow.Amount
+	}
+	return net
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -180,3 +181,60 @@ func TestRealisticMarketPriceScenario(t *testing.T) {
 	fmt.Println("LastPrice should represent current market price, not your fill price")
 	fmt.Println("Unrealized P&L = (Current Market Price - VWAP) × Position Size")
 }
+
+func TestDepositIncreasesCapacity(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.DisableDynamicSize = false
+	pt.Bankroll = 1000.0
+	pt.BaseNotional = 1000.0
+
+	now := time.Now().Unix()
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 2000.0, "0.0", now+1))
+	if _, exists := pt.Positions["ETH"]; exists && pt.Positions["ETH"].Size != 0 {
+		t.Fatalf("ETH should be skipped with the bankroll fully used")
+	}
+
+	if err := pt.Deposit(1000.0); err != nil {
+		t.Fatalf("Deposit() error = %v", err)
+	}
+	if pt.Bankroll != 2000.0 || pt.NetCashflow() != 1000.0 {
+		t.Errorf("After deposit bankroll = %.2f net cashflow = %.2f, want 2000 and 1000",
+			pt.Bankroll, pt.NetCashflow())
+	}
+
+	eth := createTestFill("ETH", "B", 1.0, 2000.0, "0.0", now+2)
+	eth.Hash = "eth_after_deposit"
+	pt.ProcessFill(eth)
+	if pos := pt.Positions["ETH"]; pos == nil || pos.Size != 0.5 {
+		t.Errorf("ETH after deposit should copy $1000 = 0.5 ETH, got %+v", pos)
+	}
+
+	if err := pt.Deposit(-5); err == nil {
+		t.Errorf("Deposit() should reject a negative amount")
+	}
+}
+
+func TestWithdrawalRejectedForMargin(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.Bankroll = 1000.0
+	pt.Leverage = 2.0
+	pt.Positions["BTC"] = &Position{Coin: "BTC", Size: 0.03, AvgEntryPrice: 50000, LastPrice: 50000}
+
+	// $1500 open at 2x needs $750 of capital
+	err := pt.Withdraw(300.0)
+	if !errors.Is(err, ErrInsufficientMargin) {
+		t.Fatalf("Withdraw(300) error = %v, want ErrInsufficientMargin", err)
+	}
+	if pt.Bankroll != 1000.0 || len(pt.Cashflows) != 0 {
+		t.Errorf("Rejected withdrawal changed bankroll to %.2f", pt.Bankroll)
+	}
+
+	if err := pt.Withdraw(200.0); err != nil {
+		t.Fatalf("Withdraw(200) error = %v", err)
+	}
+	if pt.Bankroll != 800.0 || pt.NetCashflow() != -200.0 {
+		t.Errorf("After withdrawal bankroll = %.2f net = %.2f, want 800 and -200",
+			pt.Bankroll, pt.NetCashflow())
+	}
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/ed25519"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -17,11 +21,21 @@ type Client struct {
 	baseURL    string
 	privateKey ed25519.PrivateKey
 	publicKey  ed25519.PublicKey
+
+	// Held for reading by every request so Close can drain them
+	inflight sync.RWMutex
+	closed   bool // set by Close, under inflight; later requests fail
+
+	gateMu   sync.Mutex
+	nextCall time.Time // earliest start of the next request under the min interval
+
+	assetsMu sync.Mutex
+	assets   map[string]int // perp universe index per coin, read from meta once
 }
 
 type Fill struct {
 	Coin          string  `json:"coin"`
-	Side          string  `json:"side"`
+	Side          Side    `json:"side"`
 	Size          float64 `json:"sz,string"`
 	Price         float64 `json:"px,string"`
 	Time          int64   `json:"time"`
@@ -32,11 +46,31 @@ type Fill struct {
 	Oid           int64   `json:"oid"`
 	Crossed       bool    `json:"crossed"`
 	Fee           string  `json:"fee"`
+	FeeToken      string  `json:"feeToken"` // token Fee is in, USDC for most perps
+
+	dedupKey string // cached by DedupKey
+}
+
+// ClearinghouseState is the subset of the clearinghouseState response we use
+type ClearinghouseState struct {
+	AssetPositions []struct {
+		Position TargetPosition `json:"position"`
+	} `json:"assetPositions"`
+}
+
+type TargetPosition struct {
+	Coin     string  `json:"coin"`
+	Size     float64 `json:"szi,string"`
+	EntryPx  float64 `json:"entryPx,string"`
+	Leverage struct {
+		Type  string  `json:"type"`
+		Value float64 `json:"value"`
+	} `json:"leverage"`
 }
 
 type Order struct {
 	Coin  string  `json:"coin"`
-	Side  string  `json:"side"`
+	Side  Side    `json:"side"`
 	Size  float64 `json:"sz"`
 	Price float64 `json:"px"`
 	Type  string  `json:"orderType"`
@@ -50,7 +84,16 @@ func NewClient(config *Config) (*Client, error) {
 		return nil, fmt.Errorf("invalid private key (must be 64 character hex string): %v", err)
 	}
 
+	// 64 hex characters are a seed; a full expanded key is also accepted
 	privateKey := ed25519.PrivateKey(privateKeyBytes)
+	switch len(privateKeyBytes) {
+	case ed25519.SeedSize:
+		privateKey = ed25519.NewKeyFromSeed(privateKeyBytes)
+	case ed25519.PrivateKeySize:
+	default:
+		return nil, fmt.Errorf("invalid private key (must be 64 character hex string): got %d bytes",
+			len(privateKeyBytes))
+	}
 	publicKey := privateKey.Public().(ed25519.PublicKey)
 
 	return &Client{
@@ -62,6 +105,12 @@ func NewClient(config *Config) (*Client, error) {
 	}, nil
 }
 
+// SignerPublicKey returns the hex public key exchange requests are signed
+// with
+func (c *Client) SignerPublicKey() string {
+	return hex.EncodeToString(c.publicKey)
+}
+
 func (c *Client) GetUserFills(user string) ([]*Fill, error) {
 	payload := map[string]interface{}{
 		"type": "userFills",
@@ -73,8 +122,8 @@ func (c *Client) GetUserFills(user string) ([]*Fill, error) {
 		return nil, fmt.Errorf("failed to get user fills for %s: %v", user, err)
 	}
 
-	var fills []*Fill
-	if err := json.Unmarshal(resp, &fills); err != nil {
+	fills, err := decodeFills(resp)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal fills response: %v", err)
 	}
 
@@ -84,6 +133,11 @@ func (c *Client) GetUserFills(user string) ([]*Fill, error) {
 // GetUserFillsByTime retrieves user fills within a specific time range
 // startTime and endTime are Unix timestamps in milliseconds
 func (c *Client) GetUserFillsByTime(user string, startTime, endTime int64) ([]*Fill, error) {
+	return c.GetUserFillsByTimeContext(context.Background(), user, startTime, endTime)
+}
+
+// GetUserFillsByTimeContext is GetUserFillsByTime, abandoned once ctx is done
+func (c *Client) GetUserFillsByTimeContext(ctx context.Context, user string, startTime, endTime int64) ([]*Fill, error) {
 	payload := map[string]interface{}{
 		"type":      "userFillsByTime",
 		"user":      user,
@@ -91,26 +145,199 @@ func (c *Client) GetUserFillsByTime(user string, startTime, endTime int64) ([]*F
 		"endTime":   endTime,
 	}
 
-	resp, err := c.makeInfoRequest(payload)
+	resp, err := c.makeRequest(ctx, "/info", payload, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user fills by time for %s: %v", user, err)
 	}
 
-	var fills []*Fill
-	if err := json.Unmarshal(resp, &fills); err != nil {
+	fills, err := decodeFills(resp)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal fills response: %v", err)
 	}
 
 	return fills, nil
 }
 
+// fillsWrapperKeys are the fields an object-shaped fills response may carry
+// its fills under, in order of preference
+var fillsWrapperKeys = []string{"fills", "userFills", "data"}
+
+// decodeFills decodes a fills response that is either a bare array or an
+// object wrapping the array
+func decodeFills(resp []byte) ([]*Fill, error) {
+	var fills []*Fill
+	trimmed := bytes.TrimSpace(resp)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		err := json.Unmarshal(resp, &fills)
+		return fills, err
+	}
+
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+		return nil, err
+	}
+	for _, key := range fillsWrapperKeys {
+		if raw, ok := wrapper[key]; ok {
+			if err := json.Unmarshal(raw, &fills); err != nil {
+				return nil, fmt.Errorf("field %q: %w", key, err)
+			}
+			return fills, nil
+		}
+	}
+	return nil, fmt.Errorf("object response has none of %v", fillsWrapperKeys)
+}
+
+// GetAllMids retrieves current mid prices for all coins
+func (c *Client) GetAllMids() (map[string]float64, error) {
+	payload := map[string]interface{}{
+		"type": "allMids",
+	}
+
+	resp, err := c.makeInfoRequest(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mids: %v", err)
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(resp, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mids response: %v", err)
+	}
+
+	mids := make(map[string]float64, len(raw))
+	for coin, px := range raw {
+		if price, err := strconv.ParseFloat(px, 64); err == nil {
+			mids[coin] = price
+		}
+	}
+
+	return mids, nil
+}
+
+// GetFundingRates retrieves each perp's current hourly funding rate
+func (c *Client) GetFundingRates() (map[string]float64, error) {
+	payload := map[string]interface{}{
+		"type": "metaAndAssetCtxs",
+	}
+
+	resp, err := c.makeInfoRequest(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funding rates: %v", err)
+	}
+
+	// [meta, asset contexts], with contexts in the meta's universe order
+	var raw []json.RawMessage
+	var meta struct {
+		Universe []struct {
+			Name string `json:"name"`
+		} `json:"universe"`
+	}
+	var contexts []struct {
+		Funding string `json:"funding"`
+	}
+	if err := json.Unmarshal(resp, &raw); err != nil || len(raw) != 2 {
+		return nil, fmt.Errorf("failed to unmarshal funding response: expected [meta, contexts]")
+	}
+	if err := json.Unmarshal(raw[0], &meta); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal funding meta: %v", err)
+	}
+	if err := json.Unmarshal(raw[1], &contexts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal funding contexts: %v", err)
+	}
+
+	rates := make(map[string]float64, len(contexts))
+	for i, ctx := range contexts {
+		if i >= len(meta.Universe) {
+			break
+		}
+		if rate, err := strconv.ParseFloat(ctx.Funding, 64); err == nil {
+			rates[meta.Universe[i].Name] = rate
+		}
+	}
+	return rates, nil
+}
+
+// GetClearinghouseState retrieves a user's open positions and margin state
+func (c *Client) GetClearinghouseState(user string) (*ClearinghouseState, error) {
+	payload := map[string]interface{}{
+		"type": "clearinghouseState",
+		"user": user,
+	}
+
+	resp, err := c.makeInfoRequest(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clearinghouse state for %s: %v", user, err)
+	}
+
+	var state ClearinghouseState
+	if err := json.Unmarshal(resp, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal clearinghouse state: %v", err)
+	}
+
+	return &state, nil
+}
+
+// assetIndex returns coin's index in the perp universe, which exchange
+// actions name assets by. The universe is read on first use and again when
+// a coin is missing from it, e.g. after a new listing.
+func (c *Client) assetIndex(coin string) (int, error) {
+	c.assetsMu.Lock()
+	defer c.assetsMu.Unlock()
+
+	if index, exists := c.assets[coin]; exists {
+		return index, nil
+	}
+	resp, err := c.makeInfoRequest(map[string]interface{}{"type": "meta"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get asset meta: %v", err)
+	}
+	var meta struct {
+		Universe []struct {
+			Name string `json:"name"`
+		} `json:"universe"`
+	}
+	if err := json.Unmarshal(resp, &meta); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal asset meta: %v", err)
+	}
+	c.assets = make(map[string]int, len(meta.Universe))
+	for i, asset := range meta.Universe {
+		c.assets[asset.Name] = i
+	}
+
+	index, exists := c.assets[coin]
+	if !exists {
+		return 0, fmt.Errorf("unknown asset %s", coin)
+	}
+	return index, nil
+}
+
+// UpdateLeverage sets the leverage used for a coin on our account
+func (c *Client) UpdateLeverage(coin string, leverage float64, isCross bool) error {
+	asset, err := c.assetIndex(coin)
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"type":     "updateLeverage",
+		"asset":    asset,
+		"isCross":  isCross,
+		"leverage": int(leverage),
+	}
+
+	_, err = c.makeExchangeRequest(payload)
+	return err
+}
+
 func (c *Client) PlaceOrder(order *Order) error {
+	asset, err := c.assetIndex(order.Coin)
+	if err != nil {
+		return err
+	}
 	payload := map[string]interface{}{
 		"type": "order",
 		"orders": []map[string]interface{}{
 			{
-				"a": order.Coin,
-				"b": order.Side == "buy",
+				"a": asset,
+				"b": order.Side.IsBuy(),
 				"p": fmt.Sprintf("%.6f", order.Price),
 				"s": fmt.Sprintf("%.6f", order.Size),
 				"r": false,
@@ -120,38 +347,55 @@ func (c *Client) PlaceOrder(order *Order) error {
 		"grouping": "na",
 	}
 
-	_, err := c.makeExchangeRequest(payload)
+	_, err = c.makeExchangeRequest(payload)
 	return err
 }
 
 func (c *Client) makeInfoRequest(payload map[string]interface{}) ([]byte, error) {
-	return c.makeRequest("/info", payload, false)
+	return c.makeRequest(context.Background(), "/info", payload, false)
 }
 
+// makeExchangeRequest sends an action, on behalf of the configured
+// sub-account when there is one
 func (c *Client) makeExchangeRequest(payload map[string]interface{}) ([]byte, error) {
-	return c.makeRequest("/exchange", payload, true)
+	if c.config.SubAccount != "" {
+		payload["vaultAddress"] = c.config.SubAccount
+	}
+	return c.makeRequest(context.Background(), "/exchange", payload, true)
 }
 
 func (c *Client) makeRequest(
+	ctx context.Context,
 	endpoint string,
 	payload map[string]interface{},
 	needsAuth bool,
 ) ([]byte, error) {
+	// Wait outside the in-flight lock so Close isn't held up by a queue
+	if err := c.waitTurn(ctx); err != nil {
+		return nil, err
+	}
+
+	c.inflight.RLock()
+	defer c.inflight.RUnlock()
+	if c.closed {
+		return nil, errClientClosed
+	}
+
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL+endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	if needsAuth {
-		// For now, skip signing - we'll implement this when we test
-		// In a real implementation, you'd need to sign the payload with the private key
+	if needsAuth && len(c.privateKey) == ed25519.PrivateKeySize {
+		req.Header.Set("X-Signer", c.SignerPublicKey())
+		req.Header.Set("X-Signature", hex.EncodeToString(ed25519.Sign(c.privateKey, jsonData)))
 	}
 
 	resp, err := c.httpClient.Do(req)
@@ -172,6 +416,53 @@ func (c *Client) makeRequest(
 	return body, nil
 }
 
+// waitTurn blocks until the request may start, at least
+// monitoring.min_request_interval_ms after the previous one. Each caller
+// reserves the next slot, so however many are waiting they go one per
+// interval rather than in a burst. A caller cancelled while waiting gives
+// its slot back when no one has reserved after it.
+func (c *Client) waitTurn(ctx context.Context) error {
+	interval := time.Duration(c.config.Monitoring.MinRequestIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		return nil
+	}
+
+	c.gateMu.Lock()
+	start := time.Now()
+	if c.nextCall.After(start) {
+		start = c.nextCall
+	}
+	c.nextCall = start.Add(interval)
+	c.gateMu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		c.gateMu.Lock()
+		if c.nextCall.Equal(start.Add(interval)) {
+			c.nextCall = start
+		}
+		c.gateMu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// errClientClosed fails requests on a client replaced by rotation, so
+// nothing is signed with the old key once it is swapped out
+var errClientClosed = errors.New("client closed")
+
+// Close waits for in-flight requests to finish, fails any made after it
+// and releases idle connections
 func (c *Client) Close() {
-	// Cleanup if needed
+	c.inflight.Lock()
+	defer c.inflight.Unlock()
+	c.closed = true
+	c.httpClient.CloseIdleConnections()
 }
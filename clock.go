@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock abstracts wall-clock time so time-dependent logic can be tested
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SimOptions routes a trader's or bot's time and randomness through
+// injectable sources, so the same options always reproduce the same run
+type SimOptions struct {
+	Clock Clock      // system clock when nil
+	Rand  *rand.Rand // seeded from the clock when nil
+}
+
+// NewSimOptions returns options for a deterministic run: a replay clock
+// stopped at start and a rand source seeded with seed
+func NewSimOptions(seed int64, start time.Time) SimOptions {
+	return SimOptions{
+		Clock: NewReplayClock(start),
+		Rand:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// resolveSimOptions returns the first of opts with defaults filled in
+func resolveSimOptions(opts []SimOptions) SimOptions {
+	var sim SimOptions
+	if len(opts) > 0 {
+		sim = opts[0]
+	}
+	if sim.Clock == nil {
+		sim.Clock = systemClock{}
+	}
+	if sim.Rand == nil {
+		sim.Rand = rand.New(rand.NewSource(sim.Clock.Now().UnixNano()))
+	}
+	return sim
+}
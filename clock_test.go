@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock for tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waits   []time.Duration
+	pending []fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.pending = append(c.pending, fakeTimer{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires any expired timers
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	remaining := c.pending[:0]
+	for _, timer := range c.pending {
+		if !timer.deadline.After(c.now) {
+			timer.ch <- c.now
+		} else {
+			remaining = append(remaining, timer)
+		}
+	}
+	c.pending = remaining
+}
+
+// Waits returns the durations passed to After so far
+func (c *fakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// NextTimer returns how far the earliest pending timer is from firing
+func (c *fakeClock) NextTimer() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return 0, false
+	}
+	next := c.pending[0].deadline
+	for _, timer := range c.pending[1:] {
+		if timer.deadline.Before(next) {
+			next = timer.deadline
+		}
+	}
+	return next.Sub(c.now), true
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := newFakeClock(start)
+
+	ch := clock.After(5 * time.Second)
+	clock.Advance(4 * time.Second)
+	select {
+	case <-ch:
+		t.Fatalf("Timer fired before deadline")
+	default:
+	}
+
+	clock.Advance(1 * time.Second)
+	select {
+	case fired := <-ch:
+		if !fired.Equal(start.Add(5 * time.Second)) {
+			t.Errorf("Timer fired at %v, want %v", fired, start.Add(5*time.Second))
+		}
+	default:
+		t.Fatalf("Timer did not fire at deadline")
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -14,12 +21,341 @@ type Config struct {
 	TargetAccount    string  `toml:"target_account"`
 	APIKey           string  `toml:"api_key"`
 	PrivateKey       string  `toml:"private_key"`
+	AccountAddress   string  `toml:"account_address"` // ours, polled for real fills
+	SubAccount       string  `toml:"sub_account"`     // ours to copy into, empty = the main account
 	CopyThreshold    float64 `toml:"copy_threshold"`
+	CopyAll          bool    `toml:"copy_all"` // copy every fill: threshold 0, which otherwise means the default
 	PaperTradingOnly bool    `toml:"paper_trading_only"`
+	Mode             string  `toml:"mode"` // "copy" (default) or "observe": track the target, copy nothing
 	DataDir          string  `toml:"data_dir"`
 	Bankroll         float64 `toml:"bankroll"`
 	Leverage         float64 `toml:"leverage"`
 	BaseNotional     float64 `toml:"base_notional"`
+
+	Monitoring MonitoringConfig `toml:"monitoring"`
+	Trading    TradingConfig    `toml:"trading"`
+
+	Notifications NotificationConfig `toml:"notifications"`
+	Storage       StorageConfig      `toml:"storage"`
+	Logging       LoggingConfig      `toml:"logging"`
+}
+
+// Bot modes
+const (
+	ModeCopy    = "copy"
+	ModeObserve = "observe"
+)
+
+// Copy modes
+const (
+	CopyModeAll      = "all"
+	CopyModeAddsOnly = "adds_only"
+)
+
+// LoggingConfig holds log output settings
+type LoggingConfig struct {
+	// Log to this file instead of stdout, rotating it by size (empty = stdout)
+	File       string `toml:"file"`
+	MaxSizeMB  int    `toml:"max_size_mb"`  // rotate beyond this size (default 100)
+	MaxBackups int    `toml:"max_backups"`  // rotated files kept (0 = all)
+	MaxAgeDays int    `toml:"max_age_days"` // delete rotated files older (0 = never)
+
+	// Log diagnostics such as near-miss fills just below the copy threshold
+	Debug bool `toml:"debug"`
+}
+
+// StorageConfig holds fills and accounts file settings
+type StorageConfig struct {
+	// Write account snapshots as diffs with a full snapshot every N records
+	// (0 = always full)
+	FullSnapshotEvery int `toml:"full_snapshot_every"`
+
+	// Round saved floats to this many decimals; in-memory math keeps full
+	// precision (default 8)
+	Decimals int `toml:"decimals"`
+
+	// Write a session report at shutdown: "markdown", "json" or "" (off)
+	WriteSessionReport string `toml:"write_session_report"`
+
+	// Save the equity peak with account state and restore it at start, so
+	// drawdown is measured across restarts
+	PersistEquityPeak bool `toml:"persist_equity_peak"`
+}
+
+// RiskLimits overrides the top-level bankroll, leverage and base notional
+// in one mode; zero fields keep the top-level value
+type RiskLimits struct {
+	Bankroll     float64 `toml:"bankroll"`
+	Leverage     float64 `toml:"leverage"`
+	BaseNotional float64 `toml:"base_notional"` // max notional per copy
+}
+
+// Limits returns the risk limits for the current mode: [trading.paper] in
+// paper mode and [trading.live] otherwise, over the top-level values, so
+// going live never inherits limits only meant for paper experiments
+func (c *Config) Limits() RiskLimits {
+	limits := RiskLimits{Bankroll: c.Bankroll, Leverage: c.Leverage, BaseNotional: c.BaseNotional}
+	mode := c.Trading.Live
+	if c.PaperTradingOnly {
+		mode = c.Trading.Paper
+	}
+	if mode.Bankroll != 0 {
+		limits.Bankroll = mode.Bankroll
+	}
+	if mode.Leverage != 0 {
+		limits.Leverage = mode.Leverage
+	}
+	if mode.BaseNotional != 0 {
+		limits.BaseNotional = mode.BaseNotional
+	}
+	return limits
+}
+
+// TakeProfitRung trims TrimPct of a position's largest size once its price
+// is GainPct past the average entry
+type TakeProfitRung struct {
+	GainPct float64 `toml:"gain_pct"`
+	TrimPct float64 `toml:"trim_pct"`
+}
+
+// NotificationConfig holds trade alert settings
+type NotificationConfig struct {
+	WebhookURL   string `toml:"webhook_url"`   // Discord/Slack-style webhook
+	BatchSeconds int    `toml:"batch_seconds"` // send one digest per window (0 = per trade)
+
+	// Publish each trade as JSON to this NATS server, e.g.
+	// "nats://localhost:4222" (empty = off)
+	NATSURL     string `toml:"nats_url"`
+	NATSSubject string `toml:"nats_subject"` // default "hype-copy-bot.trades"
+}
+
+// TradingConfig holds copy-trading behaviour settings
+type TradingConfig struct {
+	// Treat identical coin/side/price/size fills within this many seconds
+	// as one logical order even when their hashes differ (0 = off)
+	FingerprintDedupSeconds int `toml:"fingerprint_dedup_seconds"`
+
+	// Apply the target's leverage changes to our real positions
+	MirrorLeverage bool `toml:"mirror_leverage"`
+
+	// Cap each coin's exposure at the target's leverage on it instead of
+	// ours, falling back to ours until the target's is known
+	MirrorTargetLeverage bool `toml:"mirror_target_leverage"`
+
+	// Open copies of the target's existing positions at startup, priced at
+	// their entry and marked at the current mid
+	BackfillPositions bool `toml:"backfill_positions"`
+
+	// Realized PnL cost basis: "average" (default) or "fifo"
+	CostBasis string `toml:"cost_basis"`
+
+	// Square-root price impact on paper copies: fractional price move per
+	// sqrt(USD) of copied notional (0 = off)
+	ImpactCoefficient float64 `toml:"impact_coefficient"`
+
+	// Charge perp funding on open paper positions at the current rates
+	// every this many minutes; rates are hourly and scaled to the interval
+	// (0 = off)
+	FundingIntervalMinutes int `toml:"funding_interval_minutes"`
+
+	// Slippage on paper copies in basis points of price on a $10k copy,
+	// the gap between the target's price and ours; it grows with the
+	// square root of notional
+	SlippageBps float64 `toml:"slippage_bps"`
+
+	// Our account's quote currency and its rate per unit of the target's
+	// USDC quote (1 when ours is USDC too). Bankroll, base_notional and
+	// copy_threshold are in ours.
+	QuoteCurrency string  `toml:"quote_currency"`
+	QuoteRate     float64 `toml:"quote_rate"`
+
+	// Ignore the target's signals while their realized PnL over the
+	// lookback (default 24h) is negative
+	RequirePositiveTargetPnL bool `toml:"require_positive_target_pnl"`
+	TargetPnLLookbackMinutes int  `toml:"target_pnl_lookback_minutes"`
+
+	// Ignore the target's signals while the share of winners among their
+	// last target_win_rate_trades (default 20) closing fills is below this
+	// fraction (0 = off)
+	MinTargetWinRate    float64 `toml:"min_target_win_rate"`
+	TargetWinRateTrades int     `toml:"target_win_rate_trades"`
+
+	// Only copy these coins (empty = all)
+	Coins []string `toml:"coins"`
+
+	// Forget coins flat this long with nothing pending, keeping only their
+	// realized PnL in an aggregate, to bound memory (0 = never)
+	EvictFlatAfterSeconds int `toml:"evict_flat_after_seconds"`
+
+	// Batch the target's fills into one trade per parent order (oid)
+	// instead of by coin over the volume window. An order books when the
+	// target's next order in the coin arrives, or after a quiet minute.
+	AggregateByOrder bool `toml:"aggregate_by_order"`
+
+	// Book the pending batch as its own trade when a fill opposes its net
+	// direction, so the target's open and close don't blend into one
+	FlushOnSideChange bool `toml:"flush_on_side_change"`
+
+	// Book a coin's pending batch once it holds this many fills, whatever
+	// its volume, so a high threshold can't grow it without bound
+	// (default 1000)
+	MaxPendingFills int `toml:"max_pending_fills"`
+
+	// Per-mode overrides of bankroll, leverage and base_notional
+	Paper RiskLimits `toml:"paper"`
+	Live  RiskLimits `toml:"live"`
+
+	// Size and limit each coin within its own slice of the bankroll instead
+	// of the shared pool: coin_bankrolls amounts, with the rest split
+	// equally among the other coins in coins
+	PerCoinBankroll bool               `toml:"per_coin_bankroll"`
+	CoinBankrolls   map[string]float64 `toml:"coin_bankrolls"`
+
+	// Per-coin copy thresholds in USD, overriding copy_threshold
+	CoinThresholds map[string]float64 `toml:"coin_thresholds"`
+
+	// Apply the copy threshold to fills reducing our position too. Off by
+	// default so small exits are never missed.
+	ThresholdOnCloses bool `toml:"threshold_on_closes"`
+
+	// Skip target closes in coins we hold no copied position in, e.g. ones
+	// the target opened before we started, so they can't open a phantom
+	// position the other way. Replays feed the trader directly and aren't
+	// affected.
+	ClosesNeedCopiedOpen bool `toml:"closes_need_copied_open"`
+
+	// Which target fills to copy: "all" (default) or "adds_only", which
+	// skips the target's opens and copies it scaling in, plus its exits
+	CopyMode string `toml:"copy_mode"`
+
+	// Groups of correlated coins whose opening fills net against each
+	// other before sizing, so a hedged pair copies as its net exposure
+	HedgeGroups [][]string `toml:"hedge_groups"`
+
+	// Renamed coins, old symbol -> new. Fills and positions under the old
+	// symbol are treated as the new one.
+	CoinAliases map[string]string `toml:"coin_aliases"`
+
+	// Skip REVERSE actions beyond this many per coin per minute as
+	// suspected bad data (0 = unlimited)
+	MaxReversalsPerMinute int `toml:"max_reversals_per_minute"`
+
+	// Venue size decimals per coin and how computed sizes are rounded to
+	// them: "down" (default), "nearest" or "up"
+	SizeDecimals map[string]int `toml:"size_decimals"`
+	SizeRounding string         `toml:"size_rounding"`
+
+	// Smallest position the venue lets us close per coin. Opens below it
+	// are skipped and reversals below it only close; coins not listed use
+	// one lot of their size_decimals
+	MinSizes map[string]float64 `toml:"min_sizes"`
+
+	// A position left smaller than this after a trade is snapped flat.
+	// Coins with size_decimals use half their smallest lot instead
+	// (default 1e-9)
+	SizeEpsilon float64 `toml:"size_epsilon"`
+
+	// Auto-close positions held longer than this (0 = off)
+	MaxHoldSeconds int `toml:"max_hold_seconds"`
+
+	// Close positions at the mark once price moves this % against their
+	// average entry (0 = off)
+	StopLossPct float64 `toml:"stop_loss_pct"`
+
+	// Close positions at the mark once unrealized PnL reaches this % of
+	// their cost basis; with a stop too, whichever is hit first wins (0 = off)
+	TakeProfitPct float64 `toml:"take_profit_pct"`
+
+	// Trim positions at the mark as their gain on entry climbs through
+	// each rung, in rising gain order (empty = off)
+	TakeProfitLadder []TakeProfitRung `toml:"take_profit_ladder"`
+
+	// Don't open or add on target fills older than this when processed,
+	// e.g. after a delayed restart; they still count toward the target's
+	// PnL and closes still go through (0 = off)
+	MaxFillAgeSeconds int `toml:"max_fill_age_seconds"`
+
+	// Skip fills priced more than this % away from the coin's current mark
+	// as bad data or an extreme print; needs the mark feed (0 = off)
+	MaxPriceDeviationPct float64 `toml:"max_price_deviation_pct"`
+
+	// When dynamic sizing has no capacity left: "skip" (default),
+	// "scale_existing" (trim the worst-performing position to make room)
+	// or "reject_and_alert"
+	OnCapacityExhausted string `toml:"on_capacity_exhausted"`
+
+	// Execute each copy as this many equal slices spread over the window
+	// instead of at once (0 or 1 = off)
+	TWAPSlices        int `toml:"twap_slices"`
+	TWAPWindowSeconds int `toml:"twap_window_seconds"`
+
+	// Grow base notional by this fraction of net realized PnL (0 = fixed,
+	// 1 = every banked dollar); open gains never count
+	CompoundRealizedFraction float64 `toml:"compound_realized_fraction"`
+
+	// Scale copies by the target's recent form: 1 + performance_weight
+	// times their realized PnL over their gross realized PnL, each decayed
+	// by half every half-life (0 = off). Weight is the most a copy is
+	// scaled either way (0-1, default 0.5)
+	PerformanceHalfLifeMinutes int     `toml:"performance_halflife_minutes"`
+	PerformanceWeight          float64 `toml:"performance_weight"`
+
+	// Warn in the summary when fees exceed this percentage of gross
+	// realized PnL (default 50)
+	FeeWarnPercent float64 `toml:"fee_warn_percent"`
+
+	// Fee schedule in basis points of notional, for target fills the API
+	// reports no fee on (reported fees set the rate instead). Copies of
+	// crossing fills pay the taker fee, resting ones the maker fee; a
+	// negative maker fee is a rebate
+	TakerFeeBps float64 `toml:"taker_fee_bps"`
+	MakerFeeBps float64 `toml:"maker_fee_bps"`
+}
+
+// MonitoringConfig holds polling behaviour settings
+type MonitoringConfig struct {
+	StartupJitterMs   int `toml:"startup_jitter_ms"`    // random delay before first poll
+	MaxMarkAgeSeconds int `toml:"max_mark_age_seconds"` // pause risk checks on older marks (0 = 60)
+
+	// How far back a target switched to on reload is caught up on, to learn
+	// its positions and track record without copying old fills (default 24h)
+	TargetCatchUpMinutes int `toml:"target_catchup_minutes"`
+
+	// Give up a poll's fill fetch, retries included, after this long so a
+	// slow API can't make the loop fall behind (0 = no deadline)
+	PollDeadlineSeconds int `toml:"poll_deadline_seconds"`
+
+	// Read the target's leverage from its positions this often, for the
+	// summary and the mirroring options (0 = every 60s when mirroring,
+	// else never)
+	LeveragePollSeconds int `toml:"leverage_poll_seconds"`
+
+	// Space API requests at least this far apart, so retries and polls
+	// queued up during an outage don't all fire at once (0 = off)
+	MinRequestIntervalMs int `toml:"min_request_interval_ms"`
+
+	// Remember processed fill hashes this long. It must stay well past the
+	// one-hour poll lookback, or fills the API still returns get copied
+	// again (at least 90, default 120)
+	ProcessedFillTTLMinutes int `toml:"processed_fill_ttl_minutes"`
+
+	// Fills with no timestamp or one older than this are stamped with the
+	// time they are processed instead (0 = 7 days)
+	MaxFillTimeAgeHours int `toml:"max_fill_time_age_hours"`
+
+	QueueSize   int    `toml:"queue_size"`   // fills buffered between poller and processor
+	QueuePolicy string `toml:"queue_policy"` // "block" (default) or "drop_oldest" when full
+
+	HTTPListen       string `toml:"http_listen"`        // HTTP API address, e.g. "127.0.0.1:8080" (empty = off)
+	HTTPAuthToken    string `toml:"http_auth_token"`    // require "Authorization: Bearer <token>" when set
+	HTTPHealthPublic bool   `toml:"http_health_public"` // serve /health, /livez and /readyz without the token
+
+	// Summary position order: "notional" (largest first, default), "coin"
+	// or "pnl" (best unrealized first)
+	PositionSort string `toml:"position_sort"`
+
+	// Run the self-test before starting and refuse to start if it fails
+	SelfTestOnStart bool `toml:"selftest_on_start"`
 }
 
 // GetDataDir returns the full data directory path with PREFIX env var support
@@ -38,30 +374,79 @@ func (c *Config) GetDataDir() string {
 }
 
 func loadConfig(configFile string) (*Config, error) {
-	// Load from specified config file or default config.toml
+	// Load from a file path (default config.toml), "-" for stdin, or a URL,
+	// or several of them comma-separated to layer overrides on a base
 	return loadTOMLConfig(configFile)
 }
 
+// configStdin is where "-" reads config from (replaced in tests)
+var configStdin io.Reader = os.Stdin
+
+// readConfigSource returns raw TOML from a file path, "-" for stdin, or an
+// http(s) URL. Fetched config is only held in memory, never written out.
+func readConfigSource(configFile string) ([]byte, error) {
+	switch {
+	case configFile == "-":
+		return io.ReadAll(configStdin)
+	case strings.HasPrefix(configFile, "http://") || strings.HasPrefix(configFile, "https://"):
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch config: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch config: status %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	default:
+		return os.ReadFile(configFile)
+	}
+}
+
 func loadTOMLConfig(configFile string) (*Config, error) {
 	var config Config
+	var err error
 
 	// Use provided config file or default to config.toml
 	if configFile == "" {
 		configFile = "config.toml"
 	}
 
-	// Try to load the config file
-	if _, err := toml.DecodeFile(configFile, &config); err != nil {
+	// Comma-separated sources are decoded in order into one config, so
+	// each later file overrides only the keys it sets
+	for _, source := range strings.Split(configFile, ",") {
+		source = strings.TrimSpace(source)
+		data, err := readConfigSource(source)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = toml.Decode(string(data), &config); err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	if err := applyEnvOverlay(&config); err != nil {
 		return nil, err
 	}
 
 	// Set defaults
-	if config.CopyThreshold == 0 {
+	if config.CopyAll {
+		config.CopyThreshold = 0
+	} else if config.CopyThreshold == 0 {
 		config.CopyThreshold = 1000.0
 	}
+	if config.CopyThreshold < 0 {
+		return nil, errors.New("copy_threshold must not be negative")
+	}
 	if !config.PaperTradingOnly {
 		config.PaperTradingOnly = true
 	}
+	if config.Mode == "" {
+		config.Mode = ModeCopy
+	}
+	if config.Mode != ModeCopy && config.Mode != ModeObserve {
+		return nil, fmt.Errorf("mode must be %q or %q, got %q", ModeCopy, ModeObserve, config.Mode)
+	}
 	if config.Bankroll == 0 {
 		config.Bankroll = 10000.0 // Default $10k bankroll
 	}
@@ -72,10 +457,294 @@ func loadTOMLConfig(configFile string) (*Config, error) {
 		config.BaseNotional = 1000.0 // Default $1000 per trade
 	}
 
+	if config.Monitoring.MaxMarkAgeSeconds == 0 {
+		config.Monitoring.MaxMarkAgeSeconds = 60
+	}
+	if config.Monitoring.QueuePolicy == "" {
+		config.Monitoring.QueuePolicy = "block"
+	}
+	if config.Monitoring.QueuePolicy != "block" && config.Monitoring.QueuePolicy != "drop_oldest" {
+		return nil, fmt.Errorf("monitoring.queue_policy must be \"block\" or \"drop_oldest\", got %q",
+			config.Monitoring.QueuePolicy)
+	}
+	if config.Monitoring.PositionSort == "" {
+		config.Monitoring.PositionSort = "notional"
+	}
+	switch config.Monitoring.PositionSort {
+	case "notional", "coin", "pnl":
+	default:
+		return nil, fmt.Errorf("monitoring.position_sort must be \"notional\", \"coin\" or \"pnl\", got %q",
+			config.Monitoring.PositionSort)
+	}
+	if config.Monitoring.StartupJitterMs < 0 {
+		return nil, errors.New("monitoring.startup_jitter_ms must not be negative")
+	}
+	if config.Monitoring.MaxMarkAgeSeconds < 0 {
+		return nil, errors.New("monitoring.max_mark_age_seconds must not be negative")
+	}
+	if config.Monitoring.PollDeadlineSeconds < 0 {
+		return nil, errors.New("monitoring.poll_deadline_seconds must not be negative")
+	}
+	if config.Monitoring.LeveragePollSeconds < 0 {
+		return nil, errors.New("monitoring.leverage_poll_seconds must not be negative")
+	}
+	if config.Monitoring.MinRequestIntervalMs < 0 {
+		return nil, errors.New("monitoring.min_request_interval_ms must not be negative")
+	}
+	if ttl := config.Monitoring.ProcessedFillTTLMinutes; ttl != 0 &&
+		time.Duration(ttl)*time.Minute < minProcessedFillTTL {
+		return nil, fmt.Errorf("monitoring.processed_fill_ttl_minutes must be at least %.0f, past the %v poll lookback",
+			minProcessedFillTTL.Minutes(), fillLookback)
+	}
+	if config.Monitoring.MaxFillTimeAgeHours < 0 {
+		return nil, errors.New("monitoring.max_fill_time_age_hours must not be negative")
+	}
+	if config.Monitoring.TargetCatchUpMinutes < 0 {
+		return nil, errors.New("monitoring.target_catchup_minutes must not be negative")
+	}
+
+	if config.Trading.CostBasis == "" {
+		config.Trading.CostBasis = "average"
+	}
+	if config.Trading.CostBasis != "average" && config.Trading.CostBasis != "fifo" {
+		return nil, fmt.Errorf("trading.cost_basis must be \"average\" or \"fifo\", got %q",
+			config.Trading.CostBasis)
+	}
+	config.Trading.QuoteCurrency = strings.ToUpper(strings.TrimSpace(config.Trading.QuoteCurrency))
+	if config.Trading.QuoteCurrency == "" {
+		config.Trading.QuoteCurrency = "USDC"
+	}
+	if config.Trading.QuoteRate == 0 {
+		config.Trading.QuoteRate = 1.0
+	}
+	if config.Trading.QuoteRate < 0 {
+		return nil, errors.New("trading.quote_rate must be positive")
+	}
+	if config.Trading.QuoteCurrency == "USDC" && config.Trading.QuoteRate != 1.0 {
+		return nil, fmt.Errorf("trading.quote_rate must be 1 when quote_currency is USDC, got %g",
+			config.Trading.QuoteRate)
+	}
+	if config.Trading.TargetPnLLookbackMinutes < 0 {
+		return nil, errors.New("trading.target_pnl_lookback_minutes must be positive")
+	}
+	if config.Trading.ImpactCoefficient < 0 {
+		return nil, errors.New("trading.impact_coefficient must not be negative")
+	}
+	if config.Trading.FundingIntervalMinutes < 0 {
+		return nil, errors.New("trading.funding_interval_minutes must not be negative")
+	}
+	if config.Trading.SlippageBps < 0 {
+		return nil, errors.New("trading.slippage_bps must not be negative")
+	}
+	if config.Trading.SizeRounding == "" {
+		config.Trading.SizeRounding = "down"
+	}
+	switch config.Trading.SizeRounding {
+	case "down", "nearest", "up":
+	default:
+		return nil, fmt.Errorf("trading.size_rounding must be \"down\", \"nearest\" or \"up\", got %q",
+			config.Trading.SizeRounding)
+	}
+	if config.Trading.OnCapacityExhausted == "" {
+		config.Trading.OnCapacityExhausted = "skip"
+	}
+	switch config.Trading.OnCapacityExhausted {
+	case "skip", "scale_existing", "reject_and_alert":
+	default:
+		return nil, fmt.Errorf("trading.on_capacity_exhausted must be \"skip\", \"scale_existing\" or \"reject_and_alert\", got %q",
+			config.Trading.OnCapacityExhausted)
+	}
+	if config.Trading.CopyMode == "" {
+		config.Trading.CopyMode = CopyModeAll
+	}
+	if config.Trading.CopyMode != CopyModeAll && config.Trading.CopyMode != CopyModeAddsOnly {
+		return nil, fmt.Errorf("trading.copy_mode must be %q or %q, got %q",
+			CopyModeAll, CopyModeAddsOnly, config.Trading.CopyMode)
+	}
+	if config.Trading.SizeEpsilon < 0 {
+		return nil, errors.New("trading.size_epsilon must not be negative")
+	}
+	if config.Trading.MaxHoldSeconds < 0 {
+		return nil, errors.New("trading.max_hold_seconds must not be negative")
+	}
+	if config.Trading.StopLossPct < 0 || config.Trading.TakeProfitPct < 0 {
+		return nil, errors.New("trading.stop_loss_pct and take_profit_pct must not be negative")
+	}
+	for i, rung := range config.Trading.TakeProfitLadder {
+		if rung.GainPct <= 0 || rung.TrimPct <= 0 || rung.TrimPct > 100 {
+			return nil, fmt.Errorf("trading.take_profit_ladder rung %d needs gain_pct > 0 and trim_pct in (0, 100]", i+1)
+		}
+		if i > 0 && rung.GainPct <= config.Trading.TakeProfitLadder[i-1].GainPct {
+			return nil, errors.New("trading.take_profit_ladder gains must rise from rung to rung")
+		}
+	}
+	if config.Trading.EvictFlatAfterSeconds < 0 {
+		return nil, errors.New("trading.evict_flat_after_seconds must not be negative")
+	}
+	if config.Trading.MaxFillAgeSeconds < 0 {
+		return nil, errors.New("trading.max_fill_age_seconds must not be negative")
+	}
+	if config.Trading.MaxPriceDeviationPct < 0 {
+		return nil, errors.New("trading.max_price_deviation_pct must not be negative")
+	}
+	if config.Trading.MaxPendingFills == 0 {
+		config.Trading.MaxPendingFills = defaultMaxPendingFills
+	}
+	if config.Trading.MaxPendingFills < 0 {
+		return nil, errors.New("trading.max_pending_fills must be positive")
+	}
+	if config.Trading.FeeWarnPercent == 0 {
+		config.Trading.FeeWarnPercent = defaultFeeWarnPercent
+	}
+	if config.Trading.TakerFeeBps < 0 {
+		return nil, errors.New("trading.taker_fee_bps must not be negative")
+	}
+	if f := config.Trading.CompoundRealizedFraction; f < 0 || f > 1 {
+		return nil, errors.New("trading.compound_realized_fraction must be between 0 and 1")
+	}
+	if config.Trading.PerformanceHalfLifeMinutes < 0 {
+		return nil, errors.New("trading.performance_halflife_minutes must not be negative")
+	}
+	if config.Trading.PerformanceWeight == 0 {
+		config.Trading.PerformanceWeight = defaultPerformanceWeight
+	}
+	if w := config.Trading.PerformanceWeight; w < 0 || w > 1 {
+		return nil, errors.New("trading.performance_weight must be between 0 and 1")
+	}
+	if config.Trading.FeeWarnPercent < 0 {
+		return nil, errors.New("trading.fee_warn_percent must not be negative")
+	}
+	if config.Trading.MinTargetWinRate < 0 || config.Trading.MinTargetWinRate > 1 {
+		return nil, fmt.Errorf("trading.min_target_win_rate must be between 0 and 1, got %v",
+			config.Trading.MinTargetWinRate)
+	}
+	if config.Trading.TargetWinRateTrades == 0 {
+		config.Trading.TargetWinRateTrades = defaultWinRateTrades
+	}
+	if config.Trading.TargetWinRateTrades < 0 {
+		return nil, errors.New("trading.target_win_rate_trades must not be negative")
+	}
+	if config.Trading.TWAPSlices < 0 {
+		return nil, errors.New("trading.twap_slices must not be negative")
+	}
+	if config.Trading.TWAPSlices > 1 && config.Trading.TWAPWindowSeconds <= 0 {
+		return nil, errors.New("trading.twap_window_seconds must be positive when twap_slices is set")
+	}
+	if config.Trading.MaxReversalsPerMinute < 0 {
+		return nil, errors.New("trading.max_reversals_per_minute must not be negative")
+	}
+	if config.Trading.FingerprintDedupSeconds < 0 {
+		return nil, errors.New("trading.fingerprint_dedup_seconds must not be negative")
+	}
+
+	// Fills carry uppercase coins, so coin keys must be uppercase too
+	config.Trading.Coins = normalizeCoinList("trading.coins", config.Trading.Coins)
+	grouped := make(map[string]bool)
+	for i, group := range config.Trading.HedgeGroups {
+		group = normalizeCoinList(fmt.Sprintf("trading.hedge_groups[%d]", i), group)
+		if len(group) < 2 {
+			return nil, fmt.Errorf("trading.hedge_groups[%d] needs at least two coins", i)
+		}
+		for _, coin := range group {
+			if grouped[coin] {
+				return nil, fmt.Errorf("trading.hedge_groups lists %s in more than one group", coin)
+			}
+			grouped[coin] = true
+		}
+		config.Trading.HedgeGroups[i] = group
+	}
+	if config.Trading.CoinThresholds, err = normalizeCoinKeys(
+		"trading.coin_thresholds", config.Trading.CoinThresholds); err != nil {
+		return nil, err
+	}
+	if config.Trading.SizeDecimals, err = normalizeCoinKeys(
+		"trading.size_decimals", config.Trading.SizeDecimals); err != nil {
+		return nil, err
+	}
+	if config.Trading.MinSizes, err = normalizeCoinKeys(
+		"trading.min_sizes", config.Trading.MinSizes); err != nil {
+		return nil, err
+	}
+	for coin, size := range config.Trading.MinSizes {
+		if size < 0 {
+			return nil, fmt.Errorf("trading.min_sizes.%s must not be negative", coin)
+		}
+	}
+	if config.Trading.CoinAliases, err = normalizeCoinKeys(
+		"trading.coin_aliases", config.Trading.CoinAliases); err != nil {
+		return nil, err
+	}
+	if config.Trading.CoinBankrolls, err = normalizeCoinKeys(
+		"trading.coin_bankrolls", config.Trading.CoinBankrolls); err != nil {
+		return nil, err
+	}
+	if config.Trading.PerCoinBankroll {
+		if len(config.Trading.Coins) == 0 && len(config.Trading.CoinBankrolls) == 0 {
+			return nil, errors.New("trading.per_coin_bankroll needs trading.coins or trading.coin_bankrolls")
+		}
+		allocated := 0.0
+		for coin, slice := range config.Trading.CoinBankrolls {
+			if slice <= 0 {
+				return nil, fmt.Errorf("trading.coin_bankrolls.%s must be positive", coin)
+			}
+			allocated += slice
+		}
+		if bankroll := config.Limits().Bankroll; allocated > bankroll {
+			return nil, fmt.Errorf("trading.coin_bankrolls total $%.2f exceeds bankroll $%.2f",
+				allocated, bankroll)
+		}
+	}
+	for old, renamed := range config.Trading.CoinAliases {
+		renamed = strings.ToUpper(strings.TrimSpace(renamed))
+		if renamed == "" || renamed == old {
+			return nil, fmt.Errorf("trading.coin_aliases maps %s to %q", old, renamed)
+		}
+		if _, chained := config.Trading.CoinAliases[renamed]; chained {
+			return nil, fmt.Errorf("trading.coin_aliases chains %s through %s, map it to the final symbol", old, renamed)
+		}
+		config.Trading.CoinAliases[old] = renamed
+	}
+
+	if config.Notifications.BatchSeconds < 0 {
+		return nil, errors.New("notifications.batch_seconds must not be negative")
+	}
+
+	if config.Storage.Decimals == 0 {
+		config.Storage.Decimals = defaultStorageDecimals
+	}
+	switch config.Storage.WriteSessionReport {
+	case "", ReportMarkdown, ReportJSON:
+	default:
+		return nil, fmt.Errorf("storage.write_session_report must be %q, %q or empty, got %q",
+			ReportMarkdown, ReportJSON, config.Storage.WriteSessionReport)
+	}
+	if config.Storage.Decimals < 0 {
+		return nil, errors.New("storage.decimals must be positive")
+	}
+	if config.Storage.FullSnapshotEvery < 0 {
+		return nil, errors.New("storage.full_snapshot_every must not be negative")
+	}
+
+	for name, limits := range map[string]RiskLimits{"paper": config.Trading.Paper, "live": config.Trading.Live} {
+		if limits.Bankroll < 0 || limits.Leverage < 0 || limits.BaseNotional < 0 {
+			return nil, fmt.Errorf("trading.%s bankroll, leverage and base_notional must not be negative", name)
+		}
+	}
+	if config.Logging.MaxSizeMB == 0 {
+		config.Logging.MaxSizeMB = defaultLogMaxSizeMB
+	}
+	if config.Logging.MaxSizeMB < 0 || config.Logging.MaxBackups < 0 || config.Logging.MaxAgeDays < 0 {
+		return nil, errors.New("logging.max_size_mb, max_backups and max_age_days must not be negative")
+	}
+
 	// Validate required fields
 	if config.TargetAccount == "" {
 		return nil, errors.New("target_account is required in config.toml")
 	}
+	config.SubAccount = strings.ToLower(config.SubAccount)
+	if config.SubAccount != "" && !isAddress(config.SubAccount) {
+		return nil, fmt.Errorf("sub_account must be a 0x-prefixed 40 hex digit address, got %q", config.SubAccount)
+	}
 
 	// For paper trading, allow placeholder values for API credentials
 	if config.PaperTradingOnly {
@@ -98,3 +767,88 @@ func loadTOMLConfig(configFile string) (*Config, error) {
 
 	return &config, nil
 }
+
+// isAddress reports whether s is a 0x-prefixed 20 byte hex address
+func isAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// applyEnvOverlay overrides file values with any HYPERLIQUID_* variables
+// that are set, so secrets can stay out of the TOML. Env wins.
+func applyEnvOverlay(config *Config) error {
+	stringVars := map[string]*string{
+		"HYPERLIQUID_TARGET_ACCOUNT": &config.TargetAccount,
+		"HYPERLIQUID_API_KEY":        &config.APIKey,
+		"HYPERLIQUID_PRIVATE_KEY":    &config.PrivateKey,
+		"HYPERLIQUID_DATA_DIR":       &config.DataDir,
+		"HYPERLIQUID_SUB_ACCOUNT":    &config.SubAccount,
+	}
+	for name, field := range stringVars {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	floatVars := map[string]*float64{
+		"HYPERLIQUID_COPY_THRESHOLD": &config.CopyThreshold,
+		"HYPERLIQUID_BANKROLL":       &config.Bankroll,
+		"HYPERLIQUID_LEVERAGE":       &config.Leverage,
+		"HYPERLIQUID_BASE_NOTIONAL":  &config.BaseNotional,
+	}
+	for name, field := range floatVars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", name, value, err)
+		}
+		*field = parsed
+	}
+	return nil
+}
+
+// normalizeCoinKeys uppercases the keys of a coin-keyed map. Keys differing
+// only by case are ambiguous and rejected.
+func normalizeCoinKeys[V any](name string, m map[string]V) (map[string]V, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	normalized := make(map[string]V, len(m))
+	seen := make(map[string]string, len(m))
+	for key, value := range m {
+		coin := strings.ToUpper(strings.TrimSpace(key))
+		if other, exists := seen[coin]; exists {
+			return nil, fmt.Errorf("%s lists %s twice (as %q and %q)", name, coin, other, key)
+		}
+		seen[coin] = key
+		normalized[coin] = value
+	}
+	return normalized, nil
+}
+
+// normalizeCoinList uppercases a coin list, dropping case duplicates
+func normalizeCoinList(name string, coins []string) []string {
+	if coins == nil {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(coins))
+	seen := make(map[string]bool, len(coins))
+	for _, entry := range coins {
+		coin := strings.ToUpper(strings.TrimSpace(entry))
+		if seen[coin] {
+			log.Printf("config: %s lists %s more than once, ignoring %q", name, coin, entry)
+			continue
+		}
+		seen[coin] = true
+		normalized = append(normalized, coin)
+	}
+	return normalized
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestConfig writes TOML content to a temp file and returns its path
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+const testConfigBase = `
+target_account = "0x1234567890abcdef1234567890abcdef12345678"
+api_key = "your_api_key_here"
+private_key = "your_64_character_hex_private_key_here"
+paper_trading_only = true
+`
+
+func TestConfigStartupJitter(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+`
+[monitoring]
+startup_jitter_ms = 1500
+`))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.Monitoring.StartupJitterMs != 1500 {
+		t.Errorf("StartupJitterMs = %d, want 1500", config.Monitoring.StartupJitterMs)
+	}
+
+	_, err = loadConfig(writeTestConfig(t, testConfigBase+`
+[monitoring]
+startup_jitter_ms = -1
+`))
+	if err == nil {
+		t.Errorf("loadConfig() should reject negative startup_jitter_ms")
+	}
+}
+
+func TestConfigMaxMarkAge(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.Monitoring.MaxMarkAgeSeconds != 60 {
+		t.Errorf("MaxMarkAgeSeconds = %d, want the 60 default", config.Monitoring.MaxMarkAgeSeconds)
+	}
+
+	_, err = loadConfig(writeTestConfig(t, testConfigBase+`
+[monitoring]
+max_mark_age_seconds = -1
+`))
+	if err == nil {
+		t.Errorf("loadConfig() should reject negative max_mark_age_seconds")
+	}
+}
+
+func TestConfigQuoteCurrency(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+`
+[trading]
+quote_currency = "eur"
+quote_rate = 0.92
+`))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.Trading.QuoteCurrency != "EUR" || config.Trading.QuoteRate != 0.92 {
+		t.Errorf("Quote = %s at %g, want EUR at 0.92", config.Trading.QuoteCurrency, config.Trading.QuoteRate)
+	}
+
+	_, err = loadConfig(writeTestConfig(t, testConfigBase+`
+[trading]
+quote_rate = 1.05
+`))
+	if err == nil {
+		t.Errorf("loadConfig() should reject a quote_rate other than 1 for USDC")
+	}
+}
+
+func TestConfigFromStdin(t *testing.T) {
+	old := configStdin
+	defer func() { configStdin = old }()
+	configStdin = strings.NewReader(testConfigBase + "copy_threshold = 2500.0\n")
+
+	config, err := loadConfig("-")
+	if err != nil {
+		t.Fatalf("loadConfig(-) error = %v", err)
+	}
+	if config.CopyThreshold != 2500.0 {
+		t.Errorf("CopyThreshold = %.2f, want 2500.00", config.CopyThreshold)
+	}
+	if config.TargetAccount != "0x1234567890abcdef1234567890abcdef12345678" {
+		t.Errorf("TargetAccount = %s", config.TargetAccount)
+	}
+}
+
+func TestConfigFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/config.toml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testConfigBase + "bankroll = 50000.0\n"))
+	}))
+	defer server.Close()
+
+	config, err := loadConfig(server.URL + "/config.toml")
+	if err != nil {
+		t.Fatalf("loadConfig(url) error = %v", err)
+	}
+	if config.Bankroll != 50000.0 {
+		t.Errorf("Bankroll = %.2f, want 50000.00", config.Bankroll)
+	}
+
+	// Fetch failures and invalid TOML are errors, same as for files
+	if _, err := loadConfig(server.URL + "/missing.toml"); err == nil {
+		t.Errorf("loadConfig() should fail on 404")
+	}
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("target_account = "))
+	}))
+	defer bad.Close()
+	if _, err := loadConfig(bad.URL); err == nil {
+		t.Errorf("loadConfig() should fail on invalid TOML")
+	}
+}
+
+func TestConfigCostBasis(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.Trading.CostBasis != "average" {
+		t.Errorf("Default cost_basis = %q, want average", config.Trading.CostBasis)
+	}
+
+	if _, err := loadConfig(writeTestConfig(t, testConfigBase+"[trading]\ncost_basis = \"lifo\"\n")); err == nil {
+		t.Errorf("loadConfig() should reject unknown cost_basis")
+	}
+}
+
+func TestConfigModeLimits(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+`
+bankroll = 10000.0
+leverage = 3.0
+base_notional = 1000.0
+
+[trading.paper]
+bankroll = 1000000.0
+leverage = 10.0
+
+[trading.live]
+bankroll = 2000.0
+leverage = 1.5
+base_notional = 200.0
+`))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	// Paper overrides bankroll and leverage, base notional falls through
+	if got := config.Limits(); got != (RiskLimits{Bankroll: 1000000, Leverage: 10, BaseNotional: 1000}) {
+		t.Errorf("Paper limits = %+v", got)
+	}
+
+	config.PaperTradingOnly = false
+	want := RiskLimits{Bankroll: 2000, Leverage: 1.5, BaseNotional: 200}
+	if got := config.Limits(); got != want {
+		t.Errorf("Live limits = %+v, want %+v", got, want)
+	}
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	pt := bot.paperTrader
+	if pt.Bankroll != want.Bankroll || pt.Leverage != want.Leverage || pt.BaseNotional != want.BaseNotional {
+		t.Errorf("Trader limits = $%.2f, %.2fx, $%.2f; want the live ones",
+			pt.Bankroll, pt.Leverage, pt.BaseNotional)
+	}
+
+	if _, err := loadConfig(writeTestConfig(t, testConfigBase+"[trading.live]\nleverage = -1.0\n")); err == nil {
+		t.Errorf("loadConfig() should reject negative live leverage")
+	}
+}
+
+func TestConfigCopyAll(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+"copy_threshold = 0\n"))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.CopyThreshold != 1000.0 {
+		t.Errorf("Zero copy_threshold = %.2f, want the 1000.00 default", config.CopyThreshold)
+	}
+
+	config, err = loadConfig(writeTestConfig(t, testConfigBase+"copy_threshold = 500.0\ncopy_all = true\n"))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.CopyThreshold != 0 {
+		t.Fatalf("copy_all threshold = %.2f, want 0", config.CopyThreshold)
+	}
+
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = NewTestPaperTrader()
+	tiny := &Fill{Coin: "BTC", Side: SideBuy, Size: 0.0001, Price: 50000.0, Hash: "0xtiny", Time: time.Now().UnixMilli()}
+	bot.process(tiny)
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 1 {
+		t.Errorf("A $5 fill with copy_all booked %d trades, want 1", trades)
+	}
+
+	if _, err := loadConfig(writeTestConfig(t, testConfigBase+"copy_threshold = -1.0\n")); err == nil {
+		t.Errorf("loadConfig() should reject a negative copy_threshold")
+	}
+}
+
+func TestConfigQueuePolicy(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.Monitoring.QueuePolicy != "block" {
+		t.Errorf("Default queue_policy = %q, want block", config.Monitoring.QueuePolicy)
+	}
+
+	if _, err := loadConfig(writeTestConfig(t, testConfigBase+"[monitoring]\nqueue_policy = \"drop_newest\"\n")); err == nil {
+		t.Errorf("loadConfig() should reject unknown queue_policy")
+	}
+}
+
+func TestConfigCoinCaseCollisions(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+`
+[trading]
+coins = ["btc", "ETH", "BTC"]
+coin_thresholds = { eth = 2000.0, Sol = 500.0 }
+`))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	// List duplicates are merged with a warning
+	if want := []string{"BTC", "ETH"}; len(config.Trading.Coins) != 2 ||
+		config.Trading.Coins[0] != want[0] || config.Trading.Coins[1] != want[1] {
+		t.Errorf("Coins = %v, want %v", config.Trading.Coins, want)
+	}
+	if config.Trading.CoinThresholds["ETH"] != 2000.0 || config.Trading.CoinThresholds["SOL"] != 500.0 {
+		t.Errorf("Coin thresholds not normalized: %v", config.Trading.CoinThresholds)
+	}
+
+	// Map keys differing only by case are ambiguous
+	_, err = loadConfig(writeTestConfig(t, testConfigBase+`
+[trading]
+coin_thresholds = { BTC = 5000.0, btc = 100.0 }
+`))
+	if err == nil {
+		t.Fatalf("loadConfig() should reject case-colliding coin_thresholds")
+	}
+	if !strings.Contains(err.Error(), "coin_thresholds") || !strings.Contains(err.Error(), "BTC") {
+		t.Errorf("Error should name the map and coin, got: %v", err)
+	}
+}
+
+func TestConfigEnvOverlay(t *testing.T) {
+	envKey := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	t.Setenv("HYPERLIQUID_PRIVATE_KEY", envKey)
+	t.Setenv("HYPERLIQUID_COPY_THRESHOLD", "2500")
+
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+`
+copy_threshold = 100.0
+bankroll = 5000.0
+`))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.PrivateKey != envKey {
+		t.Errorf("PrivateKey = %q, want the env value", config.PrivateKey)
+	}
+	if config.CopyThreshold != 2500.0 {
+		t.Errorf("CopyThreshold = %.2f, want env 2500.00", config.CopyThreshold)
+	}
+	// Unset variables leave file values alone
+	if config.Bankroll != 5000.0 {
+		t.Errorf("Bankroll = %.2f, want file 5000.00", config.Bankroll)
+	}
+
+	t.Setenv("HYPERLIQUID_COPY_THRESHOLD", "lots")
+	if _, err := loadConfig(writeTestConfig(t, testConfigBase)); err == nil {
+		t.Errorf("loadConfig() should reject a non-numeric HYPERLIQUID_COPY_THRESHOLD")
+	}
+}
+
+func TestConfigCoinAliases(t *testing.T) {
+	config, err := loadConfig(writeTestConfig(t, testConfigBase+`
+[trading]
+coin_aliases = { oldcoin = "newcoin" }
+`))
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if got := config.Trading.CoinAliases["OLDCOIN"]; got != "NEWCOIN" {
+		t.Errorf("CoinAliases[OLDCOIN] = %q, want NEWCOIN", got)
+	}
+
+	_, err = loadConfig(writeTestConfig(t, testConfigBase+`
+[trading]
+coin_aliases = { A = "B", B = "C" }
+`))
+	if err == nil {
+		t.Errorf("loadConfig() should reject chained coin_aliases")
+	}
+}
+
+func TestConfigMergesFiles(t *testing.T) {
+	base := writeTestConfig(t, testConfigBase+`
+copy_threshold = 1000.0
+bankroll = 5000.0
+
+[trading]
+cost_basis = "fifo"
+coin_thresholds = { BTC = 5000.0, ETH = 2000.0 }
+`)
+	override := filepath.Join(t.TempDir(), "prod.toml")
+	if err := os.WriteFile(override, []byte(`
+copy_threshold = 2500.0
+
+[trading]
+coin_thresholds = { ETH = 3000.0 }
+`), 0644); err != nil {
+		t.Fatalf("Failed to write override: %v", err)
+	}
+
+	config, err := loadConfig(base + "," + override)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.CopyThreshold != 2500.0 {
+		t.Errorf("CopyThreshold = %.2f, want override 2500.00", config.CopyThreshold)
+	}
+	if config.TargetAccount != "0x1234567890abcdef1234567890abcdef12345678" {
+		t.Errorf("TargetAccount = %q, want the base value", config.TargetAccount)
+	}
+	if config.Bankroll != 5000.0 || config.Trading.CostBasis != "fifo" {
+		t.Errorf("Base values lost: bankroll %.2f, cost basis %q",
+			config.Bankroll, config.Trading.CostBasis)
+	}
+	// Sections merge key by key
+	if btc, eth := config.Trading.CoinThresholds["BTC"], config.Trading.CoinThresholds["ETH"]; btc != 5000.0 || eth != 3000.0 {
+		t.Errorf("CoinThresholds BTC = %.0f, ETH = %.0f; want 5000, 3000", btc, eth)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// api returns the exchange client currently in use
+func (b *Bot) api() *Client {
+	b.clientMu.RLock()
+	defer b.clientMu.RUnlock()
+	return b.client
+}
+
+// RotateCredentials swaps in a client signing with new keys. Positions are
+// untouched; requests already in flight on the old client finish before
+// it is closed.
+func (b *Bot) RotateCredentials(apiKey, privateKey string) error {
+	config := *b.config
+	config.APIKey = apiKey
+	config.PrivateKey = privateKey
+
+	client, err := NewClient(&config)
+	if err != nil {
+		return fmt.Errorf("rotate credentials: %w", err)
+	}
+
+	b.clientMu.Lock()
+	old := b.client
+	if old != nil {
+		client.baseURL = old.baseURL
+		old.assetsMu.Lock()
+		client.assets = old.assets // never mutated, only replaced
+		old.assetsMu.Unlock()
+	}
+	b.client = client
+	b.clientMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	log.Printf("bot: rotated credentials, signer %s", client.SignerPublicKey())
+	return nil
+}
+
+// Reload re-reads the config source on SIGHUP, rotating to its keys and
+// switching to its target_account when that changed
+func (b *Bot) Reload(configFile string) error {
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("reload config: %w", err)
+	}
+	if err := b.RotateCredentials(config.APIKey, config.PrivateKey); err != nil {
+		return err
+	}
+	return b.SetTarget(config.TargetAccount)
+}
+
+// handleCredentials serves POST /credentials {"api_key", "private_key"}
+func (b *Bot) handleCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var keys struct {
+		APIKey     string `json:"api_key"`
+		PrivateKey string `json:"private_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if err := b.RotateCredentials(keys.APIKey, keys.PrivateKey); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"signer": b.api().SignerPublicKey()})
+}
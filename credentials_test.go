@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const rotatedKey = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+
+func TestRotateCredentials(t *testing.T) {
+	// Mock exchange echoing the key each request was signed with
+	var mu sync.Mutex
+	var signers []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signer := r.Header.Get("X-Signer")
+		mu.Lock()
+		signers = append(signers, signer)
+		mu.Unlock()
+		w.Write([]byte(`{"signer":"` + signer + `"}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Monitoring.HTTPAuthToken = "secret"
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.client.assets = testAssets
+	bot.paperTrader.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix()))
+
+	order := &Order{Coin: "BTC", Side: SideBuy, Size: 0.1, Price: 50000.0, Type: "limit"}
+	oldClient := bot.api()
+	oldSigner := oldClient.SignerPublicKey()
+	if err := bot.api().PlaceOrder(order); err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+
+	// Rotate through the authenticated endpoint
+	body := `{"api_key":"new","private_key":"` + rotatedKey + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/credentials", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	bot.newHTTPHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rotate status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	newSigner := bot.api().SignerPublicKey()
+	if newSigner == oldSigner {
+		t.Fatalf("Signer unchanged after rotation")
+	}
+	if err := bot.api().PlaceOrder(order); err != nil {
+		t.Fatalf("PlaceOrder after rotation failed: %v", err)
+	}
+
+	// A client fetched before the swap can't sign with the old key
+	if err := oldClient.PlaceOrder(order); err == nil {
+		t.Errorf("PlaceOrder on the replaced client should fail")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(signers) != 2 || signers[0] != oldSigner || signers[1] != newSigner {
+		t.Errorf("Signers = %v, want [%s %s]", signers, oldSigner, newSigner)
+	}
+	if pos := bot.paperTrader.Positions["BTC"]; pos == nil || pos.Size == 0 {
+		t.Errorf("Position dropped by credential rotation")
+	}
+
+	// Invalid keys, including hex of the wrong length, leave the current
+	// client in place instead of panicking or signing with a bogus key
+	for _, key := range []string{"not-hex", "abcd", rotatedKey + "0011223344556677"} {
+		if err := bot.RotateCredentials("bad", key); err == nil {
+			t.Errorf("Expected error rotating to invalid key %q", key)
+		}
+	}
+	req = httptest.NewRequest(http.MethodPost, "/credentials",
+		strings.NewReader(`{"api_key":"bad","private_key":"abcd"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	bot.newHTTPHandler().ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Errorf("Rotating to a short key through the endpoint = 200, want an error")
+	}
+	if bot.api().SignerPublicKey() != newSigner {
+		t.Errorf("Failed rotation replaced the client")
+	}
+}
+
+func TestRotateCredentialsDrainsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.client.assets = testAssets
+
+	requestDone := make(chan error, 1)
+	go func() {
+		requestDone <- bot.api().PlaceOrder(&Order{Coin: "BTC", Side: SideBuy, Size: 0.1, Price: 50000.0, Type: "limit"})
+	}()
+	<-started
+
+	rotated := make(chan struct{})
+	go func() {
+		if err := bot.RotateCredentials("new", rotatedKey); err != nil {
+			t.Errorf("RotateCredentials failed: %v", err)
+		}
+		close(rotated)
+	}()
+
+	select {
+	case <-rotated:
+		t.Fatalf("Rotation finished while a request was still in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-requestDone; err != nil {
+		t.Errorf("In-flight request failed: %v", err)
+	}
+	<-rotated
+}
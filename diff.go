@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// diffEpsilon is the difference below which sizes, prices and PnL match
+const diffEpsilon = 1e-9
+
+// PortfolioDiff is how one portfolio differs from another. Deltas are this
+// portfolio minus the other.
+type PortfolioDiff struct {
+	Positions   map[string]PositionDiff // coins whose size or entry differ
+	RealizedPnL float64                 // gross realized PnL delta
+	Trades      int                     // trade count delta
+}
+
+// PositionDiff is one coin's position on each side; flat is zero
+type PositionDiff struct {
+	Size, OtherSize         float64 // signed
+	AvgPrice, OtherAvgPrice float64
+}
+
+// portfolioState is the part of a trader a diff compares
+type portfolioState struct {
+	positions map[string]Position
+	realized  float64
+	trades    int
+}
+
+func (pt *PaperTrader) portfolioState() portfolioState {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	state := portfolioState{
+		positions: make(map[string]Position),
+		realized:  pt.TotalRealizedPnL,
+		trades:    pt.TotalTrades,
+	}
+	for coin, position := range pt.Positions {
+		if position.Size != 0 {
+			state.positions[coin] = *position
+		}
+	}
+	return state
+}
+
+// Diff compares pt's positions, realized PnL and trade count against
+// other's. Each trader is read under its own lock in turn, so neither
+// needs to be quiet while the other is read.
+func (pt *PaperTrader) Diff(other *PaperTrader) PortfolioDiff {
+	ours, theirs := pt.portfolioState(), other.portfolioState()
+
+	diff := PortfolioDiff{
+		Positions:   make(map[string]PositionDiff),
+		RealizedPnL: ours.realized - theirs.realized,
+		Trades:      ours.trades - theirs.trades,
+	}
+	for coin, position := range ours.positions {
+		otherPosition := theirs.positions[coin]
+		if math.Abs(position.Size-otherPosition.Size) > diffEpsilon ||
+			math.Abs(position.AvgEntryPrice-otherPosition.AvgEntryPrice) > diffEpsilon {
+			diff.Positions[coin] = PositionDiff{
+				Size: position.Size, OtherSize: otherPosition.Size,
+				AvgPrice: position.AvgEntryPrice, OtherAvgPrice: otherPosition.AvgEntryPrice,
+			}
+		}
+	}
+	for coin, otherPosition := range theirs.positions {
+		if _, held := ours.positions[coin]; !held {
+			diff.Positions[coin] = PositionDiff{OtherSize: otherPosition.Size, OtherAvgPrice: otherPosition.AvgEntryPrice}
+		}
+	}
+	return diff
+}
+
+// Empty reports whether the two portfolios match
+func (d PortfolioDiff) Empty() bool {
+	return len(d.Positions) == 0 && math.Abs(d.RealizedPnL) <= diffEpsilon && d.Trades == 0
+}
+
+func (d PortfolioDiff) String() string {
+	if d.Empty() {
+		return "no differences"
+	}
+	parts := []string{
+		fmt.Sprintf("realized %+.2f", d.RealizedPnL),
+		fmt.Sprintf("trades %+d", d.Trades),
+	}
+	coins := make([]string, 0, len(d.Positions))
+	for coin := range d.Positions {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	for _, coin := range coins {
+		position := d.Positions[coin]
+		parts = append(parts, fmt.Sprintf("%s %.6f@%.4f vs %.6f@%.4f", coin,
+			position.Size, position.AvgPrice, position.OtherSize, position.OtherAvgPrice))
+	}
+	return strings.Join(parts, ", ")
+}
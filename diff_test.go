@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPortfolioDiff(t *testing.T) {
+	run := func(fills ...*Fill) *PaperTrader {
+		clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		pt := NewTestPaperTrader()
+		pt.Clock = clock
+		for i, fill := range fills {
+			fill.Hash = fmt.Sprintf("0xdiff%d", i)
+			pt.ProcessFill(fill)
+			clock.Advance(time.Second)
+		}
+		return pt
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	shared := func() []*Fill {
+		return []*Fill{
+			createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now),
+			createTestFill("ETH", "B", 2.0, 3000.0, "0.0", now+1),
+		}
+	}
+
+	// Same history, no differences
+	if diff := run(shared()...).Diff(run(shared()...)); !diff.Empty() {
+		t.Errorf("Identical traders differ: %s", diff)
+	}
+
+	// Ours takes profit on half the ETH and opens SOL; theirs buys more BTC
+	ours := run(append(shared(),
+		createTestFill("ETH", "A", 1.0, 3100.0, "0.0", now+2),
+		createTestFill("SOL", "B", 10.0, 200.0, "0.0", now+3))...)
+	theirs := run(append(shared(),
+		createTestFill("BTC", "B", 1.0, 52000.0, "0.0", now+2))...)
+
+	diff := ours.Diff(theirs)
+	if math.Abs(diff.RealizedPnL-100.0) > 1e-9 {
+		t.Errorf("Realized delta = %.2f, want 100.00", diff.RealizedPnL)
+	}
+	if diff.Trades != 1 {
+		t.Errorf("Trades delta = %d, want 1", diff.Trades)
+	}
+
+	want := map[string]PositionDiff{
+		"BTC": {Size: 1.0, OtherSize: 2.0, AvgPrice: 50000.0, OtherAvgPrice: 51000.0},
+		"ETH": {Size: 1.0, OtherSize: 2.0, AvgPrice: 3000.0, OtherAvgPrice: 3000.0},
+		"SOL": {Size: 10.0, AvgPrice: 200.0},
+	}
+	if len(diff.Positions) != len(want) {
+		t.Errorf("Differing positions = %v, want %v", diff.Positions, want)
+	}
+	for coin, expected := range want {
+		if got := diff.Positions[coin]; got != expected {
+			t.Errorf("%s diff = %+v, want %+v", coin, got, expected)
+		}
+	}
+
+	// Seen from the other side every delta flips
+	reverse := theirs.Diff(ours)
+	if reverse.Trades != -1 || math.Abs(reverse.RealizedPnL+100.0) > 1e-9 {
+		t.Errorf("Reverse diff = %s, want the deltas negated", reverse)
+	}
+	if sol := reverse.Positions["SOL"]; sol.Size != 0 || sol.OtherSize != 10.0 {
+		t.Errorf("Reverse SOL diff = %+v, want flat vs 10.0", sol)
+	}
+	if !strings.Contains(diff.String(), "SOL 10.000000@200.0000 vs 0.000000@0.0000") {
+		t.Errorf("Diff %q should list SOL", diff.String())
+	}
+}
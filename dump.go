@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+const redacted = "[redacted]"
+
+// redactedConfig returns a copy of config with secrets masked
+func redactedConfig(config *Config) Config {
+	safe := *config
+	for _, secret := range []*string{
+		&safe.APIKey,
+		&safe.PrivateKey,
+		&safe.Monitoring.HTTPAuthToken,
+		&safe.Notifications.WebhookURL, // webhook URLs embed their token
+		&safe.Notifications.NATSURL,    // and NATS URLs may embed credentials
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return safe
+}
+
+// Dump writes everything needed to reproduce an issue: the resolved config
+// with secrets redacted, the last saved account state, pending aggregation,
+// the target's positions and the processed-fill count
+func (b *Bot) Dump(w io.Writer) error {
+	fmt.Fprintln(w, "# config")
+	if err := toml.NewEncoder(w).Encode(redactedConfig(b.config)); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	fmt.Fprintln(w, "\n# saved state")
+	if latest := latestAccountsFile(b.paperTrader.dataDir()); latest == "" {
+		fmt.Fprintln(w, "none")
+	} else {
+		state, err := LoadLastAccount(latest)
+		if err != nil {
+			fmt.Fprintf(w, "%s: %v\n", latest, err)
+		} else {
+			fmt.Fprintf(w, "%s: %d trades, realized $%.2f, net $%.2f, bankroll $%.2f\n",
+				latest, state.NumTrades, state.RealizedPnL, state.NetRealized, state.Bankroll)
+			coins := make([]string, 0, len(state.Positions))
+			for coin := range state.Positions {
+				coins = append(coins, coin)
+			}
+			sort.Strings(coins)
+			for _, coin := range coins {
+				pos := state.Positions[coin]
+				fmt.Fprintf(w, "  %s %.6f @ %.4f, last %.4f\n", coin, pos.Size, pos.AvgPrice, pos.LastPrice)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\n# pending aggregation")
+	pt := b.paperTrader
+	pt.mu.Lock()
+	coins := make([]string, 0, len(pt.PendingFills))
+	for coin, fills := range pt.PendingFills {
+		if len(fills) > 0 {
+			coins = append(coins, coin)
+		}
+	}
+	sort.Strings(coins)
+	for _, coin := range coins {
+		fmt.Fprintf(w, "%s: %d fills, $%.2f pending volume\n",
+			coin, len(pt.PendingFills[coin]), pt.PendingVolume[coin])
+	}
+	if len(coins) == 0 {
+		fmt.Fprintln(w, "none")
+	}
+	pt.mu.Unlock()
+
+	fmt.Fprintln(w, "\n# target positions")
+	targetPositions := b.TargetPositions()
+	coins = coins[:0]
+	for coin := range targetPositions {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	for _, coin := range coins {
+		fmt.Fprintf(w, "%s %.6f\n", coin, targetPositions[coin])
+	}
+	if len(coins) == 0 {
+		fmt.Fprintln(w, "none")
+	}
+
+	b.mu.Lock()
+	processed := len(b.processedFills)
+	b.mu.Unlock()
+	fmt.Fprintf(w, "\n# processed fills\n%d\n", processed)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpRedactsSecrets(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	config := createTestConfig()
+	config.Monitoring.HTTPAuthToken = "http-secret"
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader.VolumeThreshold = 1e9 // hold fills pending
+	bot.process(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix()))
+
+	var out bytes.Buffer
+	if err := bot.Dump(&out); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	dump := out.String()
+
+	for _, want := range []string{
+		"target_account = \"" + config.TargetAccount + "\"",
+		"copy_threshold = 1000.0",
+		"private_key = \"[redacted]\"",
+		"BTC: 1 fills",
+		"# processed fills\n1\n",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("Dump missing %q:\n%s", want, dump)
+		}
+	}
+	for _, secret := range []string{config.PrivateKey, "http-secret"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("Dump leaks secret %q", secret)
+		}
+	}
+	if config.PrivateKey == redacted {
+		t.Errorf("Redaction modified the live config")
+	}
+}
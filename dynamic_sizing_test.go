@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math"
 	"testing"
 	"time"
@@ -366,3 +367,86 @@ func TestCapitalExhaustionScenario(t *testing.T) {
 		t.Errorf("Total exposure %.2f exceeds bankroll %.2f", totalExposure, pt.Bankroll)
 	}
 }
+
+func TestCompoundRealizedPnLOnly(t *testing.T) {
+	pt := &PaperTrader{
+		Positions:        make(map[string]*Position),
+		PendingFills:     make(map[string][]*Fill),
+		PendingVolume:    make(map[string]float64),
+		LastVolumeUpdate: make(map[string]time.Time),
+		Bankroll:         100000.0,
+		Leverage:         2.0,
+		BaseNotional:     1000.0,
+		CompoundFraction: 0.5,
+	}
+	fill := createTestFill("ETH", "B", 10.0, 4000.0, "0.0", time.Now().Unix())
+
+	// An open $10k paper gain leaves the base notional alone
+	pt.Positions["BTC"] = &Position{Coin: "BTC", Size: 1.0, AvgEntryPrice: 50000.0, LastPrice: 60000.0}
+	if size := pt.calculateDynamicTradeSize(fill); math.Abs(size-0.25) > 1e-9 {
+		t.Errorf("After unrealized gain: got %.6f ETH, want 0.25 ($1000)", size)
+	}
+
+	// Banking $2k net of $100 fees adds half of $1.9k
+	pt.TotalRealizedPnL = 2000.0
+	pt.TotalFees = 100.0
+	if size := pt.calculateDynamicTradeSize(fill); math.Abs(size-1950.0/4000.0) > 1e-9 {
+		t.Errorf("After realized win: got %.6f ETH, want %.6f ($1950)", size, 1950.0/4000.0)
+	}
+
+	// Realized losses shrink it, never below zero
+	pt.TotalRealizedPnL = -5000.0
+	pt.TotalFees = 0
+	if size := pt.calculateDynamicTradeSize(fill); size != 0 {
+		t.Errorf("After realized loss: got %.6f ETH, want 0", size)
+	}
+}
+
+func TestPerformanceWeightedSizing(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.DisableDynamicSize = false
+	pt.BaseNotional = 1000.0
+	pt.FormHalfLife = time.Hour
+	pt.FormWeight = 0.5
+
+	copied := func(fills ...*Fill) float64 {
+		for _, fill := range fills {
+			fill.Time = clock.Now().UnixMilli()
+			fill.Hash = fmt.Sprintf("0xform%d", fill.Time)
+			pt.ProcessFill(fill)
+			clock.Advance(time.Second)
+		}
+		return pt.TradeHistory[len(pt.TradeHistory)-1].Size
+	}
+
+	// Without any closed PnL from the target, copies are base size
+	if size := copied(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", 0)); math.Abs(size-0.02) > 1e-9 {
+		t.Errorf("Neutral copy = %.6f BTC, want 0.02 ($1000)", size)
+	}
+
+	// A losing close leaves the target cold: half size
+	cold := copied(
+		createTestFill("SOL", "A", 1.0, 100.0, "-400.0", 0),
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", 0),
+	)
+	if math.Abs(cold-0.01) > 1e-9 {
+		t.Errorf("Cold copy = %.6f BTC, want 0.01 ($500)", cold)
+	}
+
+	// Three half-lives later two winning closes outweigh the decayed loss
+	clock.Advance(3 * time.Hour)
+	hot := copied(
+		createTestFill("SOL", "A", 1.0, 100.0, "400.0", 0),
+		createTestFill("SOL", "A", 1.0, 100.0, "400.0", 0),
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", 0),
+	)
+	decay := func(d time.Duration) float64 { return math.Pow(0.5, float64(d)/float64(time.Hour)) }
+	score, gross := -400.0*decay(3*time.Hour+2*time.Second)+400.0, 400.0*decay(3*time.Hour+2*time.Second)+400.0
+	score, gross = score*decay(time.Second)+400.0, gross*decay(time.Second)+400.0
+	want := 1000.0 * (1 + 0.5*score/gross) / 50000.0
+	if hot <= cold || math.Abs(hot-want) > 1e-9 {
+		t.Errorf("Hot copy = %.6f BTC, want %.6f, larger than the cold %.6f", hot, want, cold)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrEmptyCoin    = errors.New("fill has empty coin")
+	ErrInvalidSide  = errors.New("fill has invalid side")
+	ErrInvalidPrice = errors.New("fill has invalid price")
+	ErrInvalidSize  = errors.New("fill has invalid size")
+)
+
+// Side is the fill direction in the API's notation: B = buy, A = sell (ask)
+type Side string
+
+const (
+	SideBuy  Side = "B"
+	SideSell Side = "A"
+)
+
+// sideAliases maps accepted side spellings to the API's B/A notation
+var sideAliases = map[string]Side{
+	"B":     SideBuy,
+	"BUY":   SideBuy,
+	"BID":   SideBuy,
+	"LONG":  SideBuy,
+	"A":     SideSell,
+	"S":     SideSell,
+	"SELL":  SideSell,
+	"ASK":   SideSell,
+	"SHORT": SideSell,
+}
+
+// ParseSide maps a side string or alias to a Side
+func ParseSide(s string) (Side, error) {
+	side, ok := sideAliases[strings.ToUpper(strings.TrimSpace(s))]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSide, s)
+	}
+	return side, nil
+}
+
+// IsBuy reports whether the side increases a position
+func (s Side) IsBuy() bool {
+	return s == SideBuy
+}
+
+func (s Side) String() string {
+	switch s {
+	case SideBuy:
+		return "BUY"
+	case SideSell:
+		return "SELL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Validate checks that a fill carries usable trade data. Zero-size fills
+// carry no trade and are rejected with the rest.
+func (f *Fill) Validate() error {
+	if f.Coin == "" {
+		return ErrEmptyCoin
+	}
+	if f.Side != SideBuy && f.Side != SideSell {
+		return fmt.Errorf("%w: %q", ErrInvalidSide, string(f.Side))
+	}
+	if f.Price <= 0 || math.IsNaN(f.Price) || math.IsInf(f.Price, 0) {
+		return fmt.Errorf("%w: %v", ErrInvalidPrice, f.Price)
+	}
+	if f.Size <= 0 || math.IsNaN(f.Size) || math.IsInf(f.Size, 0) {
+		return fmt.Errorf("%w: %v", ErrInvalidSize, f.Size)
+	}
+	return nil
+}
+
+// applyAlias renames the fill's coin when its symbol has been migrated
+func (f *Fill) applyAlias(aliases map[string]string) {
+	if renamed, ok := aliases[f.Coin]; ok {
+		f.Coin = renamed
+	}
+}
+
+// Normalize uppercases the coin, maps side aliases to B/A and validates
+// the result. Called once when a fill enters the bot.
+func (f *Fill) Normalize() error {
+	f.Coin = strings.ToUpper(strings.TrimSpace(f.Coin))
+	if side, err := ParseSide(string(f.Side)); err == nil {
+		f.Side = side
+	}
+	return f.Validate()
+}
+
+// DedupKey identifies the fill across polls: its hash, or for records with
+// no hash a composite of the oid (when known) and the fill's own fields so
+// hashless fills never collide on the empty string. The key is computed
+// from the fill as first seen and cached, so the normalization and time
+// stamping done once it is accepted never change it.
+func (f *Fill) DedupKey() string {
+	if f.dedupKey != "" {
+		return f.dedupKey
+	}
+	if f.Hash != "" {
+		f.dedupKey = f.Hash
+		return f.dedupKey
+	}
+
+	fields := fmt.Sprintf("%s|%s|%g|%g|%d", f.Coin, string(f.Side), f.Size, f.Price, f.Time)
+	if f.Oid != 0 {
+		f.dedupKey = fmt.Sprintf("oid:%d|%s", f.Oid, fields)
+	} else {
+		f.dedupKey = "fill:" + fields
+	}
+	return f.dedupKey
+}
+
+// shortKey abbreviates a dedup key for log lines
+func shortKey(key string) string {
+	if len(key) > 6 {
+		return key[:6]
+	}
+	return key
+}
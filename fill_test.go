@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFillValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		fill *Fill
+		want error
+	}{
+		{"Valid buy", &Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: 50000.0}, nil},
+		{"Valid sell", &Fill{Coin: "ETH", Side: "A", Size: 2.0, Price: 4000.0}, nil},
+		{"Empty coin", &Fill{Coin: "", Side: "B", Size: 1.0, Price: 50000.0}, ErrEmptyCoin},
+		{"Empty side", &Fill{Coin: "BTC", Side: "", Size: 1.0, Price: 50000.0}, ErrInvalidSide},
+		{"Unknown side", &Fill{Coin: "BTC", Side: "X", Size: 1.0, Price: 50000.0}, ErrInvalidSide},
+		{"Zero price", &Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: 0.0}, ErrInvalidPrice},
+		{"Negative price", &Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: -1.0}, ErrInvalidPrice},
+		{"NaN price", &Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: math.NaN()}, ErrInvalidPrice},
+		{"Zero size", &Fill{Coin: "BTC", Side: "B", Size: 0.0, Price: 50000.0}, ErrInvalidSize},
+		{"Negative size", &Fill{Coin: "BTC", Side: "B", Size: -1.0, Price: 50000.0}, ErrInvalidSize},
+		{"Inf size", &Fill{Coin: "BTC", Side: "B", Size: math.Inf(1), Price: 50000.0}, ErrInvalidSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fill.Validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillNormalize(t *testing.T) {
+	tests := []struct {
+		side     string
+		expected Side
+	}{
+		{"B", "B"},
+		{"buy", "B"},
+		{"Bid", "B"},
+		{"A", "A"},
+		{"sell", "A"},
+		{"ask", "A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.side, func(t *testing.T) {
+			fill := &Fill{Coin: " eth ", Side: Side(tt.side), Size: 1.0, Price: 4000.0}
+			if err := fill.Normalize(); err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+			if fill.Coin != "ETH" {
+				t.Errorf("Coin = %q, want ETH", fill.Coin)
+			}
+			if fill.Side != tt.expected {
+				t.Errorf("Side = %q, want %q", fill.Side, tt.expected)
+			}
+		})
+	}
+
+	// Malformed records are still rejected after normalization
+	bad := &Fill{Coin: "btc", Side: "sideways", Size: 1.0, Price: 50000.0}
+	if err := bad.Normalize(); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("Normalize() = %v, want %v", err, ErrInvalidSide)
+	}
+}
+
+func TestBotRejectsInvalidFills(t *testing.T) {
+	config := createTestConfig()
+	config.CopyThreshold = 100.0
+
+	bot := &Bot{
+		config:         config,
+		paperTrader:    NewTestPaperTrader(),
+		processedFills: make(map[string]int64),
+		clock:          systemClock{},
+	}
+
+	invalid := &Fill{
+		Coin: "BTC", Side: "", Size: 1.0, Price: 50000.0,
+		ClosedPnl: "0.0", Hash: "invalid_side", Time: time.Now().UnixMilli(),
+	}
+	if err := bot.process(invalid); err != nil {
+		t.Errorf("process() error = %v", err)
+	}
+	if bot.paperTrader.GetTotalTrades() != 0 {
+		t.Errorf("Invalid fill was traded: trades = %d, want 0", bot.paperTrader.GetTotalTrades())
+	}
+
+	// Lowercase coin and side alias are normalized before trading
+	alias := &Fill{
+		Coin: "btc", Side: "buy", Size: 1.0, Price: 50000.0,
+		ClosedPnl: "0.0", Hash: "alias", Time: time.Now().UnixMilli(),
+	}
+	if err := bot.process(alias); err != nil {
+		t.Errorf("process() error = %v", err)
+	}
+	pos := bot.paperTrader.Positions["BTC"]
+	if pos == nil || pos.Size != 1.0 {
+		t.Errorf("Normalized fill not traded as BTC long: %+v", pos)
+	}
+}
+
+func TestParseSide(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Side
+		wantErr bool
+	}{
+		{"B", SideBuy, false},
+		{"A", SideSell, false},
+		{"buy", SideBuy, false},
+		{"SELL", SideSell, false},
+		{"", "", true},
+		{"hold", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			side, err := ParseSide(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSide(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if side != tt.want {
+				t.Errorf("ParseSide(%q) = %q, want %q", tt.input, side, tt.want)
+			}
+		})
+	}
+
+	if !SideBuy.IsBuy() || SideSell.IsBuy() {
+		t.Errorf("IsBuy() mismatch: buy=%v sell=%v", SideBuy.IsBuy(), SideSell.IsBuy())
+	}
+	if SideBuy.String() != "BUY" || SideSell.String() != "SELL" {
+		t.Errorf("String() = %s/%s, want BUY/SELL", SideBuy, SideSell)
+	}
+}
+
+func TestUnknownSideRejected(t *testing.T) {
+	bot := &Bot{
+		config:         createTestConfig(),
+		paperTrader:    NewTestPaperTrader(),
+		processedFills: make(map[string]int64),
+		clock:          systemClock{},
+	}
+
+	// An unknown side used to be treated as a sell; it must not trade at all
+	fill := &Fill{
+		Coin: "ETH", Side: "X", Size: 10.0, Price: 4000.0,
+		ClosedPnl: "0.0", Hash: "unknown_side", Time: time.Now().UnixMilli(),
+	}
+	if err := fill.Validate(); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("Validate() = %v, want %v", err, ErrInvalidSide)
+	}
+
+	bot.process(fill)
+
+	if bot.paperTrader.GetTotalTrades() != 0 {
+		t.Errorf("Unknown side traded: trades = %d, want 0", bot.paperTrader.GetTotalTrades())
+	}
+	if _, exists := bot.paperTrader.Positions["ETH"]; exists {
+		t.Errorf("Unknown side created a position")
+	}
+}
+
+func TestFillDedupKey(t *testing.T) {
+	fill := func(hash string, oid int64, size float64) *Fill {
+		return &Fill{Coin: "btc", Side: "B", Size: size, Price: 50000.0, Time: 1700000000000, Hash: hash, Oid: oid}
+	}
+	tests := []struct {
+		name string
+		fill *Fill
+		want string
+	}{
+		{"Hash and oid", fill("0xabc", 7, 1.0), "0xabc"},
+		{"Hash only", fill("0xabc", 0, 1.0), "0xabc"},
+		{"Oid only", fill("", 7, 1.0), "oid:7|btc|B|1|50000|1700000000000"},
+		{"Neither", fill("", 0, 1.0), "fill:btc|B|1|50000|1700000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fill.DedupKey(); got != tt.want {
+				t.Errorf("DedupKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Hashless partial fills of one order stay distinct, and identical
+	// records from two polls agree
+	if fill("", 7, 1.0).DedupKey() == fill("", 7, 2.0).DedupKey() {
+		t.Error("Partial fills of one order share a key")
+	}
+	if fill("", 0, 1.0).DedupKey() != fill("", 0, 1.0).DedupKey() {
+		t.Error("Identical hashless fills have different keys")
+	}
+
+	// The key is fixed when first taken, before normalization and stamping
+	normalized := fill("", 0, 1.0)
+	key := normalized.DedupKey()
+	if err := normalized.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	normalized.Time = 0
+	if got := normalized.DedupKey(); got != key {
+		t.Errorf("DedupKey() after Normalize = %q, want %q", got, key)
+	}
+}
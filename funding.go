@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// ApplyFunding charges one funding payment at rate on coin's open
+// position: longs pay a positive rate and shorts receive it, in
+// proportion to the position's notional at its last price. The payment is
+// booked in TotalFunding and returned; flat positions pay nothing.
+func (pt *PaperTrader) ApplyFunding(coin string, rate float64) float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	position, exists := pt.Positions[coin]
+	if !exists || position.Size == 0 {
+		return 0
+	}
+	payment := -position.Size * position.LastPrice * rate
+	pt.TotalFunding += payment
+	return payment
+}
+
+// RecordFundingRates keeps the latest hourly funding rate per coin
+func (pt *PaperTrader) RecordFundingRates(rates map[string]float64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.FundingRate == nil {
+		pt.FundingRate = make(map[string]float64)
+	}
+	for coin, rate := range rates {
+		pt.FundingRate[coin] = rate
+	}
+}
+
+// chargeFunding applies the current funding rates, scaled from hourly to
+// the funding interval, to every open paper position
+func (b *Bot) chargeFunding(interval time.Duration) error {
+	rates, err := b.api().GetFundingRates()
+	if err != nil {
+		return err
+	}
+	b.paperTrader.RecordFundingRates(rates)
+
+	total := 0.0
+	for coin, rate := range rates {
+		total += b.paperTrader.ApplyFunding(coin, rate*interval.Hours())
+	}
+	if total != 0 {
+		log.Printf("bot: funding %+.2f", total)
+	}
+	return nil
+}
+
+// runFunding charges funding every interval until the bot stops
+func (b *Bot) runFunding(interval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopChan:
+			return
+		case <-ticker.C:
+			if err := b.chargeFunding(interval); err != nil {
+				log.Printf("Error charging funding: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyFunding(t *testing.T) {
+	pt := NewTestPaperTrader()
+	now := time.Now().Unix()
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("ETH", "A", 10.0, 3000.0, "0.0", now))
+	pt.Positions["SOL"] = &Position{Coin: "SOL", LastPrice: 150.0} // flat
+
+	// Longs pay a positive rate, shorts receive it, flat pays nothing
+	if paid := pt.ApplyFunding("BTC", 0.0001); math.Abs(paid-(-5.0)) > 1e-9 {
+		t.Errorf("BTC long funding = %.4f, want -5.0000", paid)
+	}
+	if received := pt.ApplyFunding("ETH", 0.0001); math.Abs(received-3.0) > 1e-9 {
+		t.Errorf("ETH short funding = %.4f, want 3.0000", received)
+	}
+	if flat := pt.ApplyFunding("SOL", 0.0001); flat != 0 {
+		t.Errorf("Flat SOL funding = %.4f, want 0", flat)
+	}
+	if math.Abs(pt.TotalFunding-(-2.0)) > 1e-9 {
+		t.Errorf("Total funding = %.4f, want -2.0000", pt.TotalFunding)
+	}
+	if math.Abs(pt.netRealizedPnL()-(-2.0)) > 1e-9 {
+		t.Errorf("Net realized = %.4f, want the -2.0000 funding", pt.netRealizedPnL())
+	}
+	if equity := pt.equity(); math.Abs(equity-(pt.Bankroll-2.0)) > 1e-6 {
+		t.Errorf("Equity = %.4f, want the bankroll less the funding paid", equity)
+	}
+	if summary := captureStdout(t, pt.PrintPortfolioSummary); !strings.Contains(summary, "Funding: $-2.00") {
+		t.Errorf("Summary should show funding:\n%s", summary)
+	}
+}
+
+func TestChargeFundingFromAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"universe":[{"name":"BTC"},{"name":"ETH"}]},` +
+			`[{"funding":"0.0001","markPx":"50000.0"},{"funding":"-0.0002","markPx":"3000.0"}]]`))
+	}))
+	defer server.Close()
+
+	bot := newTestServerBot(t)
+	bot.client.baseURL = server.URL
+	bot.paperTrader.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix()))
+
+	// Hourly rates charged every 30 minutes pay half each time
+	if err := bot.chargeFunding(30 * time.Minute); err != nil {
+		t.Fatalf("chargeFunding() error = %v", err)
+	}
+	if funding := bot.paperTrader.TotalFunding; math.Abs(funding-(-2.5)) > 1e-9 {
+		t.Errorf("Funding = %.4f, want -2.5000", funding)
+	}
+	if rate := bot.paperTrader.FundingRate["ETH"]; math.Abs(rate-(-0.0002)) > 1e-12 {
+		t.Errorf("ETH rate = %v, want the hourly -0.0002", rate)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"math"
+)
+
+// hedgeGroup returns the other coins in coin's hedge group, if any
+func (pt *PaperTrader) hedgeGroup(coin string) []string {
+	for _, group := range pt.HedgeGroups {
+		for i, member := range group {
+			if member == coin {
+				others := append([]string(nil), group[:i]...)
+				return append(others, group[i+1:]...)
+			}
+		}
+	}
+	return nil
+}
+
+// netHedge offsets an opening fill against pending fills of the opposite
+// direction, by notional, in other coins of its hedge group. Both sides
+// shrink by the offset; what is left of fill is returned, nil when it was
+// offset entirely. Fills that reduce our position are exits, not hedges,
+// and aren't netted. Caller must hold pt.mu.
+func (pt *PaperTrader) netHedge(fill *Fill) *Fill {
+	others := pt.hedgeGroup(fill.Coin)
+	if len(others) == 0 || pt.reduces(fill.Coin, fill.Side) {
+		return fill
+	}
+
+	notional := fill.Size * fill.Price
+	for _, other := range others {
+		if notional <= 0 {
+			break
+		}
+		if pt.reduces(other, sideOf(!fill.Side.IsBuy())) {
+			continue // its pending fills are an exit
+		}
+
+		pending := pt.PendingFills[other]
+		for i := len(pending) - 1; i >= 0 && notional > 0; i-- {
+			leg := pending[i]
+			if leg.Side.IsBuy() == fill.Side.IsBuy() {
+				continue
+			}
+			offset := math.Min(notional, leg.Size*leg.Price)
+			trimmed := *leg
+			trimmed.Size -= offset / leg.Price
+			pending[i] = &trimmed
+			pt.PendingVolume[other] = math.Max(0, pt.PendingVolume[other]-offset)
+			notional -= offset
+			log.Printf("Netting %s %s against pending %s %s: $%.2f offset",
+				fill.Side, fill.Coin, leg.Side, other, offset)
+		}
+		if pt.PendingFills[other] = dropEmptyFills(pending); len(pt.PendingFills[other]) == 0 {
+			pt.PendingVolume[other] = 0
+			delete(pt.LastVolumeUpdate, other)
+		}
+	}
+
+	if notional <= fill.Size*fill.Price*1e-9 {
+		return nil
+	}
+	rest := *fill
+	rest.Size = notional / fill.Price
+	return &rest
+}
+
+// reduces reports whether a trade on side shrinks our position in coin.
+// Caller must hold pt.mu.
+func (pt *PaperTrader) reduces(coin string, side Side) bool {
+	position, exists := pt.Positions[coin]
+	return exists && position.Size != 0 && side.IsBuy() == (position.Size < 0)
+}
+
+func sideOf(buy bool) Side {
+	if buy {
+		return SideBuy
+	}
+	return SideSell
+}
+
+// dropEmptyFills removes fills netted down to nothing
+func dropEmptyFills(fills []*Fill) []*Fill {
+	kept := fills[:0]
+	for _, fill := range fills {
+		if fill.Size > 1e-12 {
+			kept = append(kept, fill)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHedgeGroupNetsOpposingLegs(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+	run := func(groups [][]string) *PaperTrader {
+		clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		pt := NewTestPaperTrader()
+		pt.Clock = clock
+		pt.VolumeThreshold = 1e12 // batches book on the interval only
+		pt.MinTradeInterval = time.Minute
+		pt.HedgeGroups = groups
+
+		// Long $50k BTC hedged with $30k of ETH short
+		now := clock.Now().Unix()
+		for i, fill := range []*Fill{
+			createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now),
+			createTestFill("ETH", "A", 10.0, 3000.0, "0.0", now+1),
+		} {
+			fill.Hash = fmt.Sprintf("0xhedge%d", i)
+			pt.ProcessFill(fill)
+			clock.Advance(time.Second)
+		}
+
+		pt.mu.Lock()
+		pt.processAggregatedFills("BTC")
+		pt.processAggregatedFills("ETH")
+		pt.mu.Unlock()
+		return pt
+	}
+
+	pt := run([][]string{{"BTC", "ETH"}})
+	if pos := pt.Positions["BTC"]; pos == nil || math.Abs(pos.Size-0.4) > 1e-9 {
+		t.Errorf("BTC should copy the $20k net, 0.4 BTC, got %+v", pos)
+	}
+	if pt.Holds("ETH") {
+		t.Errorf("The ETH hedge leg should be netted away, got %.4f ETH", pt.Positions["ETH"].Size)
+	}
+	if trades := pt.GetTotalTrades(); trades != 1 {
+		t.Errorf("Trades = %d, want only the net BTC leg", trades)
+	}
+
+	// Ungrouped, both legs are copied in full
+	pt = run(nil)
+	if pt.Positions["BTC"].Size != 1.0 || pt.Positions["ETH"].Size != -10.0 {
+		t.Errorf("Without a hedge group both legs copy: BTC %.4f, ETH %.4f",
+			pt.Positions["BTC"].Size, pt.Positions["ETH"].Size)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultLogMaxSizeMB = 100
+
+// backupTimeFormat suffixes rotated files; it sorts oldest first
+const backupTimeFormat = "20060102-150405.000"
+
+// rotatingFile is a log file that is moved aside to a timestamped backup
+// once a write would take it past maxSize, keeping at most maxBackups
+// backups no older than maxAge (0 = no limit)
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups, maxAge: maxAge}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// openLogFile opens the rotating log file configured in logging
+func openLogFile(logging LoggingConfig) (*rotatingFile, error) {
+	return newRotatingFile(logging.File, int64(logging.MaxSizeMB)<<20,
+		logging.MaxBackups, time.Duration(logging.MaxAgeDays)*24*time.Hour)
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A single oversized write still goes into a fresh file
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	stamp := time.Now().Format(backupTimeFormat)
+	backup := r.path + "." + stamp
+	for i := 1; fileExists(backup); i++ {
+		backup = fmt.Sprintf("%s.%s-%d", r.path, stamp, i)
+	}
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune removes backups beyond maxBackups and older than maxAge
+func (r *rotatingFile) prune() {
+	backups, _ := filepath.Glob(r.path + ".*")
+	sort.Strings(backups)
+
+	for i, backup := range backups {
+		expired := r.maxBackups > 0 && i < len(backups)-r.maxBackups
+		if !expired && r.maxAge > 0 {
+			if info, err := os.Stat(backup); err == nil {
+				expired = time.Since(info.ModTime()) > r.maxAge
+			}
+		}
+		if expired {
+			os.Remove(backup)
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileRotatesPastMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "bot.log")
+	file, err := newRotatingFile(path, 100, 2, 0)
+	if err != nil {
+		t.Fatalf("newRotatingFile() error = %v", err)
+	}
+	defer file.Close()
+
+	line := strings.Repeat("x", 39) + "\n" // 40 bytes
+	for i := 0; i < 3; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("Backups = %v, want one after passing 100 bytes", backups)
+	}
+	data, err := os.ReadFile(backups[0])
+	if err != nil || len(data) != 80 {
+		t.Errorf("Backup holds %d bytes (%v), want the first 80", len(data), err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil || string(data) != line {
+		t.Errorf("Current log = %q (%v), want the last line", data, err)
+	}
+
+	// Older backups beyond max_backups are pruned
+	for _, name := range []string{"20200101-000000.000", "20200102-000000.000"} {
+		if err := os.WriteFile(path+"."+name, []byte("old\n"), 0644); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		file.Write([]byte(line))
+	}
+	backups, _ = filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("Backups = %v, want 2 after pruning", backups)
+	}
+	for _, backup := range backups {
+		if strings.Contains(backup, "2020") {
+			t.Errorf("Oldest backup %s should have been pruned", backup)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -9,17 +11,80 @@ import (
 	"time"
 )
 
-type unixLogger struct{}
+type unixLogger struct {
+	out io.Writer
+}
 
 func (u *unixLogger) Write(p []byte) (n int, err error) {
 	timestamp := time.Now().Format("Jan 2 15:04:05")
-	return fmt.Printf("%s %s", timestamp, string(p))
+	return fmt.Fprintf(u.out, "%s %s", timestamp, string(p))
 }
 
 func main() {
 	// Configure Unix syslog-style timestamp format (Jan 20 10:30:28)
 	log.SetFlags(0)
-	log.SetOutput(&unixLogger{})
+	log.SetOutput(&unixLogger{out: os.Stdout})
+
+	// bankroll <config> <fills.jl>...: required capital analysis, then exit
+	if len(os.Args) > 2 && os.Args[1] == "bankroll" {
+		config, err := loadConfig(os.Args[2])
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		if err := runBankrollAnalysis(config, os.Args[3:]); err != nil {
+			log.Fatal("Failed to analyse fills:", err)
+		}
+		return
+	}
+
+	// replay <config> [--speed N] <fills.jl>...: paper-trade saved fills,
+	// at N times their original pace (default instantly), then exit
+	if len(os.Args) > 2 && os.Args[1] == "replay" {
+		config, err := loadConfig(os.Args[2])
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		flags := flag.NewFlagSet("replay", flag.ExitOnError)
+		speed := flags.Float64("speed", 0, "replay at this multiple of the original pace (0 = instantly)")
+		flags.Parse(os.Args[3:])
+		if err := runReplayCommand(config, *speed, flags.Args()); err != nil {
+			log.Fatal("Failed to replay fills:", err)
+		}
+		return
+	}
+
+	// dump <config>: diagnostics for bug reports, then exit
+	if len(os.Args) > 2 && os.Args[1] == "dump" {
+		config, err := loadConfig(os.Args[2])
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		bot, err := NewBot(config)
+		if err != nil {
+			log.Fatal("Failed to create bot:", err)
+		}
+		if err := bot.Dump(os.Stdout); err != nil {
+			log.Fatal("Failed to dump:", err)
+		}
+		return
+	}
+
+	// selftest <config>: check connectivity, signing, limits and storage,
+	// then exit non-zero if any check failed
+	if len(os.Args) > 2 && (os.Args[1] == "selftest" || os.Args[1] == "--selftest") {
+		config, err := loadConfig(os.Args[2])
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		bot, err := NewBot(config)
+		if err != nil {
+			log.Fatal("Failed to create bot:", err)
+		}
+		if !printSelfTest(os.Stdout, bot.SelfTest()) {
+			os.Exit(1)
+		}
+		return
+	}
 
 	log.Println("hype-copy-bot: starting")
 
@@ -33,18 +98,40 @@ func main() {
 		log.Fatal("Failed to load config:", err)
 	}
 
+	if config.Logging.File != "" {
+		logFile, err := openLogFile(config.Logging)
+		if err != nil {
+			log.Fatal("Failed to open log file:", err)
+		}
+		defer logFile.Close()
+		log.Printf("hype-copy-bot: logging to %s", config.Logging.File)
+		log.SetOutput(&unixLogger{out: logFile})
+	}
+
 	bot, err := NewBot(config)
 	if err != nil {
 		log.Fatal("Failed to create bot:", err)
 	}
 
+	if config.Monitoring.SelfTestOnStart && !printSelfTest(os.Stdout, bot.SelfTest()) {
+		log.Fatal("Self-test failed, not starting")
+	}
+
 	if err := bot.Start(); err != nil {
 		log.Fatal("Failed to start bot:", err)
 	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		// SIGHUP picks up rotated API keys or a new target without a restart
+		if err := bot.Reload(configFile); err != nil {
+			log.Printf("Error reloading config: %v", err)
+		}
+	}
 
 	log.Println("hype-copy-bot: shutting down")
 	bot.Stop()
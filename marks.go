@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// now returns the current time from the trader's clock
+func (pt *PaperTrader) now() time.Time {
+	if pt.Clock == nil {
+		return time.Now()
+	}
+	return pt.Clock.Now()
+}
+
+// UpdateMarks applies a batch of mark prices from the market feed
+func (pt *PaperTrader) UpdateMarks(mids map[string]float64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.feedMarks == nil {
+		pt.feedMarks = make(map[string]feedMark)
+	}
+	for coin, price := range mids {
+		if price > 0 {
+			pt.feedMarks[coin] = feedMark{price: price, time: pt.now()}
+		}
+		if _, exists := pt.Positions[coin]; exists || pt.twaps[coin] != nil {
+			pt.updateMarkPrice(coin, price)
+		}
+	}
+	pt.flushOrders()
+	pt.evictFlat()
+	pt.checkCapital()
+}
+
+// feedMark is a price from the mark feed, kept for every coin so fills can
+// be checked against it before we hold the coin
+type feedMark struct {
+	price float64
+	time  time.Time
+}
+
+// FeedMark returns the coin's latest mark feed price, if there is one no
+// older than MaxMarkAge
+func (pt *PaperTrader) FeedMark(coin string) (float64, bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	mark, exists := pt.feedMarks[coin]
+	if !exists || (pt.MaxMarkAge > 0 && pt.now().Sub(mark.time) > pt.MaxMarkAge) {
+		return 0, false
+	}
+	return mark.price, true
+}
+
+// updateMarkPrice is the single entry point for new prices, from fills or
+// the mark feed. It refreshes the mark age and re-evaluates position risk.
+func (pt *PaperTrader) updateMarkPrice(coin string, price float64) {
+	if price <= 0 {
+		return
+	}
+	if pt.MarkTime == nil {
+		pt.MarkTime = make(map[string]time.Time)
+	}
+	pt.MarkTime[coin] = pt.now()
+	pt.advanceTWAP(coin, price)
+
+	position, exists := pt.Positions[coin]
+	if !exists || position.Size == 0 {
+		return // No position to update
+	}
+
+	// Update the last price for unrealized PnL calculation
+	position.LastPrice = price
+	pt.checkPositionRisk(position)
+}
+
+// markStale reports whether a coin's last mark is older than MaxMarkAge
+func (pt *PaperTrader) markStale(coin string) bool {
+	if pt.MaxMarkAge <= 0 {
+		return false
+	}
+	marked, exists := pt.MarkTime[coin]
+	return !exists || pt.now().Sub(marked) > pt.MaxMarkAge
+}
+
+// checkPositionRisk evaluates risk actions for one position. Returns false
+// when the mark is stale and risk actions are paused for the position.
+func (pt *PaperTrader) checkPositionRisk(position *Position) bool {
+	if position.Size == 0 {
+		return true
+	}
+	if pt.markStale(position.Coin) {
+		return false
+	}
+
+	// Exit zombie positions whose target exit the feed may have missed
+	if pt.MaxHold > 0 && pt.now().Sub(position.OpenTime) > pt.MaxHold {
+		log.Printf("Closing %s: held longer than %v", position.Coin, pt.MaxHold)
+		if _, err := pt.closeAtMark(position.Coin, ActionTimeout); err != nil {
+			log.Printf("Error closing %s on timeout: %v", position.Coin, err)
+		}
+	}
+	pt.checkExits(position)
+	pt.takeProfit(position)
+	return true
+}
+
+// SetStopLoss closes coin's position at the mark once price moves pct
+// percent against its average entry; 0 removes the stop. The stop is
+// checked at once and on every later price update, and lasts until the
+// position closes or reverses.
+func (pt *PaperTrader) SetStopLoss(coin string, pct float64) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pct < 0 {
+		return fmt.Errorf("stop loss must not be negative, got %.2f%%", pct)
+	}
+	position, exists := pt.Positions[coin]
+	if !exists || position.Size == 0 {
+		return ErrNoPosition
+	}
+	position.StopLossPct = pct
+	pt.checkPositionRisk(position)
+	return nil
+}
+
+// SetTakeProfit closes coin's position at the mark once its unrealized
+// PnL reaches pct percent of its cost basis; 0 removes it. Like a stop it
+// is checked at once and on every later price update.
+func (pt *PaperTrader) SetTakeProfit(coin string, pct float64) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pct < 0 {
+		return fmt.Errorf("take profit must not be negative, got %.2f%%", pct)
+	}
+	position, exists := pt.Positions[coin]
+	if !exists || position.Size == 0 {
+		return ErrNoPosition
+	}
+	position.TakeProfitPct = pct
+	pt.checkPositionRisk(position)
+	return nil
+}
+
+// checkExits closes position at the mark once its loss reaches its stop or
+// its gain its take profit. Whichever is hit first wins and both are
+// cleared.
+func (pt *PaperTrader) checkExits(position *Position) {
+	if position.Size == 0 || position.AvgEntryPrice <= 0 {
+		return
+	}
+	// Unrealized PnL over cost basis, the same for longs and shorts
+	costBasis := position.AvgEntryPrice * math.Abs(position.Size)
+	gain := pt.calculateUnrealizedPnL(position) / costBasis * 100
+
+	var exit string
+	switch {
+	case position.StopLossPct > 0 && -gain >= position.StopLossPct:
+		exit = "Stop loss"
+	case position.TakeProfitPct > 0 && gain >= position.TakeProfitPct:
+		exit = "Take profit"
+	default:
+		return
+	}
+	position.StopLossPct, position.TakeProfitPct = 0, 0
+	log.Printf("%s on %s at %+.1f%%: closing at %.4f", exit, position.Coin, gain, position.LastPrice)
+	if _, err := pt.closeAtMark(position.Coin, ActionClose); err != nil {
+		log.Printf("Error closing %s at its %s: %v", position.Coin, strings.ToLower(exit), err)
+	}
+}
+
+// takeProfit trims position at the mark for every take-profit rung its
+// gain on entry has reached since the last check, each rung once
+func (pt *PaperTrader) takeProfit(position *Position) {
+	for position.LadderRungs < len(pt.TakeProfitLadder) && position.Size != 0 && position.AvgEntryPrice > 0 {
+		rung := pt.TakeProfitLadder[position.LadderRungs]
+		gain := (position.LastPrice - position.AvgEntryPrice) / position.AvgEntryPrice * 100
+		if position.Size < 0 {
+			gain = -gain
+		}
+		if gain < rung.GainPct {
+			return
+		}
+		position.LadderRungs++
+
+		trim := math.Min(pt.roundSize(position.Coin, position.LadderBase*rung.TrimPct/100), math.Abs(position.Size))
+		if trim <= 0 {
+			continue
+		}
+		tradeSize := math.Copysign(trim, -position.Size)
+		log.Printf("Taking profit on %s at +%.1f%%: trimming %.6f", position.Coin, gain, trim)
+		action := pt.determineAction(position.Size, position.Size+tradeSize)
+		if _, err := pt.tradeAtMark(position.Coin, tradeSize, action); err != nil {
+			log.Printf("Error taking profit on %s: %v", position.Coin, err)
+			return
+		}
+	}
+}
+
+// CheckRisk runs risk checks over all open positions, tracking whether any
+// are operating on stale marks. Returns the coins with stale marks.
+func (pt *PaperTrader) CheckRisk() []string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	var stale []string
+	for coin, position := range pt.Positions {
+		if !pt.checkPositionRisk(position) {
+			stale = append(stale, coin)
+		}
+	}
+	sort.Strings(stale)
+
+	degraded := len(stale) > 0
+	if degraded && !pt.riskDegraded {
+		log.Printf("Stale marks: risk checks paused for %v", stale)
+	} else if !degraded && pt.riskDegraded {
+		log.Println("marks: feed recovered, risk checks resumed")
+	}
+	pt.riskDegraded = degraded
+
+	return stale
+}
+
+// RiskDegraded reports whether the last risk check ran on stale marks
+func (pt *PaperTrader) RiskDegraded() bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.riskDegraded
+}
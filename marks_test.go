@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStaleMarksFlagRiskChecks(t *testing.T) {
+	clock := newFakeClock(time.Now())
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.MaxMarkAge = time.Minute
+
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix()))
+
+	if stale := pt.CheckRisk(); len(stale) != 0 || pt.RiskDegraded() {
+		t.Fatalf("Fresh mark flagged stale: %v", stale)
+	}
+
+	// Mids feed goes quiet for two minutes
+	clock.Advance(2 * time.Minute)
+	stale := pt.CheckRisk()
+	if len(stale) != 1 || stale[0] != "BTC" {
+		t.Errorf("Stale coins = %v, want [BTC]", stale)
+	}
+	if !pt.RiskDegraded() {
+		t.Errorf("Risk checks should be flagged as operating on stale data")
+	}
+
+	// Feed recovers
+	pt.UpdateMarks(map[string]float64{"BTC": 51000.0, "ETH": 4000.0})
+	if stale := pt.CheckRisk(); len(stale) != 0 || pt.RiskDegraded() {
+		t.Errorf("Risk still degraded after fresh marks: %v", stale)
+	}
+	if pt.Positions["BTC"].LastPrice != 51000.0 {
+		t.Errorf("LastPrice = %.2f, want 51000.00", pt.Positions["BTC"].LastPrice)
+	}
+	if _, exists := pt.Positions["ETH"]; exists {
+		t.Errorf("Marks for coins we don't hold should not create positions")
+	}
+}
+
+func TestRefreshMarksFeedFailure(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"BTC": "52000.5"})
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	clock := newFakeClock(time.Now())
+	bot.paperTrader = NewTestPaperTrader()
+	bot.paperTrader.Clock = clock
+	bot.paperTrader.MaxMarkAge = time.Minute
+	bot.paperTrader.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix()))
+
+	bot.refreshMarks()
+	if got := bot.paperTrader.Positions["BTC"].LastPrice; got != 52000.5 {
+		t.Errorf("LastPrice = %.2f, want 52000.50", got)
+	}
+
+	fail = true
+	clock.Advance(90 * time.Second)
+	bot.refreshMarks()
+	if !bot.paperTrader.RiskDegraded() {
+		t.Errorf("Risk checks should be degraded when the feed fails past max age")
+	}
+}
+
+func TestMaxHoldTimeout(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.MaxHold = 10 * time.Minute
+
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 3000.0, "0.0", clock.Now().Unix()))
+
+	clock.Advance(9 * time.Minute)
+	pt.UpdateMarks(map[string]float64{"ETH": 3100.0})
+	pt.CheckRisk()
+	if pt.Positions["ETH"].Size != 1.0 {
+		t.Fatalf("Position closed before max hold time")
+	}
+
+	clock.Advance(2 * time.Minute)
+	pt.UpdateMarks(map[string]float64{"ETH": 3200.0})
+
+	if size := pt.Positions["ETH"].Size; size != 0 {
+		t.Fatalf("Position size after max hold = %.4f, want 0", size)
+	}
+	last := pt.TradeHistory[len(pt.TradeHistory)-1]
+	if last.Action != "TIMEOUT" || last.Price != 3200.0 {
+		t.Errorf("Exit trade = %s @ %.2f, want TIMEOUT @ 3200", last.Action, last.Price)
+	}
+	if pt.TotalRealizedPnL != 200.0 {
+		t.Errorf("Realized PnL = %.2f, want 200.00", pt.TotalRealizedPnL)
+	}
+}
+
+func TestTakeProfitLadder(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.TakeProfitLadder = []TakeProfitRung{
+		{GainPct: 5, TrimPct: 25},
+		{GainPct: 10, TrimPct: 25},
+		{GainPct: 20, TrimPct: 100},
+	}
+
+	pt.ProcessFill(createTestFill("ETH", "B", 4.0, 1000.0, "0.0", clock.Now().Unix()))
+
+	steps := []struct {
+		mark   float64
+		size   float64
+		trades int
+	}{
+		{1040.0, 4.0, 1}, // +4%: below the first rung
+		{1050.0, 3.0, 2}, // +5%: trim 1.0
+		{1080.0, 3.0, 2}, // still between rungs
+		{1040.0, 3.0, 2}, // back down: nothing fires again
+		{1060.0, 3.0, 2}, // first rung already taken
+		{1100.0, 2.0, 3}, // +10%: trim 1.0
+		{1250.0, 0.0, 4}, // +25%: close the rest
+	}
+	for _, step := range steps {
+		clock.Advance(time.Second)
+		pt.UpdateMarks(map[string]float64{"ETH": step.mark})
+		if size := pt.Positions["ETH"].Size; math.Abs(size-step.size) > 1e-9 {
+			t.Errorf("At %.0f: size = %.4f, want %.4f", step.mark, size, step.size)
+		}
+		if len(pt.TradeHistory) != step.trades {
+			t.Errorf("At %.0f: trades = %d, want %d", step.mark, len(pt.TradeHistory), step.trades)
+		}
+	}
+
+	wantActions := []string{"OPEN", "REDUCE", "REDUCE", "CLOSE"}
+	wantPrices := []float64{1000.0, 1050.0, 1100.0, 1250.0}
+	for i, trade := range pt.TradeHistory {
+		if trade.Action != wantActions[i] || trade.Price != wantPrices[i] {
+			t.Errorf("Trade %d = %s @ %.2f, want %s @ %.2f",
+				i, trade.Action, trade.Price, wantActions[i], wantPrices[i])
+		}
+	}
+	// 1.0*50 + 1.0*100 + 2.0*250
+	if math.Abs(pt.TotalRealizedPnL-650.0) > 1e-9 {
+		t.Errorf("Realized PnL = %.2f, want 650.00", pt.TotalRealizedPnL)
+	}
+}
+
+func TestStopLoss(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+
+	if err := pt.SetStopLoss("ETH", 5); err != ErrNoPosition {
+		t.Errorf("SetStopLoss() on no position = %v, want ErrNoPosition", err)
+	}
+
+	// Long stops out below entry, short above it
+	pt.ProcessFill(createTestFill("ETH", "B", 2.0, 1000.0, "0.0", clock.Now().Unix()))
+	clock.Advance(time.Second)
+	pt.ProcessFill(createTestFill("BTC", "A", 1.0, 50000.0, "0.0", clock.Now().Unix()))
+	if err := pt.SetStopLoss("ETH", 5); err != nil {
+		t.Fatalf("SetStopLoss(ETH) error = %v", err)
+	}
+	if err := pt.SetStopLoss("BTC", 2); err != nil {
+		t.Fatalf("SetStopLoss(BTC) error = %v", err)
+	}
+
+	steps := []struct {
+		marks    map[string]float64
+		eth, btc float64
+	}{
+		{map[string]float64{"ETH": 1100.0, "BTC": 49000.0}, 2.0, -1.0}, // both in profit
+		{map[string]float64{"ETH": 960.0, "BTC": 50900.0}, 2.0, -1.0},  // -4% and -1.8%
+		{map[string]float64{"ETH": 950.0, "BTC": 50900.0}, 0.0, -1.0},  // ETH hits -5%
+		{map[string]float64{"BTC": 51100.0}, 0.0, 0.0},                 // BTC past -2%
+	}
+	for i, step := range steps {
+		clock.Advance(time.Second)
+		pt.UpdateMarks(step.marks)
+		if eth, btc := pt.Positions["ETH"].Size, pt.Positions["BTC"].Size; eth != step.eth || btc != step.btc {
+			t.Errorf("Step %d: ETH %.4f BTC %.4f, want %.4f and %.4f", i, eth, btc, step.eth, step.btc)
+		}
+	}
+
+	history := pt.TradeHistory
+	if len(history) != 4 {
+		t.Fatalf("Trades = %d, want 4", len(history))
+	}
+	for i, want := range []struct {
+		coin  string
+		price float64
+	}{{"ETH", 950.0}, {"BTC", 51100.0}} {
+		trade := history[2+i]
+		if trade.Coin != want.coin || trade.Action != "CLOSE" || trade.Price != want.price {
+			t.Errorf("Stop %d = %s %s @ %.2f, want %s CLOSE @ %.2f",
+				i, trade.Coin, trade.Action, trade.Price, want.coin, want.price)
+		}
+	}
+	// 2.0*-50 + 1.0*-1100
+	if math.Abs(pt.TotalRealizedPnL+1200.0) > 1e-9 {
+		t.Errorf("Realized PnL = %.2f, want -1200.00", pt.TotalRealizedPnL)
+	}
+
+	// A new position starts without the old stop
+	clock.Advance(time.Second)
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 1000.0, "0.0", clock.Now().Unix()))
+	clock.Advance(time.Second)
+	pt.UpdateMarks(map[string]float64{"ETH": 900.0})
+	if size := pt.Positions["ETH"].Size; size != 1.0 {
+		t.Errorf("Reopened ETH size = %.4f, want 1 with no stop", size)
+	}
+}
+
+func TestTakeProfit(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+
+	// Long ETH with both a 10% take profit and a 5% stop
+	pt.ProcessFill(createTestFill("ETH", "B", 2.0, 1000.0, "0.0", clock.Now().Unix()))
+	if err := pt.SetTakeProfit("ETH", 10); err != nil {
+		t.Fatalf("SetTakeProfit() error = %v", err)
+	}
+	if err := pt.SetStopLoss("ETH", 5); err != nil {
+		t.Fatalf("SetStopLoss() error = %v", err)
+	}
+
+	for _, step := range []struct {
+		mark float64
+		size float64
+	}{
+		{1050.0, 2.0}, // +5%
+		{960.0, 2.0},  // -4%, above the stop
+		{1099.0, 2.0}, // +9.9%
+		{1100.0, 0.0}, // +10%: take profit
+	} {
+		clock.Advance(time.Second)
+		pt.UpdateMarks(map[string]float64{"ETH": step.mark})
+		if size := pt.Positions["ETH"].Size; size != step.size {
+			t.Errorf("At %.0f: size = %.4f, want %.4f", step.mark, size, step.size)
+		}
+	}
+
+	last := pt.TradeHistory[len(pt.TradeHistory)-1]
+	if last.Action != "CLOSE" || last.Price != 1100.0 || last.RealizedPnL != 200.0 {
+		t.Errorf("Exit = %s @ %.2f realizing %.2f, want CLOSE @ 1100 realizing 200",
+			last.Action, last.Price, last.RealizedPnL)
+	}
+	if pos := pt.Positions["ETH"]; pos.StopLossPct != 0 || pos.TakeProfitPct != 0 {
+		t.Errorf("After take profit stop = %.1f%%, take profit = %.1f%%, want both cleared",
+			pos.StopLossPct, pos.TakeProfitPct)
+	}
+
+	// A short takes profit as price falls, with the default
+	pt.TakeProfitPct = 4
+	clock.Advance(time.Second)
+	pt.ProcessFill(createTestFill("BTC", "A", 1.0, 50000.0, "0.0", clock.Now().Unix()))
+	clock.Advance(time.Second)
+	pt.UpdateMarks(map[string]float64{"BTC": 48000.0})
+	if size := pt.Positions["BTC"].Size; size != 0 {
+		t.Errorf("BTC size after -4%% = %.4f, want 0", size)
+	}
+	// 2.0*100 + 1.0*2000
+	if math.Abs(pt.TotalRealizedPnL-2200.0) > 1e-9 {
+		t.Errorf("Realized PnL = %.2f, want 2200.00", pt.TotalRealizedPnL)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsRegistry serves gauges and histograms in the Prometheus text
+// exposition format
+type metricsRegistry struct {
+	mu         sync.Mutex
+	gauges     []gauge
+	histograms []*histogram
+}
+
+type gauge struct {
+	name, help string
+	value      func() float64
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	name, help string
+	bounds     []float64 // bucket upper bounds, ascending
+
+	mu     sync.Mutex
+	counts []uint64 // observations per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+var (
+	// processBuckets spans a fast in-memory fill to one stuck on storage
+	processBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+	// latencyBuckets spans one poll interval to a badly lagging copy
+	latencyBuckets = []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300, 600}
+)
+
+// Gauge registers a gauge read from value at scrape time
+func (r *metricsRegistry) Gauge(name, help string, value func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges = append(r.gauges, gauge{name: name, help: help, value: value})
+}
+
+// Histogram registers and returns a histogram with the given buckets
+func (r *metricsRegistry) Histogram(name, help string, bounds []float64) *histogram {
+	h := &histogram{
+		name:   name,
+		help:   help,
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histograms = append(r.histograms, h)
+	return h
+}
+
+// Observe records one value
+func (h *histogram) Observe(v float64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+	h.sum += v
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	cumulative := uint64(0)
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatMetric(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatMetric(h.sum), h.name, h.count)
+}
+
+func (r *metricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	gauges := append([]gauge(nil), r.gauges...)
+	histograms := append([]*histogram(nil), r.histograms...)
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n",
+			g.name, g.help, g.name, g.name, formatMetric(g.value()))
+	}
+	for _, h := range histograms {
+		h.write(w)
+	}
+}
+
+func formatMetric(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// initMetrics registers the bot's metrics
+func (b *Bot) initMetrics() {
+	b.metrics = &metricsRegistry{}
+	b.metrics.Gauge("hype_copy_trades", "Paper trades booked",
+		func() float64 { return float64(b.paperTrader.GetTotalTrades()) })
+	b.metrics.Gauge("hype_copy_leverage", "Open notional over available capital",
+		func() float64 { return b.paperTrader.CurrentLeverage() })
+	b.metrics.Gauge("hype_copy_monitor_panics", "Monitor loop panics recovered",
+		func() float64 { return float64(atomic.LoadInt64(&b.monitorPanics)) })
+	b.metrics.Gauge("hype_copy_queue_dropped", "Fills dropped by queue backpressure",
+		func() float64 { return float64(atomic.LoadInt64(&b.queueDropped)) })
+
+	b.processSeconds = b.metrics.Histogram("hype_copy_process_seconds",
+		"Time to process one queued fill", processBuckets)
+	b.latencySeconds = b.metrics.Histogram("hype_copy_latency_seconds",
+		"Target fill time to our processing of it", latencyBuckets)
+}
+
+// observeLatency records how stale a copied fill was when we processed it
+func (b *Bot) observeLatency(fill *Fill) {
+	if b.latencySeconds == nil || fill.Time <= 0 {
+		return
+	}
+	latency := b.clock.Now().Sub(time.UnixMilli(fill.Time))
+	b.latencySeconds.Observe(math.Max(0, latency.Seconds()))
+}
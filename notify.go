@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier delivers a text message to an external channel
+type Notifier interface {
+	Notify(message string) error
+}
+
+// webhookNotifier posts messages as JSON to a Discord-style webhook
+type webhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+func newWebhookNotifier(url string) *webhookNotifier {
+	return &webhookNotifier{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *webhookNotifier) Notify(message string) error {
+	body, err := json.Marshal(map[string]string{"content": message, "text": message})
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.httpClient.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// alertQueueSize is how many notifications may wait for delivery before
+// new ones are dropped
+const alertQueueSize = 64
+
+// tradeAlerter turns trades into notifications, either one per trade or,
+// with a batch window, a single digest per window. Callbacks only queue;
+// run delivers, so a slow webhook never holds up trading.
+type tradeAlerter struct {
+	notifier Notifier
+	window   time.Duration
+	clock    Clock
+	queue    chan string
+
+	mu      sync.Mutex
+	pending []*PaperTrade
+}
+
+func newTradeAlerter(notifier Notifier, window time.Duration, clock Clock) *tradeAlerter {
+	return &tradeAlerter{notifier: notifier, window: window, clock: clock,
+		queue: make(chan string, alertQueueSize)}
+}
+
+// OnTrade is installed as the paper trader's trade callback
+func (a *tradeAlerter) OnTrade(trade *PaperTrade) {
+	if a.window <= 0 {
+		a.enqueue(formatTradeAlert(trade))
+		return
+	}
+
+	a.mu.Lock()
+	a.pending = append(a.pending, trade)
+	a.mu.Unlock()
+}
+
+// Flush sends a digest of pending trades, if any
+func (a *tradeAlerter) Flush() {
+	a.mu.Lock()
+	trades := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	if len(trades) > 0 {
+		a.send(formatDigest(trades))
+	}
+}
+
+// run delivers queued notifications, and with a batch window a digest at
+// the end of every window, until stop closes. It then drains the queue and
+// flushes the last digest.
+func (a *tradeAlerter) run(stop <-chan struct{}) {
+	var windowEnd <-chan time.Time
+	if a.window > 0 {
+		windowEnd = a.clock.After(a.window)
+	}
+	for {
+		select {
+		case message := <-a.queue:
+			a.send(message)
+		case <-windowEnd:
+			a.Flush()
+			windowEnd = a.clock.After(a.window)
+		case <-stop:
+			for {
+				select {
+				case message := <-a.queue:
+					a.send(message)
+				default:
+					a.Flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Alert queues an operator alert for immediate delivery, bypassing any
+// digest window
+func (a *tradeAlerter) Alert(message string) {
+	a.enqueue(message)
+}
+
+// enqueue hands message to run, dropping it when the queue is full
+func (a *tradeAlerter) enqueue(message string) {
+	select {
+	case a.queue <- message:
+	default:
+		log.Printf("Skipping notification: queue full")
+	}
+}
+
+func (a *tradeAlerter) send(message string) {
+	// Delivery failures must never affect trading
+	if err := a.notifier.Notify(message); err != nil {
+		log.Printf("Error sending notification: %v", err)
+	}
+}
+
+func formatTradeAlert(trade *PaperTrade) string {
+	return fmt.Sprintf("%s %s %.4f %s @ $%.2f | realized $%.2f",
+		trade.Action, trade.Side, trade.Size, trade.Coin, trade.Price, trade.RealizedPnL)
+}
+
+// formatDigest summarises trades, e.g. "5 trades, net +$120.00, 2 opens 3 reduces"
+func formatDigest(trades []*PaperTrade) string {
+	net := 0.0
+	counts := make(map[string]int)
+	for _, trade := range trades {
+		net += trade.RealizedPnL
+		counts[trade.Action]++
+	}
+
+	sign := "+"
+	if net < 0 {
+		sign = "-"
+		net = -net
+	}
+
+	parts := []string{}
+	for _, action := range []string{"OPEN", "ADD", "REDUCE", "CLOSE", "REVERSE", "TIMEOUT"} {
+		if n := counts[action]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %ss", n, strings.ToLower(action)))
+		}
+	}
+
+	return fmt.Sprintf("%d trades, net %s$%.2f, %s",
+		len(trades), sign, net, strings.Join(parts, " "))
+}
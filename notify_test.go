@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier captures messages instead of sending them
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recordingNotifier) Notify(message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *recordingNotifier) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+func TestNotificationDigest(t *testing.T) {
+	notifier := &recordingNotifier{}
+	clock := newFakeClock(time.Now())
+	alerter := newTradeAlerter(notifier, time.Minute, clock)
+
+	pt := NewTestPaperTrader()
+	pt.OnTrade = alerter.OnTrade
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		alerter.run(stop)
+		close(done)
+	}()
+
+	now := time.Now().Unix()
+	fills := []*Fill{
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now),
+		createTestFill("ETH", "B", 10.0, 4000.0, "0.0", now),
+		createTestFill("BTC", "A", 0.5, 52000.0, "1000.0", now),
+		createTestFill("ETH", "A", 5.0, 3900.0, "-500.0", now),
+		createTestFill("BTC", "A", 0.25, 53000.0, "750.0", now),
+	}
+	for _, fill := range fills {
+		pt.ProcessFill(fill)
+	}
+
+	if len(notifier.Messages()) != 0 {
+		t.Fatalf("Notifications sent before window closed: %v", notifier.Messages())
+	}
+
+	// Let the alerter arm its window timer, then close the window
+	deadline := time.Now().Add(time.Second)
+	for len(clock.Waits()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+
+	deadline = time.Now().Add(time.Second)
+	for len(notifier.Messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	messages := notifier.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Sent %d notifications, want 1 digest: %v", len(messages), messages)
+	}
+	expected := "5 trades, net +$1250.00, 2 opens 3 reduces"
+	if messages[0] != expected {
+		t.Errorf("Digest = %q, want %q", messages[0], expected)
+	}
+}
+
+func TestNotificationPerTrade(t *testing.T) {
+	notifier := &recordingNotifier{}
+	alerter := newTradeAlerter(notifier, 0, systemClock{})
+
+	pt := NewTestPaperTrader()
+	pt.OnTrade = alerter.OnTrade
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		alerter.run(stop)
+		close(done)
+	}()
+
+	now := time.Now().Unix()
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("BTC", "A", 1.0, 51000.0, "1000.0", now))
+
+	deadline := time.Now().Add(time.Second)
+	for len(notifier.Messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	if got := len(notifier.Messages()); got != 2 {
+		t.Errorf("Sent %d notifications without batching, want 2", got)
+	}
+}
+
+// blockingNotifier holds every delivery until release closes
+type blockingNotifier struct {
+	release chan struct{}
+}
+
+func (b *blockingNotifier) Notify(string) error {
+	<-b.release
+	return nil
+}
+
+func TestSlowWebhookNeverBlocksTrading(t *testing.T) {
+	notifier := &blockingNotifier{release: make(chan struct{})}
+	alerter := newTradeAlerter(notifier, 0, systemClock{})
+
+	pt := NewTestPaperTrader()
+	pt.OnTrade = alerter.OnTrade
+	pt.OnAlert = alerter.Alert
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		alerter.run(stop)
+		close(done)
+	}()
+
+	// More trades than the queue holds, all while delivery is stuck
+	traded := make(chan struct{})
+	go func() {
+		now := time.Now().Unix()
+		for i := 0; i < alertQueueSize+10; i++ {
+			fill := createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now)
+			fill.Hash = fmt.Sprintf("slow_%d", i)
+			pt.ProcessFill(fill)
+		}
+		pt.mu.Lock()
+		pt.alert("capital depleted")
+		pt.mu.Unlock()
+		close(traded)
+	}()
+	select {
+	case <-traded:
+	case <-time.After(time.Second):
+		t.Fatalf("Trading blocked on a stuck webhook")
+	}
+
+	close(notifier.release)
+	close(stop)
+	<-done
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TargetTracker follows the target's own fills without copying them: its
+// positions, realized PnL and fees as the exchange reports them
+type TargetTracker struct {
+	mu        sync.Mutex
+	positions map[string]float64 // signed size per coin
+	realized  float64            // sum of closedPnl
+	fees      float64
+	volume    float64 // notional traded
+	fills     int
+	wins      int // closing fills with positive closedPnl
+	losses    int
+}
+
+func NewTargetTracker() *TargetTracker {
+	return &TargetTracker{positions: make(map[string]float64)}
+}
+
+// Record applies one target fill
+func (t *TargetTracker) Record(fill *Fill) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	size := fill.Size
+	if !fill.Side.IsBuy() {
+		size = -size
+	}
+	t.positions[fill.Coin] = fill.StartPosition + size
+	if math.Abs(t.positions[fill.Coin]) < 1e-12 {
+		delete(t.positions, fill.Coin)
+	}
+
+	pnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+	fee, _ := strconv.ParseFloat(fill.Fee, 64)
+	t.realized += pnl
+	t.fees += fee
+	t.volume += fill.Size * fill.Price
+	t.fills++
+	switch {
+	case pnl > 0:
+		t.wins++
+	case pnl < 0:
+		t.losses++
+	}
+}
+
+// RealizedPnL returns the target's realized PnL before fees
+func (t *TargetTracker) RealizedPnL() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.realized
+}
+
+// PrintSummary prints the target's performance since we started watching
+func (t *TargetTracker) PrintSummary() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Println("🔭 TARGET PERFORMANCE (observe only)")
+	fmt.Println(strings.Repeat("=", 80))
+	fmt.Printf("💰 Realized PnL: $%.2f\n", t.realized)
+	fmt.Printf("💸 Fees: $%.2f\n", t.fees)
+	fmt.Printf("🎯 Net PnL: $%.2f\n", t.realized-t.fees)
+	fmt.Printf("📊 Fills: %d, $%.2f volume\n", t.fills, t.volume)
+	if closes := t.wins + t.losses; closes > 0 {
+		fmt.Printf("🏆 Win Rate: %.1f%% of %d closing fills\n",
+			float64(t.wins)/float64(closes)*100, closes)
+	}
+
+	if len(t.positions) > 0 {
+		fmt.Println("\n🔄 TARGET POSITIONS:")
+		fmt.Println(strings.Repeat("-", 60))
+		coins := make([]string, 0, len(t.positions))
+		for coin := range t.positions {
+			coins = append(coins, coin)
+		}
+		sort.Strings(coins)
+		for _, coin := range coins {
+			fmt.Printf("%-8s | %+.4f\n", coin, t.positions[coin])
+		}
+	}
+	fmt.Println(strings.Repeat("=", 80))
+}
+
+// observe records a fill in observe mode: deduplicated and validated like a
+// copy, but fed to the target tracker with no copy filters or paper trader
+func (b *Bot) observe(fill *Fill) {
+	b.mu.Lock()
+	key := fill.DedupKey()
+	if _, exists := b.processedFills[key]; exists {
+		b.mu.Unlock()
+		return
+	}
+	b.processedFills[key] = fill.Time
+	if err := fill.Normalize(); err != nil {
+		b.mu.Unlock()
+		log.Printf("Skipping invalid fill %s: %v", key, err)
+		return
+	}
+	fill.applyAlias(b.config.Trading.CoinAliases)
+	b.trackTargetPosition(fill)
+	b.mu.Unlock()
+
+	b.observer.Record(fill)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObserveModeTracksTargetOnly(t *testing.T) {
+	config := createTestConfig()
+	config.Mode = ModeObserve
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	now := time.Now().UnixMilli()
+	for _, fill := range []*Fill{
+		{Coin: "BTC", Side: SideBuy, Size: 2.0, Price: 50000.0, Hash: "obs1", Time: now, Fee: "10.0"},
+		{Coin: "BTC", Side: SideSell, Size: 1.0, Price: 52000.0, StartPosition: 2.0,
+			ClosedPnl: "2000.0", Hash: "obs2", Time: now + 1, Fee: "5.0"},
+		{Coin: "ETH", Side: SideSell, Size: 0.1, Price: 3000.0, Hash: "obs3", Time: now + 2}, // below copy threshold
+		{Coin: "BTC", Side: SideSell, Size: 1.0, Price: 52000.0, StartPosition: 2.0,
+			ClosedPnl: "2000.0", Hash: "obs2", Time: now + 1}, // repeat
+	} {
+		if err := bot.process(fill); err != nil {
+			t.Fatalf("process(%s) error = %v", fill.Hash, err)
+		}
+	}
+
+	if pnl := bot.observer.RealizedPnL(); math.Abs(pnl-2000.0) > 1e-9 {
+		t.Errorf("Target realized PnL = %.2f, want 2000.00", pnl)
+	}
+	if len(bot.paperTrader.Positions) != 0 || bot.paperTrader.GetTotalTrades() != 0 {
+		t.Errorf("Observe mode should not paper trade, got %d positions, %d trades",
+			len(bot.paperTrader.Positions), bot.paperTrader.GetTotalTrades())
+	}
+
+	output := captureStdout(t, bot.printSummary)
+	for _, want := range []string{"TARGET PERFORMANCE", "Realized PnL: $2000.00", "Net PnL: $1985.00",
+		"Fills: 3", "BTC      | +1.0000", "ETH      | -0.1000"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Summary missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "PAPER TRADING") {
+		t.Errorf("Observe summary should not show the paper portfolio:\n%s", output)
+	}
+}
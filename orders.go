@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sync"
+)
+
+// CopyOrderTracker nets unfilled copy orders against new copy signals so a
+// partial fill followed by another signal doesn't over-order beyond the
+// target's intent. Sizes are signed: positive = long, negative = short.
+type CopyOrderTracker struct {
+	mu      sync.Mutex
+	filled  map[string]float64 // position actually filled per coin
+	working map[string]float64 // placed but still unfilled per coin
+}
+
+func NewCopyOrderTracker() *CopyOrderTracker {
+	return &CopyOrderTracker{
+		filled:  make(map[string]float64),
+		working: make(map[string]float64),
+	}
+}
+
+// Signal returns the signed size to order so that filled plus working size
+// reaches the desired position, and records that size as working
+func (t *CopyOrderTracker) Signal(coin string, desired float64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	order := desired - t.filled[coin] - t.working[coin]
+	if math.Abs(order) < 1e-12 {
+		return 0
+	}
+	t.working[coin] += order
+	return order
+}
+
+// OnFill records an execution of our copy order, moving size from working
+// to filled
+func (t *CopyOrderTracker) OnFill(coin string, size float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.filled[coin] += size
+	t.working[coin] -= size
+	if math.Abs(t.working[coin]) < 1e-12 {
+		t.working[coin] = 0
+	}
+}
+
+// Add records an order placed outside Signal, such as a TWAP child, as
+// working
+func (t *CopyOrderTracker) Add(coin string, size float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.working[coin] += size
+}
+
+// OnCancel drops whatever is still working for a coin (cancel or expiry)
+func (t *CopyOrderTracker) OnCancel(coin string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.working[coin] = 0
+}
+
+// Outstanding returns the unfilled working size for a coin
+func (t *CopyOrderTracker) Outstanding(coin string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.working[coin]
+}
+
+// syncRealPosition places a real order moving our account toward the paper
+// position for coin, net of any copy orders still working
+func (b *Bot) syncRealPosition(coin string, price float64) {
+	if b.orders == nil {
+		return
+	}
+
+	b.paperTrader.mu.Lock()
+	if b.paperTrader.twaps[coin] != nil {
+		// Child orders are already working toward the target
+		b.paperTrader.mu.Unlock()
+		return
+	}
+	desired := 0.0
+	if pos, exists := b.paperTrader.Positions[coin]; exists {
+		desired = pos.Size
+	}
+	b.paperTrader.mu.Unlock()
+
+	size := b.orders.Signal(coin, desired)
+	if size == 0 {
+		return
+	}
+
+	if err := b.placeOrder(coin, size, price); err != nil {
+		log.Printf("Error placing order for %s: %v", coin, err)
+		b.orders.OnCancel(coin)
+	}
+}
+
+// placeOrder sends a limit order for signed size at price
+func (b *Bot) placeOrder(coin string, size, price float64) error {
+	side := SideBuy
+	if size < 0 {
+		side = SideSell
+	}
+	order := &Order{
+		Coin:  coin,
+		Side:  side,
+		Size:  math.Abs(size),
+		Price: price,
+		Type:  "limit",
+	}
+	if err := b.api().PlaceOrder(order); err != nil {
+		b.shadow.Reject(coin)
+		return err
+	}
+	b.shadow.Expect(coin, size, price)
+	log.Printf("order: %s %.4f %s@%.2f", side, order.Size, coin, price)
+	return nil
+}
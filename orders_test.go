@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCopyOrderTrackerPartialFill(t *testing.T) {
+	tracker := NewCopyOrderTracker()
+
+	// Copy signal wants us long 10 BTC
+	if size := tracker.Signal("BTC", 10.0); size != 10.0 {
+		t.Fatalf("First order size = %f, want 10", size)
+	}
+
+	// Only 4 of the 10 fill; 6 stay working on the book
+	tracker.OnFill("BTC", 4.0)
+	if outstanding := tracker.Outstanding("BTC"); math.Abs(outstanding-6.0) > 1e-9 {
+		t.Errorf("Outstanding = %f, want 6", outstanding)
+	}
+
+	// Target adds, so we now want 12: only the 2 shortfall is ordered
+	if size := tracker.Signal("BTC", 12.0); math.Abs(size-2.0) > 1e-9 {
+		t.Errorf("Top-up order size = %f, want 2 (not the full 12)", size)
+	}
+
+	// Same signal again orders nothing
+	if size := tracker.Signal("BTC", 12.0); size != 0 {
+		t.Errorf("Repeated signal order size = %f, want 0", size)
+	}
+
+	// Once the working remainder is cancelled, the shortfall is re-ordered
+	tracker.OnCancel("BTC")
+	if size := tracker.Signal("BTC", 12.0); math.Abs(size-8.0) > 1e-9 {
+		t.Errorf("Order after cancel = %f, want 8", size)
+	}
+
+	// Closing nets against filled size only
+	tracker.OnCancel("BTC")
+	if size := tracker.Signal("BTC", 0.0); math.Abs(size+4.0) > 1e-9 {
+		t.Errorf("Close order size = %f, want -4", size)
+	}
+}
+
+func TestRealModeTopsUpShortfall(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Orders []map[string]interface{} `json:"orders"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		for _, order := range payload.Orders {
+			sizes = append(sizes, order["s"].(string))
+		}
+		mu.Unlock()
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.PaperTradingOnly = false
+	config.CopyThreshold = 100.0
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.client.assets = testAssets
+	bot.paperTrader = NewTestPaperTrader()
+
+	now := time.Now().UnixMilli()
+	bot.process(&Fill{Coin: "BTC", Side: "B", Size: 10.0, Price: 50000.0, Hash: "real1", Time: now})
+
+	// Our order only partially fills
+	bot.orders.OnFill("BTC", 4.0)
+
+	bot.process(&Fill{Coin: "BTC", Side: "B", Size: 2.0, Price: 50000.0, Hash: "real2", Time: now})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sizes) != 2 || sizes[0] != "10.000000" || sizes[1] != "2.000000" {
+		t.Errorf("Placed order sizes = %v, want [10.000000 2.000000]", sizes)
+	}
+}
+
+func TestOrdersPlacedForSubAccount(t *testing.T) {
+	var vault interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		vault = payload["vaultAddress"]
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.SubAccount = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.client.assets = testAssets
+
+	if err := bot.placeOrder("BTC", 0.5, 50000.0); err != nil {
+		t.Fatalf("placeOrder() error = %v", err)
+	}
+	if vault != config.SubAccount {
+		t.Errorf("vaultAddress = %v, want %s", vault, config.SubAccount)
+	}
+}
+
+func TestExchangeActionsUseAssetIndex(t *testing.T) {
+	var mu sync.Mutex
+	var assets []interface{}
+	metaRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		switch payload["type"] {
+		case "meta":
+			metaRequests++
+			w.Write([]byte(`{"universe":[{"name":"BTC"},{"name":"ETH"}]}`))
+			return
+		case "updateLeverage":
+			assets = append(assets, payload["asset"])
+		case "order":
+			orders := payload["orders"].([]interface{})
+			assets = append(assets, orders[0].(map[string]interface{})["a"])
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	if err := bot.client.UpdateLeverage("ETH", 5, true); err != nil {
+		t.Fatalf("UpdateLeverage() error = %v", err)
+	}
+	if err := bot.placeOrder("ETH", 0.5, 3000.0); err != nil {
+		t.Fatalf("placeOrder() error = %v", err)
+	}
+	if err := bot.client.UpdateLeverage("DOGE", 5, true); err == nil {
+		t.Errorf("Expected error for a coin missing from meta")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(assets) != 2 || assets[0] != 1.0 || assets[1] != 1.0 {
+		t.Errorf("Asset fields = %v, want [1 1] (ETH's universe index)", assets)
+	}
+	if metaRequests != 2 {
+		t.Errorf("Meta requests = %d, want 2 (one cached lookup, one refresh on miss)", metaRequests)
+	}
+}
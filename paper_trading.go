@@ -1,19 +1,28 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+var (
+	ErrNoPosition = errors.New("no open position")
+	ErrNoMark     = errors.New("no mark price")
+)
+
 type PaperTrader struct {
 	mu                 sync.Mutex
 	Positions          map[string]*Position
-	TotalRealizedPnL   float64
+	TotalRealizedPnL   float64 // gross trading PnL, before fees and funding
+	TotalFees          float64 // trading fees paid, net of maker rebates
+	TotalFunding       float64 // funding received (negative when paid)
 	TotalTrades        int
 	StartTime          time.Time
 	TradeHistory       []*PaperTrade
@@ -28,6 +37,74 @@ type PaperTrader struct {
 	Leverage           float64              // Maximum leverage multiplier
 	BaseNotional       float64              // Base trade size in USD
 	DisableDynamicSize bool                 // For testing: disable dynamic sizing and use exact fill sizes
+	DisableLimits      bool                 // For analysis: size at full base notional, never reject
+	TargetLeverage     map[string]float64   // Target's leverage per coin, for reporting
+	FundingRate        map[string]float64   // latest hourly funding rate per coin
+	MirrorTargetLev    bool                 // cap each coin at the target's leverage, not ours
+	OnTrade            func(*PaperTrade)    // Called with pt.mu held after each trade
+	Clock              Clock                // Time source, system clock when nil
+	MaxMarkAge         time.Duration        // Marks older than this pause risk checks
+	MarkTime           map[string]time.Time // When each coin's price was last marked
+	CostBasis          string               // "average" (default) or "fifo"
+	Tags               map[string]string    // Copied onto each position when it opens
+	SubAccount         string               // sub-account copied into, saved apart from the rest
+	ImpactCoefficient  float64              // sqrt price impact per sqrt(USD) copied, 0 = off
+	SlippageBps        float64              // adverse slippage on a $10k copy, in bps
+	QuoteRate          float64              // our quote units per target quote unit, 0 = 1:1
+	QuoteCurrency      string               // label of our quote currency, "" = USDC
+	FullSnapshotEvery  int                  // write account diffs between full snapshots, 0 = always full
+	StorageDecimals    int                  // decimals kept on saved floats, 0 = 8
+	MaxReversalsPerMin int                  // per-coin REVERSE limit per minute, 0 = unlimited
+	SizeDecimals       map[string]int       // venue size precision per coin, unrounded when absent
+	SizeRounding       string               // "down" (default), "nearest" or "up"
+	SizeEpsilon        float64              // positions below this snap flat, 0 = 1e-9
+	MinSizes           map[string]float64   // smallest closable position per coin
+	Cashflows          []Cashflow           // deposits and withdrawals, in order
+	MaxHold            time.Duration        // auto-close positions open longer, 0 = off
+	StopLossPct        float64              // stop set on each new position, 0 = none
+	TakeProfitPct      float64              // take profit set on each new position, 0 = none
+	TakeProfitLadder   []TakeProfitRung     // trims at rising gains on entry, lowest first
+	OnCapacityExhaust  string               // "skip" (default), "scale_existing" or "reject_and_alert"
+	OnAlert            func(string)         // Called with pt.mu held for operator alerts
+	TWAPSlices         int                  // execute copies in this many slices, <= 1 = instantly
+	TWAPWindow         time.Duration        // window the slices are spread across
+	QueueTWAPChildren  bool                 // queue each slice for TakeTWAPChildren (real mode)
+	PositionSort       string               // summary order: "notional" (default), "coin" or "pnl"
+	CoinAliases        map[string]string    // renamed coins, old symbol -> new
+	HedgeGroups        [][]string           // correlated coins whose opens net while pending
+	FeeWarnPercent     float64              // warn when fees exceed this % of gross PnL, 0 = 50
+	CompoundFraction   float64              // share of net realized PnL added to base notional
+	FormHalfLife       time.Duration        // half-life of target PnL in performance weighting, 0 = off
+	FormWeight         float64              // most performance weighting scales a copy, 0 = 0.5
+	TakerFeeBps        float64              // fee on copies of crossing fills
+	MakerFeeBps        float64              // fee on copies of resting fills, negative = rebate
+	LatencyCount       int                  // fills with a measured copy latency
+	LatencyTotal       time.Duration        // sum of target fill time to processing time
+	LatencyMax         time.Duration        // worst copy latency seen
+	EvictFlatAfter     time.Duration        // forget coins flat this long, 0 = never
+	EvictedRealized    float64              // realized PnL of evicted coins
+	EvictedCoins       int                  // coins evicted so far
+	AggregateByOrder   bool                 // batch fills by parent order id instead of volume
+	FlushOnSideChange  bool                 // book pending fills before one opposing their net side
+	MaxPendingFills    int                  // book a coin's batch at this many fills, 0 = no cap
+	PerCoinBankroll    bool                 // size and limit each coin within its own bankroll slice
+	CoinBankrolls      map[string]float64   // configured slices, in our quote
+	BankrollCoins      []string             // coins sharing the unconfigured rest equally
+	TakerFills         int                  // target fills that crossed the book
+	MakerFills         int                  // target fills that rested on the book
+	RealizedByAction   map[string]float64   // TotalRealizedPnL by the action that booked it
+	EquityPeak         float64              // highest equity seen, drawdown is measured from it
+	riskDegraded       bool
+	reduceOnly         bool // capital depleted, only reduce or close
+
+	reversals      map[string][]time.Time     // recent REVERSE times per coin
+	form           targetForm                 // target's decayed realized PnL
+	feedMarks      map[string]feedMark        // latest mark feed price per coin, held or not
+	twaps          map[string]*twapExecution  // sliced copies in progress per coin
+	twapChildren   []twapChild                // executed slices not yet taken for placement
+	lastSnapshot   map[string]AccountPosition // positions in the last accounts record
+	snapshotFile   string                     // file the last accounts record went to
+	diffsSinceFull int
 }
 
 type Position struct {
@@ -39,6 +116,18 @@ type Position struct {
 	LastPrice      float64 // for unrealized PnL calculation
 	OpenTime       time.Time
 	TradeCount     int
+	Lots           []Lot             // open lots, oldest first (FIFO cost basis only)
+	Tags           map[string]string // set at open, e.g. originating target and copy mode
+	LadderBase     float64           // largest size since open, take-profit trims are sized on it
+	LadderRungs    int               // take-profit rungs already fired
+	StopLossPct    float64           // close once price moves this % against entry, 0 = off
+	TakeProfitPct  float64           // close once unrealized PnL is this % of cost basis, 0 = off
+}
+
+// Lot is one entry into a position at a single price
+type Lot struct {
+	Size  float64 // absolute size
+	Price float64
 }
 
 type PaperTrade struct {
@@ -51,6 +140,7 @@ type PaperTrade struct {
 	RealizedPnL   float64
 	PositionSize  float64 // position after this trade
 	UnrealizedPnL float64
+	Fee           float64 // negative for a maker rebate
 }
 
 type PositionAction int
@@ -61,6 +151,7 @@ const (
 	ActionReduce
 	ActionClose
 	ActionReverse
+	ActionTimeout // closed after exceeding the maximum holding time
 )
 
 func (pa PositionAction) String() string {
@@ -75,6 +166,8 @@ func (pa PositionAction) String() string {
 		return "CLOSE"
 	case ActionReverse:
 		return "REVERSE"
+	case ActionTimeout:
+		return "TIMEOUT"
 	default:
 		return "UNKNOWN"
 	}
@@ -92,20 +185,25 @@ func (pa PositionAction) Emoji() string {
 		return "🔴"
 	case ActionReverse:
 		return "🔄"
+	case ActionTimeout:
+		return "⏰"
 	default:
 		return "❓"
 	}
 }
 
-func NewPaperTrader(bankroll, leverage, baseNotional float64) *PaperTrader {
+func NewPaperTrader(bankroll, leverage, baseNotional float64, opts ...SimOptions) *PaperTrader {
+	sim := resolveSimOptions(opts)
 	return &PaperTrader{
+		Clock:            sim.Clock,
 		Positions:        make(map[string]*Position),
-		StartTime:        time.Now(),
+		StartTime:        sim.Clock.Now(),
 		TradeHistory:     make([]*PaperTrade, 0),
 		LastTradeTime:    make(map[string]time.Time),
 		PendingFills:     make(map[string][]*Fill),
 		PendingVolume:    make(map[string]float64),
 		LastVolumeUpdate: make(map[string]time.Time),
+		MaxMarkAge:       60 * time.Second,
 		MinTradeInterval: 60 * time.Second, // 1 minute minimum between trades
 		VolumeThreshold:  1000.0,           // $1000 volume threshold to trigger trade
 		VolumeDecayRate:  0.5,              // 50% decay per minute
@@ -135,36 +233,296 @@ func NewTestPaperTrader() *PaperTrader {
 	}
 }
 
-// calculateAvailableCapital returns the current available capital for trading
-// Available capital = initial bankroll + realized PnL + unrealized PnL
+// quoteRate converts target-quoted prices and PnL into our quote currency
+func (pt *PaperTrader) quoteRate() float64 {
+	if pt.QuoteRate <= 0 {
+		return 1.0
+	}
+	return pt.QuoteRate
+}
+
+// calculateAvailableCapital returns the current available capital for
+// trading, clamped at zero so losses beyond the bankroll never produce
+// negative capacity
 func (pt *PaperTrader) calculateAvailableCapital() float64 {
-	// Start with initial bankroll and realized PnL
-	availableCapital := pt.Bankroll + pt.TotalRealizedPnL
+	return math.Max(0, pt.equity())
+}
 
-	// Add unrealized PnL from all positions
+// equity returns bankroll + realized PnL net of fees and funding +
+// unrealized PnL, negative once losses exceed the bankroll
+func (pt *PaperTrader) equity() float64 {
+	// Net realized PnL plus unrealized PnL from all positions
+	pnl := pt.netRealizedPnL()
 	for _, position := range pt.Positions {
 		if position.Size != 0 {
-			availableCapital += pt.calculateUnrealizedPnL(position)
+			pnl += pt.calculateUnrealizedPnL(position)
 		}
 	}
 
-	return availableCapital
+	// Bankroll is in our quote, PnL in the target's
+	return pt.Bankroll + pnl*pt.quoteRate()
+}
+
+// sizingCapital returns the capital coin is sized and limited against: the
+// whole account, or in per-coin bankroll mode only coin's own slice plus
+// the PnL coin made on it
+func (pt *PaperTrader) sizingCapital(coin string) float64 {
+	if !pt.PerCoinBankroll {
+		return pt.calculateAvailableCapital()
+	}
+	pnl := 0.0
+	if position, exists := pt.Positions[coin]; exists {
+		pnl = position.RealizedPnL
+		if position.Size != 0 {
+			pnl += pt.calculateUnrealizedPnL(position)
+		}
+	}
+	return math.Max(0, pt.coinBankroll(coin)+pnl*pt.quoteRate())
+}
+
+// sharesCapital reports whether other's notional uses up coin's capital
+func (pt *PaperTrader) sharesCapital(coin, other string) bool {
+	return !pt.PerCoinBankroll || coin == other
+}
+
+// coinBankroll returns coin's bankroll slice: its configured amount, or an
+// equal share of the unconfigured rest among BankrollCoins. Coins with
+// neither get nothing.
+func (pt *PaperTrader) coinBankroll(coin string) float64 {
+	if slice, exists := pt.CoinBankrolls[coin]; exists {
+		return slice
+	}
+	rest := pt.Bankroll
+	for _, slice := range pt.CoinBankrolls {
+		rest -= slice
+	}
+	sharing, listed := 0, false
+	for _, other := range pt.BankrollCoins {
+		if _, configured := pt.CoinBankrolls[other]; !configured {
+			sharing++
+			listed = listed || other == coin
+		}
+	}
+	if !listed || rest <= 0 {
+		return 0
+	}
+	return rest / float64(sharing)
+}
+
+// checkCapital switches the trader into reduce-only mode once available
+// capital is gone, alerting once on entry, and back out on recovery.
+// Analysis runs without limits never become reduce-only.
+func (pt *PaperTrader) checkCapital() {
+	if equity := pt.equity(); equity > pt.EquityPeak {
+		pt.EquityPeak = equity
+	}
+	depleted := !pt.DisableLimits && pt.equity() <= 0
+	if depleted && !pt.reduceOnly {
+		log.Printf("Capital depleted (equity $%.2f): reduce-only until it recovers", pt.equity())
+		pt.alert(fmt.Sprintf("capital depleted (equity $%.2f): new positions blocked, reduce-only", pt.equity()))
+	} else if !depleted && pt.reduceOnly {
+		log.Println("bot: capital recovered, new positions allowed")
+	}
+	pt.reduceOnly = depleted
+}
+
+// Drawdown returns how far equity is below its peak, 0 at a new high
+func (pt *PaperTrader) Drawdown() float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.drawdown()
+}
+
+func (pt *PaperTrader) drawdown() float64 {
+	return math.Max(0, pt.EquityPeak-pt.equity())
+}
+
+// Reduces reports whether fill trades against our position in its coin
+func (pt *PaperTrader) Reduces(fill *Fill) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.reduces(fill.Coin, fill.Side)
+}
+
+// capacityProblem reports why an opening copy of fill would be rejected for
+// capital, or "" when it can be sized. Only the default skip policy rejects
+// for capacity; the others resolve it when the trade books.
+func (pt *PaperTrader) capacityProblem(fill *Fill) string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.DisableDynamicSize || pt.DisableLimits {
+		return ""
+	}
+	if pt.reduceOnly {
+		return "capital depleted, reduce-only"
+	}
+	if (pt.OnCapacityExhaust == "" || pt.OnCapacityExhaust == "skip") &&
+		pt.calculateDynamicTradeSize(fill) == 0 {
+		return "no capacity left"
+	}
+	return ""
+}
+
+// Holds reports whether we have an open position in coin
+func (pt *PaperTrader) Holds(coin string) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	position, exists := pt.Positions[coin]
+	return exists && position.Size != 0
+}
+
+// ReduceOnly reports whether depleted capital is blocking new positions
+func (pt *PaperTrader) ReduceOnly() bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.reduceOnly
+}
+
+// leverageWarnRatio is the share of the leverage cap that triggers warnings
+const leverageWarnRatio = 0.8
+
+// CurrentLeverage returns open notional divided by available capital
+func (pt *PaperTrader) CurrentLeverage() float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.currentLeverage()
+}
+
+func (pt *PaperTrader) currentLeverage() float64 {
+	notional := 0.0
+	for _, pos := range pt.Positions {
+		if pos.Size != 0 {
+			notional += math.Abs(pos.Size*pos.LastPrice) * pt.quoteRate()
+		}
+	}
+
+	capital := pt.calculateAvailableCapital()
+	if capital <= 0 {
+		if notional > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return notional / capital
+}
+
+// Exposure returns open notional in our quote at current marks: long and
+// short totals (both positive), net (long minus short) and gross (their sum)
+func (pt *PaperTrader) Exposure() (long, short, net, gross float64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.exposure()
+}
+
+func (pt *PaperTrader) exposure() (long, short, net, gross float64) {
+	for _, pos := range pt.Positions {
+		notional := pos.Size * pos.LastPrice * pt.quoteRate()
+		if notional > 0 {
+			long += notional
+		} else {
+			short -= notional
+		}
+	}
+	return long, short, long - short, long + short
+}
+
+// tradeFee returns the fee on a trade of signed tradeSize at price, in the
+// same units as realized PnL. Fees are charged at the rate the copied
+// target fills paid: each fill's own fee over its notional when the API
+// supplied one in USDC, else the maker fee for fills that didn't cross and
+// the taker fee for those that did. Fees in other tokens can't be valued
+// against notional, so they take the bps fallback. No fills means we took.
+func (pt *PaperTrader) tradeFee(tradeSize, price float64, fills []*Fill) float64 {
+	var feeValue, totalValue float64
+	for _, fill := range fills {
+		value := fill.Size * fill.Price
+		totalValue += value
+		usdc := fill.FeeToken == "" || fill.FeeToken == "USDC"
+		if fee, err := strconv.ParseFloat(fill.Fee, 64); err == nil && usdc {
+			feeValue += fee
+		} else if fill.Crossed {
+			feeValue += value * pt.TakerFeeBps / 10000
+		} else {
+			feeValue += value * pt.MakerFeeBps / 10000
+		}
+	}
+	rate := pt.TakerFeeBps / 10000
+	if totalValue > 0 {
+		rate = feeValue / totalValue
+	}
+	return math.Abs(tradeSize) * price * rate
+}
+
+// addRealized books realized PnL under the action that produced it
+func (pt *PaperTrader) addRealized(action string, pnl float64) {
+	pt.TotalRealizedPnL += pnl
+	if pnl == 0 {
+		return
+	}
+	if pt.RealizedByAction == nil {
+		pt.RealizedByAction = make(map[string]float64)
+	}
+	pt.RealizedByAction[action] += pnl
+}
+
+// netRealizedPnL returns realized PnL after fees and funding
+func (pt *PaperTrader) netRealizedPnL() float64 {
+	return pt.TotalRealizedPnL - pt.TotalFees + pt.TotalFunding
+}
+
+// leverage returns the leverage coin's capital checks use: the target's
+// own on that coin when mirrored and known, else the configured one
+func (pt *PaperTrader) leverage(coin string) float64 {
+	if leverage := pt.TargetLeverage[coin]; pt.MirrorTargetLev && leverage > 0 {
+		return leverage
+	}
+	return pt.Leverage
+}
+
+// slippageReferenceNotional is the copy size SlippageBps is quoted at
+const slippageReferenceNotional = 10000.0
+
+// impactPrice worsens price by slippage plus a square-root impact model:
+// buys pay up and sells give up SlippageBps/10000 scaled by
+// sqrt(notional / slippageReferenceNotional), plus
+// ImpactCoefficient * sqrt(notional), as a fraction of price
+func (pt *PaperTrader) impactPrice(price, size float64) float64 {
+	if (pt.ImpactCoefficient <= 0 && pt.SlippageBps <= 0) || size == 0 {
+		return price
+	}
+	notional := math.Abs(size) * price
+	impact := pt.SlippageBps/10000*math.Sqrt(notional/slippageReferenceNotional) +
+		pt.ImpactCoefficient*math.Sqrt(notional)
+	if size > 0 {
+		return price * (1 + impact)
+	}
+	return price * math.Max(0, 1-impact)
 }
 
 // calculateDynamicTradeSize determines the appropriate trade size based on available capital
 func (pt *PaperTrader) calculateDynamicTradeSize(fill *Fill) float64 {
-	availableCapital := pt.calculateAvailableCapital()
+	// Base notional and capital are in our quote currency
+	price := fill.Price * pt.quoteRate()
+	baseNotional := pt.baseNotional() * pt.performanceFactor()
+
+	if pt.DisableLimits {
+		return pt.roundSize(fill.Coin, baseNotional/price)
+	}
+
+	availableCapital := pt.sizingCapital(fill.Coin)
 
 	// Calculate currently used capital (total position value)
 	usedCapital := 0.0
-	for _, pos := range pt.Positions {
-		if pos.Size != 0 {
-			usedCapital += math.Abs(pos.Size * pos.LastPrice)
+	for coin, pos := range pt.Positions {
+		if pos.Size != 0 && pt.sharesCapital(fill.Coin, coin) {
+			usedCapital += math.Abs(pos.Size*pos.LastPrice) * pt.quoteRate()
 		}
 	}
 
 	// Maximum allowed capital usage
-	maxCapital := availableCapital * pt.Leverage
+	maxCapital := availableCapital * pt.leverage(fill.Coin)
 
 	// How much capital can we still use?
 	remainingCapital := maxCapital - usedCapital
@@ -175,16 +533,88 @@ func (pt *PaperTrader) calculateDynamicTradeSize(fill *Fill) float64 {
 	}
 
 	// Try to use the full base notional, but never exceed remaining capital
-	finalNotional := math.Min(pt.BaseNotional, remainingCapital)
+	finalNotional := math.Min(baseNotional, remainingCapital)
 
 	// If the remaining capital is too small to be meaningful, skip the trade
-	if finalNotional < pt.BaseNotional*0.1 { // Less than 10% of base notional
+	if finalNotional < baseNotional*0.1 { // Less than 10% of base notional
 		return 0
 	}
 
 	// Convert notional to trade size based on fill price
-	tradeSize := finalNotional / fill.Price
+	tradeSize := finalNotional / price
+
+	return pt.roundSize(fill.Coin, tradeSize)
+}
+
+// baseNotional returns the notional a copy aims for: BaseNotional plus
+// CompoundFraction of net realized PnL, so sizing grows with banked
+// profits but not with open gains
+func (pt *PaperTrader) baseNotional() float64 {
+	if pt.CompoundFraction <= 0 {
+		return pt.BaseNotional
+	}
+	// Realized PnL is in the target's quote
+	return math.Max(0, pt.BaseNotional+pt.CompoundFraction*pt.netRealizedPnL()*pt.quoteRate())
+}
+
+// roundSize rounds a computed size to the coin's venue precision. Rounding
+// down never exceeds the capacity the size was computed from.
+func (pt *PaperTrader) roundSize(coin string, size float64) float64 {
+	decimals, exists := pt.SizeDecimals[coin]
+	if !exists {
+		return size
+	}
 
+	scale := math.Pow(10, float64(decimals))
+	scaled := size * scale
+	// Absorb float error so exact boundaries like 0.3 stay put
+	const epsilon = 1e-9
+	switch pt.SizeRounding {
+	case "nearest":
+		scaled = math.Round(scaled)
+	case "up":
+		scaled = math.Ceil(scaled - epsilon)
+	default:
+		scaled = math.Floor(scaled + epsilon)
+	}
+	return scaled / scale
+}
+
+// defaultSizeEpsilon is the flat threshold for coins without size decimals
+const defaultSizeEpsilon = 1e-9
+
+// sizeEpsilon returns the size below which coin's position counts as flat:
+// half the coin's smallest lot when its size decimals are known, so dust
+// can't survive rounding, else SizeEpsilon
+func (pt *PaperTrader) sizeEpsilon(coin string) float64 {
+	if decimals, exists := pt.SizeDecimals[coin]; exists {
+		return 0.5 * math.Pow(10, -float64(decimals))
+	}
+	if pt.SizeEpsilon > 0 {
+		return pt.SizeEpsilon
+	}
+	return defaultSizeEpsilon
+}
+
+// minSize returns the smallest position coin can be closed from: its
+// configured minimum, else one lot of its venue precision, else 0
+func (pt *PaperTrader) minSize(coin string) float64 {
+	if size := pt.MinSizes[coin]; size > 0 {
+		return size
+	}
+	if decimals, exists := pt.SizeDecimals[coin]; exists {
+		return math.Pow(10, -float64(decimals))
+	}
+	return 0
+}
+
+// snapFlat extends tradeSize to flatten position when it would leave only
+// dust behind
+func (pt *PaperTrader) snapFlat(position *Position, tradeSize float64) float64 {
+	remaining := position.Size + tradeSize
+	if remaining != 0 && position.Size != 0 && math.Abs(remaining) < pt.sizeEpsilon(position.Coin) {
+		return -position.Size
+	}
 	return tradeSize
 }
 
@@ -197,35 +627,65 @@ func (pt *PaperTrader) validatePositionSize(
 	// Calculate total position value across all coins
 	totalPositionValue := 0.0
 
+	// Shrinking a position never needs more capital
+	if pos, exists := pt.Positions[coin]; exists &&
+		math.Abs(newSize) <= math.Abs(pos.Size) && newSize*pos.Size >= 0 {
+		return true
+	}
+
 	// Sum existing positions
 	for coinName, pos := range pt.Positions {
-		if coinName != coin {
+		if coinName != coin && pt.sharesCapital(coin, coinName) {
 			totalPositionValue += math.Abs(pos.Size * pos.LastPrice)
 		}
 	}
 
 	// Add the new position value
 	totalPositionValue += math.Abs(newSize * price)
+	totalPositionValue *= pt.quoteRate()
 
 	// Check against available capital * leverage limit
-	availableCapital := pt.calculateAvailableCapital()
-	maxPositionValue := availableCapital * pt.Leverage
+	availableCapital := pt.sizingCapital(coin)
+	maxPositionValue := availableCapital * pt.leverage(coin)
 
 	return totalPositionValue <= maxPositionValue
 }
 
+// ProcessFill books a fill that passed Fill.Normalize
 func (pt *PaperTrader) ProcessFill(fill *Fill) {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
 
-	// Skip zero-size trades entirely
-	if fill.Size == 0 {
-		return
+	fill.applyAlias(pt.CoinAliases)
+	pt.migrateAliases()
+	pt.recordLatency(fill)
+	pt.recordTargetPnL(fill)
+
+	// A maker-heavy target is harder to copy since our copies always take
+	if fill.Crossed {
+		pt.TakerFills++
+	} else {
+		pt.MakerFills++
 	}
 
 	// Update real-time price for existing position (if any)
 	pt.updateRealTimePrice(fill.Coin, fill.Price)
 
+	// A hedging leg only adds the exposure its group's pending fills lack
+	if fill = pt.netHedge(fill); fill == nil {
+		return
+	}
+
+	if pt.AggregateByOrder && fill.Oid != 0 {
+		pt.aggregateByOrder(fill)
+		return
+	}
+
+	// A turn in the target's direction starts a new batch
+	if pt.FlushOnSideChange && pt.opposesPending(fill) {
+		pt.processAggregatedFills(fill.Coin)
+	}
+
 	// Add fill to pending queue
 	if pt.PendingFills[fill.Coin] == nil {
 		pt.PendingFills[fill.Coin] = make([]*Fill, 0)
@@ -241,7 +701,7 @@ func (pt *PaperTrader) ProcessFill(fill *Fill) {
 
 	// Track when volume accumulation started for this coin
 	if _, exists := pt.LastVolumeUpdate[fill.Coin]; !exists {
-		pt.LastVolumeUpdate[fill.Coin] = time.Now()
+		pt.LastVolumeUpdate[fill.Coin] = pt.now()
 	}
 
 	// Check if we should process trades (volume threshold OR time threshold)
@@ -251,16 +711,99 @@ func (pt *PaperTrader) ProcessFill(fill *Fill) {
 	shouldProcessByTime := false
 	if pt.PendingVolume[fill.Coin] > 0 {
 		volumeStartTime, timeExists := pt.LastVolumeUpdate[fill.Coin]
-		if timeExists && time.Since(volumeStartTime) >= pt.MinTradeInterval {
+		if timeExists && pt.now().Sub(volumeStartTime) >= pt.MinTradeInterval {
 			shouldProcessByTime = true
 		}
 	}
 
-	if shouldProcessByVolume || shouldProcessByTime {
+	// Bound the batch when the thresholds keep it open
+	shouldProcessByCount := pt.MaxPendingFills > 0 && len(pt.PendingFills[fill.Coin]) >= pt.MaxPendingFills
+	if shouldProcessByCount && !shouldProcessByVolume && !shouldProcessByTime {
+		log.Printf("%s: %d pending fills reached the cap, booking them", fill.Coin, len(pt.PendingFills[fill.Coin]))
+	}
+
+	if shouldProcessByVolume || shouldProcessByTime || shouldProcessByCount {
 		pt.processAggregatedFills(fill.Coin)
 	}
 }
 
+// defaultMaxPendingFills caps a coin's pending batch when unconfigured
+const defaultMaxPendingFills = 1000
+
+// opposesPending reports whether fill's side is against the net direction
+// of the coin's pending fills. Caller must hold pt.mu.
+func (pt *PaperTrader) opposesPending(fill *Fill) bool {
+	net := 0.0
+	for _, pending := range pt.PendingFills[fill.Coin] {
+		if pending.Side.IsBuy() {
+			net += pending.Size
+		} else {
+			net -= pending.Size
+		}
+	}
+	return net != 0 && (net > 0) != fill.Side.IsBuy()
+}
+
+// evictFlat forgets coins flat for longer than EvictFlatAfter with nothing
+// pending, so a target trading hundreds of coins doesn't grow the per-coin
+// maps forever. Their realized PnL moves into EvictedRealized. Caller must
+// hold pt.mu.
+func (pt *PaperTrader) evictFlat() {
+	if pt.EvictFlatAfter <= 0 {
+		return
+	}
+	for coin, position := range pt.Positions {
+		if position.Size != 0 || len(pt.PendingFills[coin]) > 0 || pt.PendingVolume[coin] > 0 ||
+			pt.twaps[coin] != nil {
+			continue
+		}
+		if pt.now().Sub(pt.LastTradeTime[coin]) < pt.EvictFlatAfter {
+			continue
+		}
+
+		pt.EvictedRealized += position.RealizedPnL
+		pt.EvictedCoins++
+		delete(pt.Positions, coin)
+		delete(pt.PendingFills, coin)
+		delete(pt.PendingVolume, coin)
+		delete(pt.LastVolumeUpdate, coin)
+		delete(pt.LastTradeTime, coin)
+		delete(pt.MarkTime, coin)
+		delete(pt.TargetLeverage, coin)
+		delete(pt.reversals, coin)
+	}
+}
+
+// aggregateByOrder batches fills by the target's parent order rather than
+// by volume: a fill from a new order books the previous order's fills as
+// one trade. Caller must hold pt.mu.
+func (pt *PaperTrader) aggregateByOrder(fill *Fill) {
+	pending := pt.PendingFills[fill.Coin]
+	if len(pending) > 0 && pending[len(pending)-1].Oid != fill.Oid {
+		pt.processAggregatedFills(fill.Coin)
+	}
+	pt.PendingFills[fill.Coin] = append(pt.PendingFills[fill.Coin], fill)
+	pt.PendingVolume[fill.Coin] += fill.Size * fill.Price
+	pt.LastVolumeUpdate[fill.Coin] = pt.now() // the order's latest fill
+}
+
+// flushOrders books pending orders that have had no new fills for
+// MinTradeInterval, as no later order arrived to close them off. Caller
+// must hold pt.mu.
+func (pt *PaperTrader) flushOrders() {
+	if !pt.AggregateByOrder {
+		return
+	}
+	for coin, fills := range pt.PendingFills {
+		if len(fills) == 0 || fills[len(fills)-1].Oid == 0 {
+			continue
+		}
+		if pt.now().Sub(pt.LastVolumeUpdate[coin]) >= pt.MinTradeInterval {
+			pt.processAggregatedFills(coin)
+		}
+	}
+}
+
 func (pt *PaperTrader) processAggregatedFills(coin string) {
 	fills := pt.PendingFills[coin]
 	if len(fills) == 0 {
@@ -270,12 +813,12 @@ func (pt *PaperTrader) processAggregatedFills(coin string) {
 	// Calculate aggregated values
 	var totalSize, totalValue, totalClosedPnL float64
 	var lastPrice float64
-	var side string
+	var side Side
 	var lastTime int64
 
 	for _, fill := range fills {
 		size := fill.Size
-		if fill.Side == "A" { // sell
+		if fill.Side == SideSell {
 			size = -fill.Size
 		}
 		totalSize += size
@@ -298,6 +841,10 @@ func (pt *PaperTrader) processAggregatedFills(coin string) {
 	// Get or create position
 	position := pt.getPosition(coin)
 
+	// Does the target's trade shrink our position?
+	reducing := position.Size*totalSize < 0
+	pt.checkCapital()
+
 	var adjustedTradeSize float64
 
 	if pt.DisableDynamicSize {
@@ -314,10 +861,24 @@ func (pt *PaperTrader) processAggregatedFills(coin string) {
 		}
 
 		dynamicTradeSize := pt.calculateDynamicTradeSize(mostRecentFill)
+		if pt.reduceOnly && reducing {
+			// No capacity left, but exits must still go through
+			size := pt.roundSize(coin, pt.baseNotional()/(mostRecentFill.Price*pt.quoteRate()))
+			dynamicTradeSize = math.Min(size, math.Abs(position.Size))
+		}
+
+		// Out of capacity: optionally make room by trimming the worst position
+		if dynamicTradeSize == 0 && pt.OnCapacityExhaust == "scale_existing" &&
+			pt.trimWorstPosition(coin, pt.baseNotional()) {
+			dynamicTradeSize = pt.calculateDynamicTradeSize(mostRecentFill)
+		}
 
 		// If dynamic sizing returns 0, skip the trade entirely
 		if dynamicTradeSize == 0 {
 			log.Printf("Skipping trade for %s: insufficient capital remaining", coin)
+			if pt.OnCapacityExhaust == "reject_and_alert" {
+				pt.alert(fmt.Sprintf("capacity exhausted: rejected %s copy", coin))
+			}
 			pt.PendingFills[coin] = nil // Clear pending fills
 			pt.PendingVolume[coin] = 0
 			delete(pt.LastVolumeUpdate, coin)
@@ -331,6 +892,12 @@ func (pt *PaperTrader) processAggregatedFills(coin string) {
 		}
 	}
 
+	// Sliced copies take impact per child instead
+	signalPrice := avgPrice
+
+	// Our own copy moves the market against us
+	avgPrice = pt.impactPrice(avgPrice, adjustedTradeSize)
+
 	// Calculate trade details with adjusted sizing
 	oldSize := position.Size
 	newSize := oldSize + adjustedTradeSize
@@ -338,60 +905,291 @@ func (pt *PaperTrader) processAggregatedFills(coin string) {
 	// Determine action type
 	action := pt.determineAction(oldSize, newSize)
 
+	// A position below the venue minimum could never be closed for real
+	if minSize := pt.minSize(coin); math.Abs(newSize) < minSize-pt.sizeEpsilon(coin) {
+		switch action {
+		case ActionOpen:
+			log.Printf("Skipping OPEN for %s: size %.6f below the %.6f minimum", coin, newSize, minSize)
+			pt.PendingFills[coin] = nil
+			pt.PendingVolume[coin] = 0
+			delete(pt.LastVolumeUpdate, coin)
+			return
+		case ActionReverse:
+			log.Printf("Closing %s instead of reversing: new size %.6f below the %.6f minimum",
+				coin, newSize, minSize)
+			adjustedTradeSize, newSize, action = -oldSize, 0, ActionClose
+			avgPrice = pt.impactPrice(signalPrice, adjustedTradeSize)
+		}
+	}
+
+	// Without capital only exits are copied
+	if pt.reduceOnly && action != ActionReduce && action != ActionClose {
+		log.Printf("Skipping %s for %s: capital depleted, reduce-only", action, coin)
+		pt.PendingFills[coin] = nil
+		pt.PendingVolume[coin] = 0
+		delete(pt.LastVolumeUpdate, coin)
+		return
+	}
+
+	// A burst of flips is more likely bad data than a real target
+	if action == ActionReverse && !pt.allowReversal(coin) {
+		log.Printf("Skipping REVERSE for %s: more than %d reversals in a minute, suspected bad data",
+			coin, pt.MaxReversalsPerMin)
+		pt.PendingFills[coin] = nil
+		pt.PendingVolume[coin] = 0
+		delete(pt.LastVolumeUpdate, coin)
+		return
+	}
+
 	// Validate position size limits (skip for tests with disabled dynamic sizing)
-	if !pt.DisableDynamicSize && !pt.validatePositionSize(coin, newSize, lastPrice) {
-		availableCapital := pt.calculateAvailableCapital()
+	if !pt.DisableDynamicSize && !pt.DisableLimits &&
+		!pt.validatePositionSize(coin, newSize, lastPrice) {
+		availableCapital := pt.sizingCapital(coin)
 		log.Printf("Skipping trade for %s: would exceed capital limit (%.2f available * %.2fx = %.2f max)",
-			coin, availableCapital, pt.Leverage, availableCapital*pt.Leverage)
+			coin, availableCapital, pt.leverage(coin), availableCapital*pt.leverage(coin))
 		pt.PendingFills[coin] = nil // Clear pending fills
 		pt.PendingVolume[coin] = 0
 		delete(pt.LastVolumeUpdate, coin)
 		return
 	}
 
+	// Clear pending fills and volume
+	pt.PendingFills[coin] = nil
+	pt.PendingVolume[coin] = 0
+	delete(pt.LastVolumeUpdate, coin)
+
+	if pt.TWAPSlices > 1 {
+		pt.startTWAP(coin, adjustedTradeSize, signalPrice, totalClosedPnL, fills)
+		return
+	}
+
+	pt.bookTrade(coin, adjustedTradeSize, avgPrice, lastPrice, totalClosedPnL, side, lastTime, fills)
+}
+
+// bookTrade applies a copied trade of signed tradeSize at price to the
+// position and records it. markPrice becomes the position's last price.
+func (pt *PaperTrader) bookTrade(
+	coin string,
+	tradeSize, price, markPrice, closedPnL float64,
+	side Side,
+	lastTime int64,
+	fills []*Fill,
+) {
+	position := pt.getPosition(coin)
+	tradeSize = pt.snapFlat(position, tradeSize)
+	action := pt.determineAction(position.Size, position.Size+tradeSize)
+
 	// Calculate realized PnL for position changes (using adjusted trade size)
-	realizedPnL := pt.calculateRealizedPnL(position, adjustedTradeSize, avgPrice, totalClosedPnL, action)
+	realizedPnL := pt.calculateRealizedPnL(position, tradeSize, price, closedPnL, action)
 
 	// Update position
-	pt.updatePosition(position, adjustedTradeSize, avgPrice, realizedPnL)
+	pt.updatePosition(position, tradeSize, price, realizedPnL)
 
 	// Update last price for unrealized PnL calculation
-	position.LastPrice = lastPrice
+	position.LastPrice = markPrice
 
 	// Update totals
+	fee := pt.tradeFee(tradeSize, price, fills)
 	pt.TotalTrades++
-	pt.TotalRealizedPnL += realizedPnL
+	pt.addRealized(action.String(), realizedPnL)
+	pt.TotalFees += fee
 
 	// Create trade record
 	trade := &PaperTrade{
 		Timestamp:     time.Unix(lastTime/1000, 0),
 		Coin:          coin,
 		Action:        action.String(),
-		Side:          map[string]string{"B": "BUY", "A": "SELL"}[side],
-		Size:          math.Abs(adjustedTradeSize),
-		Price:         avgPrice,
+		Side:          side.String(),
+		Size:          math.Abs(tradeSize),
+		Price:         price,
 		RealizedPnL:   realizedPnL,
 		PositionSize:  position.Size,
 		UnrealizedPnL: pt.calculateUnrealizedPnL(position),
+		Fee:           fee,
 	}
 	pt.TradeHistory = append(pt.TradeHistory, trade)
 
 	// Update last trade time
-	pt.LastTradeTime[coin] = time.Now()
+	pt.LastTradeTime[coin] = pt.now()
 
-	// Clear pending fills and volume
+	// Save fill data and account snapshot
+	for i, fill := range fills {
+		record := pt.fillRecord(fill, action.String(), trade.RealizedPnL, trade.UnrealizedPnL)
+		if i == len(fills)-1 {
+			record.TradeSize = tradeSize
+			record.TradePrice = price
+		}
+		pt.saveFillRecord(record)
+	}
+	pt.SaveAccount()
+
+	// Print trade with proper formatting
+	pt.printTrade(trade, action)
+
+	if pt.OnTrade != nil {
+		pt.OnTrade(trade)
+	}
+}
+
+// ClosePosition manually flattens a position at its current mark, booking a
+// CLOSE trade. Pending target fills for the coin are discarded.
+func (pt *PaperTrader) ClosePosition(coin string) (*PaperTrade, error) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.closeAtMark(coin, ActionClose)
+}
+
+// closeAtMark flattens a position at its last price, booking the trade as
+// action. Pending target fills for the coin are discarded. Caller must hold
+// pt.mu.
+func (pt *PaperTrader) closeAtMark(coin string, action PositionAction) (*PaperTrade, error) {
+	position, exists := pt.Positions[coin]
+	if !exists || position.Size == 0 {
+		return nil, ErrNoPosition
+	}
+
+	trade, err := pt.tradeAtMark(coin, -position.Size, action)
+	if err != nil {
+		return nil, err
+	}
 	pt.PendingFills[coin] = nil
 	pt.PendingVolume[coin] = 0
 	delete(pt.LastVolumeUpdate, coin)
+	return trade, nil
+}
 
-	// Save fill data and account snapshot
-	for _, fill := range fills {
-		pt.SaveFill(fill, action.String(), trade.RealizedPnL, trade.UnrealizedPnL)
+// tradeAtMark books a bot-initiated trade of signed tradeSize at the
+// position's last price. Caller must hold pt.mu.
+func (pt *PaperTrader) tradeAtMark(coin string, tradeSize float64, action PositionAction) (*PaperTrade, error) {
+	position, exists := pt.Positions[coin]
+	if !exists || position.Size == 0 {
+		return nil, ErrNoPosition
+	}
+	price := position.LastPrice
+	if price <= 0 {
+		return nil, ErrNoMark
+	}
+
+	tradeSize = pt.snapFlat(position, tradeSize)
+	if action == ActionReduce && position.Size+tradeSize == 0 {
+		action = ActionClose // the trim left only dust
 	}
+	side := SideBuy
+	if tradeSize < 0 {
+		side = SideSell
+	}
+
+	realizedPnL := pt.calculateRealizedPnL(position, tradeSize, price, 0, action)
+	pt.updatePosition(position, tradeSize, price, realizedPnL)
+
+	// Bot-initiated trades always take
+	fee := pt.tradeFee(tradeSize, price, nil)
+	pt.TotalTrades++
+	pt.addRealized(action.String(), realizedPnL)
+	pt.TotalFees += fee
+
+	now := pt.now()
+	trade := &PaperTrade{
+		Timestamp:     now,
+		Coin:          coin,
+		Action:        action.String(),
+		Side:          side.String(),
+		Size:          math.Abs(tradeSize),
+		Price:         price,
+		RealizedPnL:   realizedPnL,
+		PositionSize:  position.Size,
+		UnrealizedPnL: pt.calculateUnrealizedPnL(position),
+		Fee:           fee,
+	}
+	pt.TradeHistory = append(pt.TradeHistory, trade)
+	pt.LastTradeTime[coin] = now
+
+	fill := &Fill{
+		Coin:  coin,
+		Side:  side,
+		Size:  math.Abs(tradeSize),
+		Price: price,
+		Time:  now.UnixMilli(),
+		Hash:  "local-" + strings.ToLower(trade.Action), // not a target fill
+	}
+	record := pt.fillRecord(fill, trade.Action, realizedPnL, trade.UnrealizedPnL)
+	record.TradeSize = tradeSize
+	record.TradePrice = price
+	pt.saveFillRecord(record)
 	pt.SaveAccount()
 
-	// Print trade with proper formatting
 	pt.printTrade(trade, action)
+
+	if pt.OnTrade != nil {
+		pt.OnTrade(trade)
+	}
+	return trade, nil
+}
+
+// trimWorstPosition frees up to notional of capacity by reducing the open
+// position, other than coin, with the worst unrealized return. Returns
+// false when there is nothing to trim.
+func (pt *PaperTrader) trimWorstPosition(coin string, notional float64) bool {
+	var worst *Position
+	worstReturn := math.Inf(1)
+	for other, pos := range pt.Positions {
+		if other == coin || pos.Size == 0 || pos.LastPrice <= 0 || pos.AvgEntryPrice <= 0 {
+			continue
+		}
+		ret := pt.calculateUnrealizedPnL(pos) / math.Abs(pos.Size*pos.AvgEntryPrice)
+		if ret < worstReturn || (ret == worstReturn && pos.Coin < worst.Coin) {
+			worst, worstReturn = pos, ret
+		}
+	}
+	if worst == nil {
+		return false
+	}
+
+	trim := notional / (worst.LastPrice * pt.quoteRate())
+	log.Printf("Trimming %s by up to %.4f to make room for %s", worst.Coin, trim, coin)
+
+	if trim >= math.Abs(worst.Size) {
+		_, err := pt.closeAtMark(worst.Coin, ActionClose)
+		return err == nil
+	}
+	if worst.Size > 0 {
+		trim = -trim
+	}
+	_, err := pt.tradeAtMark(worst.Coin, trim, ActionReduce)
+	return err == nil
+}
+
+// alert sends an operator alert when a handler is installed
+func (pt *PaperTrader) alert(message string) {
+	log.Printf("alert: %s", message)
+	if pt.OnAlert != nil {
+		pt.OnAlert(message)
+	}
+}
+
+// allowReversal applies the per-coin reversal rate limit, recording the
+// reversal when it is allowed
+func (pt *PaperTrader) allowReversal(coin string) bool {
+	if pt.MaxReversalsPerMin <= 0 {
+		return true
+	}
+	if pt.reversals == nil {
+		pt.reversals = make(map[string][]time.Time)
+	}
+
+	now := pt.now()
+	recent := pt.reversals[coin][:0]
+	for _, at := range pt.reversals[coin] {
+		if now.Sub(at) < time.Minute {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= pt.MaxReversalsPerMin {
+		pt.reversals[coin] = recent
+		return false
+	}
+	pt.reversals[coin] = append(recent, now)
+	return true
 }
 
 // applyVolumeDecay reduces pending volume based on time since volume accumulation started
@@ -401,7 +1199,7 @@ func (pt *PaperTrader) applyVolumeDecay(coin string) {
 		return
 	}
 
-	elapsed := time.Since(lastUpdate)
+	elapsed := pt.now().Sub(lastUpdate)
 
 	// Only apply decay after at least 10 seconds have passed
 	// This prevents micro-second decay from affecting rapid fills
@@ -434,13 +1232,73 @@ func (pt *PaperTrader) getPosition(coin string) *Position {
 		AvgEntryPrice:  0,
 		TotalCostBasis: 0,
 		RealizedPnL:    0,
-		OpenTime:       time.Now(),
+		OpenTime:       pt.now(),
 		TradeCount:     0,
 	}
 	pt.Positions[coin] = pos
 	return pos
 }
 
+// Backfill opens a copy of a position the target already held, entered at
+// their entry price without booking a trade. It is marked at the live mark
+// rather than the old entry so unrealized PnL is current from the start.
+// It returns our signed size, 0 when we hold the coin or have no capacity.
+func (pt *PaperTrader) Backfill(coin string, long bool, entry, mark float64) float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if entry <= 0 || mark <= 0 {
+		return 0
+	}
+	if pos, exists := pt.Positions[coin]; exists && pos.Size != 0 {
+		return 0
+	}
+	side := SideBuy
+	if !long {
+		side = SideSell
+	}
+	size := pt.calculateDynamicTradeSize(&Fill{Coin: coin, Side: side, Price: entry})
+	if size <= 0 {
+		return 0
+	}
+	if !long {
+		size = -size
+	}
+
+	position := pt.getPosition(coin)
+	pt.updatePosition(position, size, entry, 0)
+	position.LastPrice = entry
+	pt.updateMarkPrice(coin, mark)
+	return size
+}
+
+// migrateAliases moves positions still held under a renamed coin's old
+// symbol to the new one, so a migration neither orphans the old position
+// nor opens a spurious new one
+func (pt *PaperTrader) migrateAliases() {
+	for old, renamed := range pt.CoinAliases {
+		position, exists := pt.Positions[old]
+		if !exists {
+			continue
+		}
+		if current, exists := pt.Positions[renamed]; exists && current.Size != 0 {
+			log.Printf("Error migrating %s to %s: both have open positions", old, renamed)
+			continue
+		}
+
+		delete(pt.Positions, old)
+		position.Coin = renamed
+		pt.Positions[renamed] = position
+		for _, times := range []map[string]time.Time{pt.LastTradeTime, pt.MarkTime} {
+			if t, exists := times[old]; exists {
+				times[renamed] = t
+				delete(times, old)
+			}
+		}
+		log.Printf("bot: migrated %s position to renamed coin %s", old, renamed)
+	}
+}
+
 func (pt *PaperTrader) determineAction(oldSize, newSize float64) PositionAction {
 	// Flat to Long/Short
 	if oldSize == 0 && newSize != 0 {
@@ -483,8 +1341,10 @@ func (pt *PaperTrader) calculateRealizedPnL(
 		return 0
 	}
 
-	// Only use API's closedPnL for position reductions
-	if closedPnL != 0 && (action == ActionReduce || action == ActionClose || action == ActionReverse) {
+	// Only use API's closedPnL for position reductions, and never under
+	// FIFO: the target's figure is on its own cost basis, not our lots
+	if closedPnL != 0 && pt.CostBasis != "fifo" &&
+		(action == ActionReduce || action == ActionClose || action == ActionReverse) {
 		return closedPnL
 	}
 
@@ -499,6 +1359,10 @@ func (pt *PaperTrader) calculateRealizedPnL(
 				reducedSize = math.Abs(position.Size)
 			}
 
+			if pt.CostBasis == "fifo" && len(position.Lots) > 0 {
+				return fifoRealizedPnL(position, reducedSize, price)
+			}
+
 			pnlPerUnit := price - position.AvgEntryPrice
 			if position.Size < 0 {
 				pnlPerUnit = position.AvgEntryPrice - price // short position
@@ -511,6 +1375,68 @@ func (pt *PaperTrader) calculateRealizedPnL(
 	return 0
 }
 
+// fifoRealizedPnL matches a reduction against the oldest lots first
+func fifoRealizedPnL(position *Position, reducedSize, price float64) float64 {
+	pnl := 0.0
+	remaining := reducedSize
+	for _, lot := range position.Lots {
+		if remaining <= 0 {
+			break
+		}
+		matched := math.Min(lot.Size, remaining)
+		if position.Size > 0 {
+			pnl += (price - lot.Price) * matched
+		} else {
+			pnl += (lot.Price - price) * matched
+		}
+		remaining -= matched
+	}
+	return pnl
+}
+
+// consumeLots removes size from the oldest lots
+func consumeLots(lots []Lot, size float64) []Lot {
+	for len(lots) > 0 && size > 0 {
+		if lots[0].Size > size {
+			lots[0].Size -= size
+			break
+		}
+		size -= lots[0].Size
+		lots = lots[1:]
+	}
+	return lots
+}
+
+// updateLots keeps the FIFO lot queue in step with a position change
+func (pt *PaperTrader) updateLots(position *Position, oldSize, newSize, tradeSize, price float64) {
+	if pt.CostBasis != "fifo" {
+		return
+	}
+
+	switch {
+	case newSize == 0:
+		position.Lots = nil
+	case oldSize == 0 || (oldSize > 0) != (newSize > 0):
+		// New or reversed position starts a fresh queue
+		position.Lots = []Lot{{Size: math.Abs(newSize), Price: price}}
+	case (oldSize > 0) == (tradeSize > 0):
+		position.Lots = append(position.Lots, Lot{Size: math.Abs(tradeSize), Price: price})
+	default:
+		position.Lots = consumeLots(position.Lots, math.Abs(tradeSize))
+	}
+
+	// Remaining lots define the entry price under FIFO
+	totalSize, totalCost := 0.0, 0.0
+	for _, lot := range position.Lots {
+		totalSize += lot.Size
+		totalCost += lot.Size * lot.Price
+	}
+	if totalSize > 0 {
+		position.TotalCostBasis = totalCost
+		position.AvgEntryPrice = totalCost / totalSize
+	}
+}
+
 func (pt *PaperTrader) updatePosition(
 	position *Position,
 	tradeSize float64,
@@ -536,13 +1462,14 @@ func (pt *PaperTrader) updatePosition(
 		// New position
 		position.AvgEntryPrice = price
 		position.TotalCostBasis = price * math.Abs(tradeSize)
-		position.OpenTime = time.Now()
+		position.OpenTime = pt.now()
+		position.Tags = pt.openTags()
 	} else if (oldSize > 0 && newSize < 0) || (oldSize < 0 && newSize > 0) {
 		// Position reversal - new position in opposite direction
 		reversedSize := math.Abs(newSize)
 		position.AvgEntryPrice = price
 		position.TotalCostBasis = price * reversedSize
-		position.OpenTime = time.Now()
+		position.OpenTime = pt.now()
 	} else if (oldSize > 0 && tradeSize > 0) || (oldSize < 0 && tradeSize < 0) {
 		// Adding to position - recalculate weighted average
 		totalCost := position.TotalCostBasis + (price * math.Abs(tradeSize))
@@ -550,6 +1477,30 @@ func (pt *PaperTrader) updatePosition(
 		position.AvgEntryPrice = totalCost / math.Abs(newSize)
 	}
 	// For reducing positions, keep the same average entry price
+
+	// A new position climbs the take-profit ladder from the bottom and
+	// starts with the default stop and take profit
+	if oldSize == 0 || newSize == 0 || oldSize*newSize < 0 {
+		position.LadderBase = 0
+		position.LadderRungs = 0
+		position.StopLossPct = pt.StopLossPct
+		position.TakeProfitPct = pt.TakeProfitPct
+	}
+	position.LadderBase = math.Max(position.LadderBase, math.Abs(newSize))
+
+	pt.updateLots(position, oldSize, newSize, tradeSize, price)
+}
+
+// openTags returns a copy of the trader's tags for a newly opened position
+func (pt *PaperTrader) openTags() map[string]string {
+	if len(pt.Tags) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(pt.Tags))
+	for k, v := range pt.Tags {
+		tags[k] = v
+	}
+	return tags
 }
 
 func (pt *PaperTrader) calculateUnrealizedPnL(position *Position) float64 {
@@ -564,13 +1515,7 @@ func (pt *PaperTrader) calculateUnrealizedPnL(position *Position) float64 {
 // updateRealTimePrice updates the LastPrice for existing positions based on market fills
 // This enables real-time unrealized PnL calculation even when we're not trading
 func (pt *PaperTrader) updateRealTimePrice(coin string, price float64) {
-	position, exists := pt.Positions[coin]
-	if !exists || position.Size == 0 {
-		return // No position to update
-	}
-
-	// Update the last price for unrealized PnL calculation
-	position.LastPrice = price
+	pt.updateMarkPrice(coin, price)
 }
 
 func (pt *PaperTrader) printTrade(trade *PaperTrade, action PositionAction) {
@@ -605,6 +1550,106 @@ func (pt *PaperTrader) printTrade(trade *PaperTrade, action PositionAction) {
 		pnlStr)
 }
 
+// TakerRatio returns the share of target fills that crossed the book
+func (pt *PaperTrader) TakerRatio() float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.takerRatio()
+}
+
+// recordLatency measures how long after the target's fill we process it
+func (pt *PaperTrader) recordLatency(fill *Fill) {
+	if fill.Time <= 0 {
+		return
+	}
+	latency := pt.now().Sub(time.UnixMilli(fill.Time))
+	if latency < 0 {
+		latency = 0 // clock skew between us and the exchange
+	}
+	pt.LatencyCount++
+	pt.LatencyTotal += latency
+	if latency > pt.LatencyMax {
+		pt.LatencyMax = latency
+	}
+}
+
+// CopyLatency returns the average and maximum delay between the target's
+// fills and our processing of them
+func (pt *PaperTrader) CopyLatency() (avg, peak time.Duration) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.copyLatency()
+}
+
+func (pt *PaperTrader) copyLatency() (avg, peak time.Duration) {
+	if pt.LatencyCount == 0 {
+		return 0, 0
+	}
+	return pt.LatencyTotal / time.Duration(pt.LatencyCount), pt.LatencyMax
+}
+
+func (pt *PaperTrader) takerRatio() float64 {
+	total := pt.TakerFills + pt.MakerFills
+	if total == 0 {
+		return 0
+	}
+	return float64(pt.TakerFills) / float64(total)
+}
+
+// defaultFeeWarnPercent is the fee drag warned about when unconfigured
+const defaultFeeWarnPercent = 50.0
+
+// FeeDrag returns total fees as a percentage of gross realized PnL and
+// whether it exceeds FeeWarnPercent. Fees without any gross profit are an
+// infinite drag.
+func (pt *PaperTrader) FeeDrag() (percent float64, warn bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.feeDrag()
+}
+
+func (pt *PaperTrader) feeDrag() (float64, bool) {
+	if pt.TotalFees <= 0 { // rebates outweigh fees: no drag
+		return 0, false
+	}
+
+	percent := math.Inf(1)
+	if pt.TotalRealizedPnL > 0 {
+		percent = pt.TotalFees / pt.TotalRealizedPnL * 100
+	}
+
+	limit := pt.FeeWarnPercent
+	if limit <= 0 {
+		limit = defaultFeeWarnPercent
+	}
+	return percent, percent > limit
+}
+
+// minAnnualizeDuration is the shortest session whose return is annualized;
+// extrapolating minutes of PnL to a year says nothing
+const minAnnualizeDuration = 24 * time.Hour
+
+// AnnualizedReturn extrapolates net realized PnL as a fraction of the
+// bankroll linearly to a year. It assumes the session's rate holds, so it
+// is a rough guide at best, and is 0 for sessions shorter than
+// minAnnualizeDuration.
+func (pt *PaperTrader) AnnualizedReturn() float64 {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	annualized, _ := pt.annualizedReturn()
+	return annualized
+}
+
+// annualizedReturn also reports whether the session was long enough
+func (pt *PaperTrader) annualizedReturn() (float64, bool) {
+	elapsed := pt.now().Sub(pt.StartTime)
+	if elapsed < minAnnualizeDuration || pt.Bankroll <= 0 {
+		return 0, false
+	}
+	sessionReturn := pt.netRealizedPnL() * pt.quoteRate() / pt.Bankroll
+	return sessionReturn * float64(365*24*time.Hour) / float64(elapsed), true
+}
+
 func (pt *PaperTrader) PrintPortfolioSummary() {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
@@ -625,11 +1670,24 @@ func (pt *PaperTrader) PrintPortfolioSummary() {
 	}
 
 	// Time and performance
-	elapsed := time.Since(pt.StartTime)
+	elapsed := pt.now().Sub(pt.StartTime)
 	totalPnL := pt.TotalRealizedPnL + totalUnrealized
 
 	fmt.Printf("⏱️  Session Duration: %v\n", elapsed.Round(time.Second))
+	if pt.QuoteCurrency != "" && pt.QuoteCurrency != "USDC" {
+		fmt.Printf("💱 Quote: %s at %.4f per USDC; bankroll, equity and drawdown in %s, PnL in USDC\n",
+			pt.QuoteCurrency, pt.quoteRate(), pt.QuoteCurrency)
+	}
 	fmt.Printf("💰 Total Realized PnL: $%.2f\n", pt.TotalRealizedPnL)
+	if len(pt.RealizedByAction) > 0 {
+		parts := []string{}
+		for _, action := range []PositionAction{ActionReduce, ActionClose, ActionReverse, ActionTimeout} {
+			if pnl, exists := pt.RealizedByAction[action.String()]; exists {
+				parts = append(parts, fmt.Sprintf("%s $%.2f", action, pnl))
+			}
+		}
+		fmt.Printf("   by action: %s\n", strings.Join(parts, ", "))
+	}
 	fmt.Printf("📈 Total Unrealized PnL: $%.2f\n", totalUnrealized)
 	fmt.Printf("🎯 Total Portfolio PnL: $%.2f\n", totalPnL)
 	fmt.Printf("📊 Total Trades: %d\n", pt.TotalTrades)
@@ -638,13 +1696,77 @@ func (pt *PaperTrader) PrintPortfolioSummary() {
 	if pt.TotalTrades > 0 {
 		fmt.Printf("📊 Avg PnL per Trade: $%.2f\n", totalPnL/float64(pt.TotalTrades))
 	}
+	if annualized, ok := pt.annualizedReturn(); ok {
+		fmt.Printf("📆 Annualized Return: %.1f%% (net realized, extrapolated)\n", annualized*100)
+	}
+	if drawdown := pt.drawdown(); drawdown > 0 {
+		fmt.Printf("📉 Drawdown: $%.2f (%.1f%%) from the $%.2f equity peak\n",
+			drawdown, drawdown/pt.EquityPeak*100, pt.EquityPeak)
+	}
+	leverage := pt.currentLeverage()
+	fmt.Printf("⚖️  Current Leverage: %.2fx of %.2fx max\n", leverage, pt.Leverage)
+	if pt.Leverage > 0 && leverage > pt.Leverage*leverageWarnRatio {
+		fmt.Printf("⚠️  Leverage above %.0f%% of the cap\n", leverageWarnRatio*100)
+	}
+	if _, _, net, gross := pt.exposure(); gross > 0 {
+		fmt.Printf("🧭 Exposure: net $%.2f of $%.2f gross\n", net, gross)
+	}
+	if pt.TotalFees < 0 {
+		fmt.Printf("💸 Fees: $%.2f, maker rebates exceed fees paid\n", pt.TotalFees)
+	} else if pt.TotalFees > 0 {
+		percent, warn := pt.feeDrag()
+		if math.IsInf(percent, 1) {
+			fmt.Printf("💸 Fees: $%.2f with no gross profit\n", pt.TotalFees)
+		} else {
+			fmt.Printf("💸 Fees: $%.2f (%.1f%% of gross PnL)\n", pt.TotalFees, percent)
+		}
+		if warn {
+			fmt.Println("⚠️  Fees are eating the profits; this target may not be worth copying")
+		}
+	}
+	if pt.TotalFunding != 0 {
+		fmt.Printf("🪙 Funding: $%.2f (negative when paid)\n", pt.TotalFunding)
+	}
+	if pt.TotalFees != 0 || pt.TotalFunding != 0 {
+		fmt.Printf("🧾 Net Realized PnL: $%.2f (gross $%.2f - fees $%.2f + funding $%.2f)\n",
+			pt.netRealizedPnL(), pt.TotalRealizedPnL, pt.TotalFees, pt.TotalFunding)
+	}
+	if pt.LatencyCount > 0 {
+		avg, peak := pt.copyLatency()
+		fmt.Printf("⏳ Copy Latency: avg %v, max %v\n",
+			avg.Round(time.Millisecond), peak.Round(time.Millisecond))
+	}
+	if pt.TakerFills+pt.MakerFills > 0 {
+		fmt.Printf("🎯 Target Taker Ratio: %.1f%% (%d taker / %d maker)\n",
+			pt.takerRatio()*100, pt.TakerFills, pt.MakerFills)
+	}
 
 	// Active positions
 	if activePositions > 0 {
 		fmt.Println("\n🔄 ACTIVE POSITIONS:")
 		fmt.Println(strings.Repeat("-", 60))
+
+		// Group by originating target when positions carry target tags
+		groups := make(map[string][]string)
 		for coin, position := range pt.Positions {
 			if position.Size != 0 {
+				target := position.Tags["target"]
+				groups[target] = append(groups[target], coin)
+			}
+		}
+		targets := make([]string, 0, len(groups))
+		for target := range groups {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+
+		for _, target := range targets {
+			if target != "" {
+				fmt.Printf("[target %s]\n", target)
+			}
+			pt.sortPositions(groups[target])
+			for _, coin := range groups[target] {
+				position := pt.Positions[coin]
 				unrealizedPnL := pt.calculateUnrealizedPnL(position)
 				pnlPercent := 0.0
 				if position.AvgEntryPrice > 0 {
@@ -656,16 +1778,84 @@ func (pt *PaperTrader) PrintPortfolioSummary() {
 					sizeStr = "+" + sizeStr
 				}
 
-				fmt.Printf("%-8s | %s | Avg: $%.2f | Last: $%.2f | PnL: $%.2f (%.2f%%)\n",
+				fmt.Printf("%-8s | %s | Avg: $%.2f | Last: $%.2f | PnL: $%.2f (%.2f%%) | Realized: $%.2f\n",
 					coin, sizeStr, position.AvgEntryPrice, position.LastPrice,
-					unrealizedPnL, pnlPercent)
+					unrealizedPnL, pnlPercent, position.RealizedPnL)
 			}
 		}
 	}
 
+	// Flat coins we traded earlier still carry their realized PnL
+	var closed []string
+	for coin, position := range pt.Positions {
+		if position.Size == 0 && position.TradeCount > 0 {
+			closed = append(closed, coin)
+		}
+	}
+	if len(closed) > 0 || pt.EvictedCoins > 0 {
+		sort.Strings(closed)
+		fmt.Println("\n✅ CLOSED POSITIONS:")
+		fmt.Println(strings.Repeat("-", 60))
+		for _, coin := range closed {
+			position := pt.Positions[coin]
+			fmt.Printf("%-8s | %d trades | Realized: $%.2f\n",
+				coin, position.TradeCount, position.RealizedPnL)
+		}
+		if pt.EvictedCoins > 0 {
+			fmt.Printf("%d older coins | Realized: $%.2f\n", pt.EvictedCoins, pt.EvictedRealized)
+		}
+	}
+
+	if len(pt.TargetLeverage) > 0 {
+		fmt.Println("\n⚖️  TARGET LEVERAGE:")
+		coins := make([]string, 0, len(pt.TargetLeverage))
+		for coin := range pt.TargetLeverage {
+			coins = append(coins, coin)
+		}
+		sort.Strings(coins)
+		for _, coin := range coins {
+			fmt.Printf("%-8s | %.0fx\n", coin, pt.TargetLeverage[coin])
+		}
+	}
+
 	fmt.Println(strings.Repeat("=", 80))
 }
 
+// sortPositions orders coins for display by PositionSort: "notional"
+// (largest first, default), "coin" or "pnl" (best unrealized first). Ties
+// fall back to coin name so output is stable between runs.
+func (pt *PaperTrader) sortPositions(coins []string) {
+	key := func(coin string) float64 {
+		position := pt.Positions[coin]
+		switch pt.PositionSort {
+		case "coin":
+			return 0
+		case "pnl":
+			return pt.calculateUnrealizedPnL(position)
+		default:
+			return math.Abs(position.Size * position.LastPrice)
+		}
+	}
+
+	sort.Slice(coins, func(i, j int) bool {
+		ki, kj := key(coins[i]), key(coins[j])
+		if ki != kj {
+			return ki > kj
+		}
+		return coins[i] < coins[j]
+	})
+}
+
+// RecordTargetLeverage stores the target's current leverage for a coin
+func (pt *PaperTrader) RecordTargetLeverage(coin string, leverage float64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.TargetLeverage == nil {
+		pt.TargetLeverage = make(map[string]float64)
+	}
+	pt.TargetLeverage[coin] = leverage
+}
+
 func (pt *PaperTrader) GetTotalTrades() int {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
@@ -679,6 +1869,13 @@ func (pt *PaperTrader) SetVolumeThreshold(threshold float64) {
 	pt.VolumeThreshold = threshold
 }
 
+// SetSlippageBps sets the slippage on a $10k copy, in basis points
+func (pt *PaperTrader) SetSlippageBps(bps float64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.SlippageBps = bps
+}
+
 // SetMinTradeInterval sets the minimum time between trades
 func (pt *PaperTrader) SetMinTradeInterval(interval time.Duration) {
 	pt.mu.Lock()
@@ -716,6 +1913,8 @@ func (pt *PaperTrader) PrintRecentTrades(count int) {
 			action = ActionClose
 		case "REVERSE":
 			action = ActionReverse
+		case "TIMEOUT":
+			action = ActionTimeout
 		}
 
 		fmt.Printf("%s | %s %s %.2f %s @ $%.2f | PnL: $%.2f\n",
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"math"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -234,6 +238,7 @@ func TestSmallTradeFiltering(t *testing.T) {
 		config:         config,
 		paperTrader:    NewTestPaperTrader(),
 		processedFills: make(map[string]int64),
+		clock:          systemClock{},
 	}
 
 	// Small trade - should be filtered out
@@ -249,8 +254,10 @@ func TestSmallTradeFiltering(t *testing.T) {
 		t.Errorf("Small trade was not filtered: got %d trades, want 0", bot.paperTrader.GetTotalTrades())
 	}
 
-	// Large trade - should pass through
+	// Large trade, a fill of its own - should pass through. The rejected
+	// small one is processed and never retried.
 	largeFill := createTestFill("ETH", "B", 1.0, 4000.0, "0.0", time.Now().Unix())
+	largeFill.Hash = "test_hash_ETH_B_large"
 	err = bot.process(largeFill)
 
 	if err != nil {
@@ -265,10 +272,15 @@ func TestSmallTradeFiltering(t *testing.T) {
 
 func TestZeroAndNegativeSizes(t *testing.T) {
 	pt := NewTestPaperTrader()
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = pt
 
 	// Test zero size trade (should be ignored)
 	zeroFill := createTestFill("BTC", "B", 0.0, 50000.0, "0.0", time.Now().Unix())
-	pt.ProcessFill(zeroFill)
+	bot.process(zeroFill)
 
 	position := pt.Positions["BTC"]
 	if position != nil && position.Size != 0 {
@@ -278,6 +290,24 @@ func TestZeroAndNegativeSizes(t *testing.T) {
 	if pt.GetTotalTrades() != 0 {
 		t.Errorf("Zero size trade should be ignored: trades = %d, want 0", pt.GetTotalTrades())
 	}
+
+	// Ignored entirely, not recorded as a no-op
+	if position != nil || len(pt.PendingFills["BTC"]) != 0 || pt.LatencyCount != 0 {
+		t.Errorf("Zero size fill left state behind: position %v, pending %d, latency samples %d",
+			position, len(pt.PendingFills["BTC"]), pt.LatencyCount)
+	}
+
+	// Ingestion drops them before any bookkeeping, even against a position
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", time.Now().Unix()))
+	bot.process(&Fill{Coin: "BTC", Side: SideSell, Size: 0, Price: 50000.0,
+		Time: time.Now().UnixMilli(), Hash: "zero"})
+	if _, seen := bot.processedFills["zero"]; !seen {
+		t.Errorf("Zero size fill should be marked processed so it isn't re-examined")
+	}
+	if pt.GetTotalTrades() != 1 || pt.Positions["BTC"].Size != 1.0 {
+		t.Errorf("Zero size close changed the book: trades %d, size %.4f",
+			pt.GetTotalTrades(), pt.Positions["BTC"].Size)
+	}
 }
 
 func TestHighFrequencyTrading(t *testing.T) {
@@ -370,7 +400,7 @@ func createTestFill(
 ) *Fill {
 	return &Fill{
 		Coin:      coin,
-		Side:      side,
+		Side:      Side(side),
 		Size:      size,
 		Price:     price,
 		Time:      timestamp * 1000, // Convert to milliseconds
@@ -403,3 +433,1032 @@ func BenchmarkPositionActionDetermination(b *testing.B) {
 		pt.determineAction(oldSize, newSize)
 	}
 }
+
+func TestFIFOIgnoresTargetClosedPnl(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.CostBasis = "fifo"
+	now := time.Now().Unix()
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 1000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 2000.0, "0.0", now+1))
+
+	// The target reports its average-cost PnL; ours comes from the lots
+	pt.ProcessFill(createTestFill("ETH", "A", 1.0, 2500.0, "1000.0", now+2))
+	if math.Abs(pt.TotalRealizedPnL-1500.0) > 0.01 {
+		t.Errorf("FIFO realized PnL = %.2f, want 1500.00 from the 1000 lot", pt.TotalRealizedPnL)
+	}
+}
+
+func TestCostBasisFIFOVersusAverage(t *testing.T) {
+	// Build 2 ETH at different prices, then sell 1 above both
+	sequence := func(pt *PaperTrader) {
+		now := time.Now().Unix()
+		pt.ProcessFill(createTestFill("ETH", "B", 1.0, 1000.0, "0.0", now))
+		pt.ProcessFill(createTestFill("ETH", "B", 1.0, 2000.0, "0.0", now+1))
+		pt.ProcessFill(createTestFill("ETH", "A", 1.0, 2500.0, "0.0", now+2))
+	}
+
+	average := NewTestPaperTrader()
+	sequence(average)
+
+	fifo := NewTestPaperTrader()
+	fifo.CostBasis = "fifo"
+	sequence(fifo)
+
+	// Average: (2500 - 1500) * 1 = 1000, FIFO: (2500 - 1000) * 1 = 1500
+	if math.Abs(average.TotalRealizedPnL-1000.0) > 0.01 {
+		t.Errorf("Average cost realized PnL = %.2f, want 1000.00", average.TotalRealizedPnL)
+	}
+	if math.Abs(fifo.TotalRealizedPnL-1500.0) > 0.01 {
+		t.Errorf("FIFO realized PnL = %.2f, want 1500.00", fifo.TotalRealizedPnL)
+	}
+
+	// The remaining FIFO lot is the newer one
+	pos := fifo.Positions["ETH"]
+	if len(pos.Lots) != 1 || pos.Lots[0].Price != 2000.0 || pos.Lots[0].Size != 1.0 {
+		t.Errorf("Remaining FIFO lots = %+v, want [{1 2000}]", pos.Lots)
+	}
+	if pos.AvgEntryPrice != 2000.0 {
+		t.Errorf("FIFO entry price = %.2f, want 2000.00", pos.AvgEntryPrice)
+	}
+	if average.Positions["ETH"].AvgEntryPrice != 1500.0 {
+		t.Errorf("Average entry price = %.2f, want 1500.00", average.Positions["ETH"].AvgEntryPrice)
+	}
+
+	// Closing the rest realizes against the remaining lot
+	fifo.ProcessFill(createTestFill("ETH", "A", 1.0, 2500.0, "0.0", time.Now().Unix()+3))
+	if math.Abs(fifo.TotalRealizedPnL-2000.0) > 0.01 {
+		t.Errorf("FIFO realized after close = %.2f, want 2000.00", fifo.TotalRealizedPnL)
+	}
+	if len(fifo.Positions["ETH"].Lots) != 0 {
+		t.Errorf("Lots should be empty after close: %+v", fifo.Positions["ETH"].Lots)
+	}
+}
+
+func TestTakerRatio(t *testing.T) {
+	pt := NewTestPaperTrader()
+	if ratio := pt.TakerRatio(); ratio != 0 {
+		t.Errorf("Taker ratio with no fills = %.2f, want 0", ratio)
+	}
+
+	now := time.Now().Unix()
+	for i, crossed := range []bool{true, false, true, true, false, false, true, true} {
+		fill := createTestFill("BTC", "B", 0.1, 50000.0, "0.0", now+int64(i))
+		fill.Crossed = crossed
+		pt.ProcessFill(fill)
+	}
+
+	if pt.TakerFills != 5 || pt.MakerFills != 3 {
+		t.Errorf("Taker/maker fills = %d/%d, want 5/3", pt.TakerFills, pt.MakerFills)
+	}
+	if ratio := pt.TakerRatio(); math.Abs(ratio-0.625) > 1e-9 {
+		t.Errorf("Taker ratio = %.4f, want 0.625", ratio)
+	}
+}
+
+func TestPriceImpact(t *testing.T) {
+	now := time.Now().Unix()
+
+	small := NewTestPaperTrader()
+	small.ImpactCoefficient = 0.0001
+	small.ProcessFill(createTestFill("BTC", "B", 0.02, 50000.0, "0.0", now)) // $1k
+
+	large := NewTestPaperTrader()
+	large.ImpactCoefficient = 0.0001
+	large.ProcessFill(createTestFill("BTC", "B", 2.0, 50000.0, "0.0", now)) // $100k
+
+	smallPrice := small.Positions["BTC"].AvgEntryPrice
+	largePrice := large.Positions["BTC"].AvgEntryPrice
+	if largePrice <= smallPrice {
+		t.Errorf("Large copy price %.2f should be worse than small copy price %.2f", largePrice, smallPrice)
+	}
+
+	// sqrt($100k) * 0.0001 = 3.16% worse
+	want := 50000.0 * (1 + 0.0001*math.Sqrt(100000.0))
+	if math.Abs(largePrice-want) > 0.01 {
+		t.Errorf("Large copy price = %.2f, want %.2f", largePrice, want)
+	}
+
+	// Sells receive less
+	seller := NewTestPaperTrader()
+	seller.ImpactCoefficient = 0.0001
+	seller.ProcessFill(createTestFill("BTC", "A", 2.0, 50000.0, "0.0", now))
+	if price := seller.Positions["BTC"].AvgEntryPrice; price >= 50000.0 {
+		t.Errorf("Sell copy price = %.2f, want below 50000", price)
+	}
+}
+
+func TestSlippageRoundTrip(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.SetSlippageBps(5.0)
+
+	// Buy and sell 0.2 BTC at the same 50000: each side slips 5 bps of $10k
+	now := clock.Now().Unix()
+	buy := createTestFill("BTC", "B", 0.2, 50000.0, "0.0", now)
+	buy.Hash = "0xslipbuy"
+	pt.ProcessFill(buy)
+	if price := pt.Positions["BTC"].AvgEntryPrice; math.Abs(price-50025.0) > 1e-6 {
+		t.Errorf("Entry = %.4f, want 50025.0000 (cost basis on the slipped price)", price)
+	}
+
+	clock.Advance(time.Second)
+	sell := createTestFill("BTC", "A", 0.2, 50000.0, "0.0", now+1)
+	sell.Hash = "0xslipsell"
+	pt.ProcessFill(sell)
+	if exit := pt.TradeHistory[1].Price; math.Abs(exit-49975.0) > 1e-6 {
+		t.Errorf("Exit = %.4f, want 49975.0000", exit)
+	}
+
+	slippage := 10000.0 * 5.0 / 10000
+	if math.Abs(pt.TotalRealizedPnL-(-2*slippage)) > 1e-6 {
+		t.Errorf("Round trip realized = %.4f, want %.4f (2x slippage)", pt.TotalRealizedPnL, -2*slippage)
+	}
+
+	// A $40k copy slips twice the rate: sqrt(40k / 10k) * 5 bps = 10 bps
+	large := NewTestPaperTrader()
+	large.SetSlippageBps(5.0)
+	large.ProcessFill(createTestFill("BTC", "B", 0.8, 50000.0, "0.0", now))
+	if price := large.Positions["BTC"].AvgEntryPrice; math.Abs(price-50050.0) > 1e-6 {
+		t.Errorf("Large entry = %.4f, want 50050.0000", price)
+	}
+}
+
+func TestReversalRateLimit(t *testing.T) {
+	clock := NewReplayClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.MaxReversalsPerMin = 2
+
+	// Alternate +10 / -20 / +20 ... so every fill after the first flips
+	flip := func(i int) {
+		side, size := "A", 20.0
+		if i%2 == 0 {
+			side = "B"
+		}
+		if i == 0 {
+			size = 10.0
+		}
+		fill := createTestFill("BTC", side, size, 50000.0, "0.0", clock.Now().Unix())
+		fill.Hash = fmt.Sprintf("reversal_%d", i)
+		pt.ProcessFill(fill)
+		clock.Set(clock.Now().Add(time.Second))
+	}
+
+	for i := 0; i < 6; i++ {
+		flip(i)
+	}
+
+	reversals := 0
+	for _, trade := range pt.TradeHistory {
+		if trade.Action == "REVERSE" {
+			reversals++
+		}
+	}
+	if reversals != 2 {
+		t.Errorf("Reversals within a minute = %d, want 2", reversals)
+	}
+
+	// Once the window passes, reversals are allowed again
+	clock.Set(clock.Now().Add(time.Minute))
+	before := len(pt.TradeHistory)
+	size := pt.Positions["BTC"].Size
+	side := "A"
+	if size < 0 {
+		side = "B"
+	}
+	fill := createTestFill("BTC", side, math.Abs(size)*2, 50000.0, "0.0", clock.Now().Unix())
+	fill.Hash = "reversal_after_window"
+	pt.ProcessFill(fill)
+	if len(pt.TradeHistory) != before+1 || pt.TradeHistory[before].Action != "REVERSE" {
+		t.Errorf("Reversal after the window should be allowed")
+	}
+}
+
+func TestCurrentLeverage(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.Bankroll = 10000.0
+	pt.Leverage = 3.0
+
+	if leverage := pt.CurrentLeverage(); leverage != 0 {
+		t.Errorf("Leverage with no positions = %.2f, want 0", leverage)
+	}
+
+	// $15k long BTC and $5k short ETH against $10k capital = 2x
+	pt.Positions["BTC"] = &Position{Coin: "BTC", Size: 0.3, AvgEntryPrice: 50000, LastPrice: 50000}
+	pt.Positions["ETH"] = &Position{Coin: "ETH", Size: -2.0, AvgEntryPrice: 2500, LastPrice: 2500}
+	if leverage := pt.CurrentLeverage(); math.Abs(leverage-2.0) > 1e-9 {
+		t.Errorf("Leverage = %.4f, want 2.0", leverage)
+	}
+
+	// Unrealized loss shrinks capital: $17k open against $7k capital
+	pt.Positions["BTC"].LastPrice = 40000
+	want := 17000.0 / 7000.0
+	if leverage := pt.CurrentLeverage(); math.Abs(leverage-want) > 1e-9 {
+		t.Errorf("Leverage after loss = %.4f, want %.4f", leverage, want)
+	}
+}
+
+func TestExposure(t *testing.T) {
+	pt := NewTestPaperTrader()
+
+	// $15k + $4k long, $5k short, one flat coin
+	pt.Positions["BTC"] = &Position{Coin: "BTC", Size: 0.3, AvgEntryPrice: 48000, LastPrice: 50000}
+	pt.Positions["SOL"] = &Position{Coin: "SOL", Size: 20.0, AvgEntryPrice: 200, LastPrice: 200}
+	pt.Positions["ETH"] = &Position{Coin: "ETH", Size: -2.0, AvgEntryPrice: 2600, LastPrice: 2500}
+	pt.Positions["DOGE"] = &Position{Coin: "DOGE", Size: 0, LastPrice: 0.1}
+
+	long, short, net, gross := pt.Exposure()
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"long", long, 19000},
+		{"short", short, 5000},
+		{"net", net, 14000},
+		{"gross", gross, 24000},
+	} {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s exposure = %.2f, want %.2f", tt.name, tt.got, tt.want)
+		}
+	}
+
+	output := captureStdout(t, pt.PrintPortfolioSummary)
+	if !strings.Contains(output, "Exposure: net $14000.00 of $24000.00 gross") {
+		t.Errorf("Summary should show exposure, got:\n%s", output)
+	}
+}
+
+func TestSizeRounding(t *testing.T) {
+	tests := []struct {
+		mode string
+		size float64
+		want float64
+	}{
+		{"down", 0.12345, 0.1234},
+		{"nearest", 0.12345, 0.1235},
+		{"up", 0.12345, 0.1235},
+		{"down", 0.12344, 0.1234},
+		{"nearest", 0.12344, 0.1234},
+		{"up", 0.12341, 0.1235},
+		{"", 0.12349, 0.1234}, // default is down
+		// Exact boundaries survive float error in every mode
+		{"down", 0.3, 0.3},
+		{"up", 0.3, 0.3},
+		{"nearest", 0.3, 0.3},
+	}
+
+	for _, tt := range tests {
+		pt := NewTestPaperTrader()
+		pt.SizeDecimals = map[string]int{"ETH": 4}
+		pt.SizeRounding = tt.mode
+		if got := pt.roundSize("ETH", tt.size); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("roundSize(%q, %v) = %v, want %v", tt.mode, tt.size, got, tt.want)
+		}
+	}
+
+	// Coins without known precision are left alone
+	pt := NewTestPaperTrader()
+	pt.SizeDecimals = map[string]int{"ETH": 4}
+	if got := pt.roundSize("BTC", 0.123456789); got != 0.123456789 {
+		t.Errorf("Unknown coin size = %v, want unrounded", got)
+	}
+
+	// Dynamic sizing rounds: $1000 at $3000 = 0.33333... -> 0.3333
+	pt.DisableDynamicSize = false
+	pt.BaseNotional = 1000.0
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 3000.0, "0.0", time.Now().Unix()))
+	if size := pt.Positions["ETH"].Size; math.Abs(size-0.3333) > 1e-12 {
+		t.Errorf("Dynamic copy size = %v, want 0.3333", size)
+	}
+}
+
+func TestCapacityExhaustedScaleExisting(t *testing.T) {
+	var alerts []string
+	newTrader := func(policy string) *PaperTrader {
+		pt := NewTestPaperTrader()
+		pt.DisableDynamicSize = false
+		pt.Bankroll = 3050.0
+		pt.BaseNotional = 1000.0
+		pt.OnCapacityExhaust = policy
+		pt.OnAlert = func(message string) { alerts = append(alerts, message) }
+
+		// $2000 BTC and $1000 ETH leave $50 of capacity, under 10% of base
+		now := time.Now().Unix()
+		pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now))
+		pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now+1))
+		pt.ProcessFill(createTestFill("ETH", "B", 1.0, 2000.0, "0.0", now+2))
+		pt.UpdateMarks(map[string]float64{"BTC": 45000.0, "ETH": 2100.0})
+		pt.ProcessFill(createTestFill("SOL", "B", 50.0, 100.0, "0.0", now+3))
+		return pt
+	}
+
+	skipped := newTrader("skip")
+	if pos := skipped.Positions["SOL"]; pos != nil && pos.Size != 0 {
+		t.Fatalf("skip policy should drop the SOL signal, got %.4f", pos.Size)
+	}
+
+	if len(alerts) != 0 {
+		t.Errorf("skip policy should not alert, got %v", alerts)
+	}
+
+	newTrader("reject_and_alert")
+	if len(alerts) != 1 {
+		t.Errorf("reject_and_alert alerts = %d, want 1", len(alerts))
+	}
+
+	pt := newTrader("scale_existing")
+
+	// BTC is the worst performer (-10% vs +5%) and gives up $1000 at the mark
+	wantBTC := 0.04 - 1000.0/45000.0
+	if size := pt.Positions["BTC"].Size; math.Abs(size-wantBTC) > 1e-9 {
+		t.Errorf("BTC after trim = %.6f, want %.6f", size, wantBTC)
+	}
+	if size := pt.Positions["ETH"].Size; math.Abs(size-0.5) > 1e-9 {
+		t.Errorf("ETH should be untouched, got %.6f", size)
+	}
+	if pos := pt.Positions["SOL"]; pos == nil || math.Abs(pos.Size-10.0) > 1e-9 {
+		t.Fatalf("SOL should be copied at full base notional after the trim, got %+v", pos)
+	}
+
+	trim := pt.TradeHistory[len(pt.TradeHistory)-2]
+	if trim.Coin != "BTC" || trim.Action != "REDUCE" || trim.RealizedPnL >= 0 {
+		t.Errorf("Trim trade = %s %s realized %.2f, want a losing BTC REDUCE",
+			trim.Coin, trim.Action, trim.RealizedPnL)
+	}
+}
+
+func TestFeeDrag(t *testing.T) {
+	pt := NewTestPaperTrader()
+	if percent, warn := pt.FeeDrag(); percent != 0 || warn {
+		t.Errorf("FeeDrag with no fees = %.1f%%, %v; want 0%%, false", percent, warn)
+	}
+
+	pt.TotalRealizedPnL = 400.0
+	pt.TotalFees = 100.0
+	percent, warn := pt.FeeDrag()
+	if math.Abs(percent-25.0) > 1e-9 {
+		t.Errorf("Fee drag = %.2f%%, want 25.00%%", percent)
+	}
+	if warn {
+		t.Errorf("25%% drag should not warn at the default 50%% threshold")
+	}
+
+	pt.FeeWarnPercent = 20.0
+	if _, warn := pt.FeeDrag(); !warn {
+		t.Errorf("25%% drag should warn at a 20%% threshold")
+	}
+
+	// Fees with no gross profit are all drag
+	pt.TotalRealizedPnL = -50.0
+	if percent, warn := pt.FeeDrag(); !math.IsInf(percent, 1) || !warn {
+		t.Errorf("FeeDrag with no profit = %.1f%%, %v; want +Inf, true", percent, warn)
+	}
+}
+
+func TestMakerRebate(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.TakerFeeBps = 4.5
+	pt.MakerFeeBps = -1.0
+
+	// A resting fill earns the rebate: 1 BTC @ 50000 = $5 back
+	maker := createTestFill("BTC", "B", 1.0, 50000.0, "0", 1000)
+	maker.Crossed = false
+	pt.ProcessFill(maker)
+	if math.Abs(pt.TotalFees-(-5.0)) > 1e-9 {
+		t.Fatalf("Fees after maker fill = %.4f, want -5.0000", pt.TotalFees)
+	}
+
+	// Taking on the close pays 4.5 bps: -5 + 22.5
+	time.Sleep(2 * time.Millisecond)
+	taker := createTestFill("BTC", "A", 1.0, 50000.0, "0", 1001)
+	taker.Crossed = true
+	pt.ProcessFill(taker)
+	if math.Abs(pt.TotalFees-17.5) > 1e-9 {
+		t.Errorf("Fees after taker close = %.4f, want 17.5000", pt.TotalFees)
+	}
+
+	rebated := NewTestPaperTrader()
+	rebated.MakerFeeBps = -1.0
+	rebated.ProcessFill(maker)
+	if net := rebated.netRealizedPnL(); net <= rebated.TotalRealizedPnL {
+		t.Errorf("Net realized %.2f should exceed gross %.2f with only rebates",
+			net, rebated.TotalRealizedPnL)
+	}
+	if fee := rebated.TradeHistory[0].Fee; math.Abs(fee-(-5.0)) > 1e-9 {
+		t.Errorf("Trade fee = %.4f, want -5.0000", fee)
+	}
+	if _, warn := rebated.FeeDrag(); warn {
+		t.Errorf("Rebates alone should not warn about fee drag")
+	}
+}
+
+func TestSuppliedFillFees(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.VolumeThreshold = 60000.0 // the third fill books the batch
+	pt.MinTradeInterval = time.Minute
+	pt.TakerFeeBps = 4.5
+
+	// Two fills carry the fee the target paid; one falls back to 4.5 bps
+	now := clock.Now().Unix()
+	fees := []string{"5.0", "", "2.5"}
+	for i, fee := range fees {
+		fill := createTestFill("BTC", "B", 0.5, 50000.0, "0.0", now+int64(i))
+		fill.Hash = fmt.Sprintf("0xfee%d", i)
+		fill.Fee = fee
+		fill.Crossed = true
+		pt.ProcessFill(fill)
+		clock.Advance(time.Second)
+	}
+
+	if pt.TotalTrades != 1 {
+		t.Fatalf("Trades = %d, want one aggregated trade", pt.TotalTrades)
+	}
+	want := 5.0 + 25000.0*4.5/10000 + 2.5
+	if math.Abs(pt.TotalFees-want) > 1e-9 || math.Abs(pt.TradeHistory[0].Fee-want) > 1e-9 {
+		t.Errorf("Fees = %.4f (trade %.4f), want %.4f once for the batch",
+			pt.TotalFees, pt.TradeHistory[0].Fee, want)
+	}
+
+	// A fee in another token isn't USDC, so it falls back to bps
+	hype := &Fill{Size: 1.0, Price: 50000.0, Fee: "0.1", FeeToken: "HYPE", Crossed: true}
+	if fee := pt.tradeFee(1.0, 50000.0, []*Fill{hype}); math.Abs(fee-22.5) > 1e-9 {
+		t.Errorf("Fee with a HYPE fee token = %.4f, want the 4.5 bps fallback 22.50", fee)
+	}
+
+	// Closing at a profit: gross, fees and net are reported apart
+	exit := createTestFill("BTC", "A", 1.5, 51000.0, "1500.0", now+3)
+	exit.Hash = "0xfeeclose"
+	exit.Fee = "7.65"
+	pt.ProcessFill(exit)
+	total := want + 7.65
+	if math.Abs(pt.TotalFees-total) > 1e-9 || math.Abs(pt.netRealizedPnL()-(1500.0-total)) > 1e-9 {
+		t.Errorf("Fees = %.4f, net = %.4f; want %.4f and %.4f",
+			pt.TotalFees, pt.netRealizedPnL(), total, 1500.0-total)
+	}
+	// Capital for sizing and drawdown is net of fees too
+	if equity := pt.equity(); math.Abs(equity-(pt.Bankroll+1500.0-total)) > 1e-6 {
+		t.Errorf("Equity = %.4f, want bankroll + %.4f net", equity, 1500.0-total)
+	}
+	summary := captureStdout(t, pt.PrintPortfolioSummary)
+	line := fmt.Sprintf("Net Realized PnL: $%.2f (gross $1500.00 - fees $%.2f + funding $0.00)", 1500.0-total, total)
+	if !strings.Contains(summary, line) {
+		t.Errorf("Summary missing %q:\n%s", line, summary)
+	}
+}
+
+func TestCopyLatency(t *testing.T) {
+	clock := NewReplayClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+
+	// Target filled 30s and 10s before we processed
+	old := createTestFill("BTC", "B", 0.1, 50000.0, "0.0", clock.Now().Add(-30*time.Second).Unix())
+	pt.ProcessFill(old)
+	recent := createTestFill("ETH", "B", 1.0, 3000.0, "0.0", clock.Now().Add(-10*time.Second).Unix())
+	pt.ProcessFill(recent)
+
+	avg, peak := pt.CopyLatency()
+	if avg < 19*time.Second || avg > 21*time.Second {
+		t.Errorf("Average copy latency = %v, want ~20s", avg)
+	}
+	if peak < 29*time.Second || peak > 31*time.Second {
+		t.Errorf("Max copy latency = %v, want ~30s", peak)
+	}
+}
+
+func TestCoinAliasReconcilesRename(t *testing.T) {
+	pt := NewTestPaperTrader()
+	now := time.Now().Unix()
+
+	// Opened before the exchange renamed the coin
+	pt.ProcessFill(createTestFill("OLDCOIN", "B", 100.0, 10.0, "0.0", now))
+	if size := pt.Positions["OLDCOIN"].Size; size != 100.0 {
+		t.Fatalf("OLDCOIN size = %.2f, want 100.00", size)
+	}
+
+	// The target's close arrives under the new symbol
+	pt.CoinAliases = map[string]string{"OLDCOIN": "NEWCOIN"}
+	pt.ProcessFill(createTestFill("NEWCOIN", "A", 100.0, 12.0, "200.0", now+1))
+
+	if _, exists := pt.Positions["OLDCOIN"]; exists {
+		t.Errorf("Old symbol position left orphaned")
+	}
+	pos := pt.Positions["NEWCOIN"]
+	if pos == nil || pos.Size != 0 {
+		t.Fatalf("NEWCOIN position = %+v, want closed", pos)
+	}
+	last := pt.TradeHistory[len(pt.TradeHistory)-1]
+	if last.Action != "CLOSE" {
+		t.Errorf("Close under new symbol booked as %s, want CLOSE", last.Action)
+	}
+	// (12 - 10) * 100 = 200
+	if math.Abs(pt.TotalRealizedPnL-200.0) > 0.01 {
+		t.Errorf("Realized PnL = %.2f, want 200.00", pt.TotalRealizedPnL)
+	}
+
+	// Late fills still quoting the old symbol land on the new one
+	pt.ProcessFill(createTestFill("OLDCOIN", "B", 50.0, 12.0, "0.0", now+2))
+	if _, exists := pt.Positions["OLDCOIN"]; exists {
+		t.Errorf("Fill under old symbol opened a position under it")
+	}
+}
+
+// captureStdout returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestSummaryPositionOrder(t *testing.T) {
+	pt := NewTestPaperTrader()
+	now := time.Now().Unix()
+	pt.ProcessFill(createTestFill("SOL", "B", 30.0, 200.0, "0.0", now))  // $6k
+	pt.ProcessFill(createTestFill("BTC", "B", 0.1, 50000.0, "0.0", now)) // $5k
+	pt.ProcessFill(createTestFill("ETH", "A", 1.0, 3000.0, "0.0", now))  // $3k short
+
+	order := func() []string {
+		var coins []string
+		for _, line := range strings.Split(captureStdout(t, pt.PrintPortfolioSummary), "\n") {
+			if fields := strings.Fields(line); len(fields) > 1 && fields[1] == "|" {
+				coins = append(coins, fields[0])
+			}
+		}
+		return coins
+	}
+
+	if got := strings.Join(order(), ","); got != "SOL,BTC,ETH" {
+		t.Errorf("Notional order = %s, want SOL,BTC,ETH", got)
+	}
+
+	pt.PositionSort = "coin"
+	if got := strings.Join(order(), ","); got != "BTC,ETH,SOL" {
+		t.Errorf("Coin order = %s, want BTC,ETH,SOL", got)
+	}
+
+	// SOL falls, ETH short gains
+	pt.UpdateMarks(map[string]float64{"SOL": 190.0, "ETH": 2900.0})
+	pt.PositionSort = "pnl"
+	if got := strings.Join(order(), ","); got != "ETH,BTC,SOL" {
+		t.Errorf("PnL order = %s, want ETH,BTC,SOL", got)
+	}
+}
+
+func TestSummaryShowsRealizedForFlatCoins(t *testing.T) {
+	pt := NewTestPaperTrader()
+	now := time.Now().Unix()
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 3000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("ETH", "A", 1.0, 3300.0, "300.0", now+1))
+	pt.ProcessFill(createTestFill("BTC", "B", 0.1, 50000.0, "0.0", now+2))
+
+	summary := captureStdout(t, pt.PrintPortfolioSummary)
+	if !strings.Contains(summary, "ETH      | 2 trades | Realized: $300.00") {
+		t.Errorf("Summary missing realized PnL of flat ETH:\n%s", summary)
+	}
+	if !strings.Contains(summary, "Realized: $0.00") {
+		t.Errorf("Active BTC line should show its realized PnL:\n%s", summary)
+	}
+
+	pt.mu.Lock()
+	snapshot := pt.accountSnapshot()
+	pt.mu.Unlock()
+	if _, open := snapshot.Positions["ETH"]; open {
+		t.Errorf("Flat ETH should not be an open snapshot position")
+	}
+	if snapshot.CoinRealized["ETH"] != 300.0 {
+		t.Errorf("Snapshot ETH realized = %.2f, want 300.00", snapshot.CoinRealized["ETH"])
+	}
+}
+
+func TestPerCoinBankrollSlices(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.DisableDynamicSize = false
+	pt.Bankroll = 20000.0
+	pt.Leverage = 1.0
+	pt.BaseNotional = 8000.0
+	pt.PerCoinBankroll = true
+	pt.BankrollCoins = []string{"BTC", "ETH"} // $10k each
+
+	now := time.Now().Unix()
+	for i := int64(0); i < 3; i++ {
+		pt.ProcessFill(createTestFill("BTC", "B", 1.0, 32000.0, "0.0", now+i))
+	}
+	// $8k, then the $2k left in BTC's slice, then nothing
+	if size := pt.Positions["BTC"].Size; size != 0.3125 {
+		t.Errorf("BTC size = %.4f, want 0.3125 ($10k slice)", size)
+	}
+
+	// ETH still has its own full slice, and none of it went to BTC
+	pt.ProcessFill(createTestFill("ETH", "B", 10.0, 2000.0, "0.0", now+3))
+	if size := pt.Positions["ETH"].Size; size != 4.0 {
+		t.Errorf("ETH size = %.4f, want 4.0000 (full base notional)", size)
+	}
+
+	// Coins without a slice can't trade
+	pt.ProcessFill(createTestFill("SOL", "B", 10.0, 100.0, "0.0", now+4))
+	if pt.Holds("SOL") {
+		t.Errorf("SOL has no slice but opened %.4f", pt.Positions["SOL"].Size)
+	}
+
+	// A configured slice takes its amount and the rest splits among the others
+	pt.CoinBankrolls = map[string]float64{"BTC": 15000.0}
+	if got := pt.coinBankroll("ETH"); got != 5000.0 {
+		t.Errorf("ETH slice = %.2f, want 5000.00", got)
+	}
+}
+
+func TestAggregateByOrder(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.AggregateByOrder = true
+	pt.MinTradeInterval = time.Minute
+
+	// Three child fills of one order, spread past the volume window
+	now := clock.Now().Unix()
+	for i, size := range []float64{0.5, 0.3, 0.2} {
+		fill := createTestFill("BTC", "B", size, 50000.0+float64(i)*10, "0.0", now+int64(i))
+		fill.Oid = 100
+		pt.ProcessFill(fill)
+		clock.Advance(10 * time.Second)
+	}
+	if len(pt.TradeHistory) != 0 {
+		t.Fatalf("Order booked before it was complete")
+	}
+
+	// The next order closes the first off as a single trade
+	next := createTestFill("BTC", "A", 0.4, 50100.0, "0.0", now+3)
+	next.Oid = 101
+	pt.ProcessFill(next)
+	if len(pt.TradeHistory) != 1 {
+		t.Fatalf("Trades = %d, want 1 for the first order", len(pt.TradeHistory))
+	}
+	trade := pt.TradeHistory[0]
+	want := 0.5*50000.0 + 0.3*50010.0 + 0.2*50020.0 // over 1.0 total
+	if math.Abs(trade.Size-1.0) > 1e-9 || math.Abs(trade.Price-want) > 1e-6 {
+		t.Errorf("Order trade = %.4f @ %.2f, want 1.0000 @ %.2f", trade.Size, trade.Price, want)
+	}
+
+	// The last order books once it has gone quiet
+	pt.UpdateMarks(map[string]float64{"BTC": 50100.0})
+	if len(pt.TradeHistory) != 1 {
+		t.Fatalf("Last order booked before going quiet")
+	}
+	clock.Advance(time.Minute)
+	pt.UpdateMarks(map[string]float64{"BTC": 50100.0})
+	if len(pt.TradeHistory) != 2 || math.Abs(pt.Positions["BTC"].Size-0.6) > 1e-9 {
+		t.Errorf("Trades = %d, BTC = %.4f; want 2 trades and 0.6000",
+			len(pt.TradeHistory), pt.Positions["BTC"].Size)
+	}
+}
+
+func TestFlushOnSideChange(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+	run := func(flush bool) *PaperTrader {
+		clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		pt := NewTestPaperTrader()
+		pt.Clock = clock
+		pt.VolumeThreshold = 1e12 // batches only book on a side change
+		pt.MinTradeInterval = time.Minute
+		pt.FlushOnSideChange = flush
+
+		now := clock.Now().Unix()
+		for i, fill := range []*Fill{
+			createTestFill("ETH", "B", 0.5, 3000.0, "0.0", now),
+			createTestFill("ETH", "B", 0.5, 3000.0, "0.0", now+1),
+			createTestFill("ETH", "A", 0.4, 3100.0, "40.0", now+2),
+			createTestFill("ETH", "B", 0.1, 3050.0, "0.0", now+3), // books the sell
+		} {
+			fill.Hash = fmt.Sprintf("0xside%d", i)
+			pt.ProcessFill(fill)
+			clock.Advance(time.Second)
+		}
+		return pt
+	}
+
+	pt := run(true)
+	if len(pt.TradeHistory) != 2 {
+		t.Fatalf("Trades = %d, want the buys and the sell booked separately", len(pt.TradeHistory))
+	}
+	open, reduce := pt.TradeHistory[0], pt.TradeHistory[1]
+	if open.Action != "OPEN" || math.Abs(open.Size-1.0) > 1e-9 {
+		t.Errorf("First trade = %s %.4f, want OPEN 1.0000", open.Action, open.Size)
+	}
+	if reduce.Action != "REDUCE" || math.Abs(reduce.Size-0.4) > 1e-9 || reduce.Price != 3100.0 {
+		t.Errorf("Second trade = %s %.4f @ %.2f, want REDUCE 0.4000 @ 3100.00",
+			reduce.Action, reduce.Size, reduce.Price)
+	}
+
+	if blended := run(false); len(blended.TradeHistory) != 0 {
+		t.Errorf("Without the flag the window should keep aggregating, got %d trades",
+			len(blended.TradeHistory))
+	}
+}
+
+func TestEvictLongFlatCoins(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.EvictFlatAfter = time.Hour
+
+	// 50 transient round trips, each closed for a $10 profit
+	now := clock.Now().Unix()
+	for i := 0; i < 50; i++ {
+		coin := fmt.Sprintf("COIN%d", i)
+		pt.ProcessFill(createTestFill(coin, "B", 1.0, 100.0, "0.0", now))
+		pt.ProcessFill(createTestFill(coin, "A", 1.0, 110.0, "10.0", now))
+	}
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now))
+	wantRealized := pt.TotalRealizedPnL
+
+	// Within the window flat coins are kept
+	clock.Advance(30 * time.Minute)
+	pt.UpdateMarks(map[string]float64{"BTC": 50000.0})
+	if len(pt.Positions) != 51 {
+		t.Fatalf("Positions = %d before the window, want 51", len(pt.Positions))
+	}
+
+	// A coin that just went flat survives the sweep
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 3000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("ETH", "A", 1.0, 3100.0, "100.0", now))
+
+	clock.Advance(31 * time.Minute)
+	pt.UpdateMarks(map[string]float64{"BTC": 50000.0})
+	if len(pt.Positions) != 2 || pt.Positions["BTC"] == nil || pt.Positions["ETH"] == nil {
+		t.Fatalf("Positions after eviction = %d, want BTC and ETH only", len(pt.Positions))
+	}
+	if len(pt.LastTradeTime) != 2 || len(pt.MarkTime) > 2 {
+		t.Errorf("Per-coin maps not trimmed: %d trade times, %d marks",
+			len(pt.LastTradeTime), len(pt.MarkTime))
+	}
+	if pt.EvictedCoins != 50 || math.Abs(pt.EvictedRealized-500.0) > 1e-9 {
+		t.Errorf("Evicted %d coins with $%.2f realized, want 50 and $500.00",
+			pt.EvictedCoins, pt.EvictedRealized)
+	}
+	if pt.TotalRealizedPnL != wantRealized+100.0 {
+		t.Errorf("Total realized = %.2f, want %.2f", pt.TotalRealizedPnL, wantRealized+100.0)
+	}
+	if summary := captureStdout(t, pt.PrintPortfolioSummary); !strings.Contains(summary, "50 older coins | Realized: $500.00") {
+		t.Errorf("Summary missing evicted realized PnL:\n%s", summary)
+	}
+}
+
+func TestDepletedCapitalIsReduceOnly(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.DisableDynamicSize = false
+	pt.Bankroll = 200.0
+	pt.Leverage = 10.0
+	pt.BaseNotional = 1000.0
+	var alerts []string
+	pt.OnAlert = func(msg string) { alerts = append(alerts, msg) }
+	now := time.Now().Unix()
+
+	// Two $1k copies: 0.04 BTC at 50k
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now))
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now+1))
+	if size := pt.Positions["BTC"].Size; math.Abs(size-0.04) > 1e-9 {
+		t.Fatalf("BTC size = %.4f, want 0.0400", size)
+	}
+
+	// 0.04 * 6000 = $240 loss against a $200 bankroll
+	pt.UpdateMarks(map[string]float64{"BTC": 44000.0})
+	if capital := pt.calculateAvailableCapital(); capital != 0 {
+		t.Errorf("Available capital = %.2f, want clamped to 0", capital)
+	}
+	if !pt.ReduceOnly() {
+		t.Fatalf("Negative equity should switch to reduce-only")
+	}
+	if len(alerts) != 1 {
+		t.Errorf("Alerts = %v, want one depletion alert", alerts)
+	}
+
+	// New opens are blocked
+	pt.ProcessFill(createTestFill("ETH", "B", 1.0, 3000.0, "0.0", now+2))
+	if pos, exists := pt.Positions["ETH"]; exists && pos.Size != 0 {
+		t.Errorf("ETH opened with no capital: %.4f", pos.Size)
+	}
+	// So are adds
+	pt.ProcessFill(createTestFill("BTC", "B", 1.0, 44000.0, "0.0", now+3))
+	if size := pt.Positions["BTC"].Size; math.Abs(size-0.04) > 1e-9 {
+		t.Errorf("BTC added to with no capital: %.4f", size)
+	}
+
+	// Reduces still go through: $1k at 44k is 0.0227 of the 0.04
+	trades := len(pt.TradeHistory)
+	pt.ProcessFill(createTestFill("BTC", "A", 1.0, 44000.0, "0.0", now+4))
+	if len(pt.TradeHistory) != trades+1 || pt.TradeHistory[trades].Action != "REDUCE" {
+		t.Fatalf("Reduce blocked while capital depleted")
+	}
+	want := 0.04 - 1000.0/44000.0
+	if size := pt.Positions["BTC"].Size; math.Abs(size-want) > 1e-9 {
+		t.Errorf("BTC size after reduce = %.6f, want %.6f", size, want)
+	}
+
+	// Target selling more than we hold closes rather than flips
+	pt.ProcessFill(createTestFill("BTC", "A", 1.0, 44000.0, "0.0", now+5))
+	if last := pt.TradeHistory[len(pt.TradeHistory)-1]; last.Action != "CLOSE" {
+		t.Errorf("Final exit booked as %s, want CLOSE", last.Action)
+	}
+}
+
+func TestRealizedByAction(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+
+	now := clock.Now().Unix()
+	for i, fill := range []*Fill{
+		createTestFill("BTC", "B", 2.0, 100.0, "0.0", now),
+		createTestFill("BTC", "A", 1.0, 110.0, "0.0", now+1), // REDUCE +10
+		createTestFill("BTC", "A", 1.0, 120.0, "0.0", now+2), // CLOSE +20
+		createTestFill("ETH", "B", 1.0, 50.0, "0.0", now+3),
+		createTestFill("ETH", "A", 3.0, 40.0, "0.0", now+4), // REVERSE -10
+		createTestFill("ETH", "B", 1.0, 30.0, "0.0", now+5), // REDUCE +10
+	} {
+		fill.Hash = fmt.Sprintf("0xaction%d", i)
+		pt.ProcessFill(fill)
+		clock.Advance(time.Second)
+	}
+
+	want := map[string]float64{"REDUCE": 20.0, "CLOSE": 20.0, "REVERSE": -10.0}
+	sum := 0.0
+	for action, pnl := range pt.RealizedByAction {
+		if math.Abs(pnl-want[action]) > 1e-9 {
+			t.Errorf("%s realized = %.2f, want %.2f", action, pnl, want[action])
+		}
+		sum += pnl
+	}
+	if len(pt.RealizedByAction) != len(want) {
+		t.Errorf("Realized by action = %v, want %v", pt.RealizedByAction, want)
+	}
+	if math.Abs(sum-pt.TotalRealizedPnL) > 1e-9 || math.Abs(sum-30.0) > 1e-9 {
+		t.Errorf("Actions sum to %.2f, total realized %.2f; want both 30.00", sum, pt.TotalRealizedPnL)
+	}
+
+	output := captureStdout(t, pt.PrintPortfolioSummary)
+	if !strings.Contains(output, "by action: REDUCE $20.00, CLOSE $20.00, REVERSE $-10.00") {
+		t.Errorf("Summary should break realized PnL down by action:\n%s", output)
+	}
+}
+
+func TestMaxPendingFillsForcesFlush(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.VolumeThreshold = 1e12 // never reached
+	pt.MinTradeInterval = time.Hour
+	pt.MaxPendingFills = 5
+
+	now := clock.Now().Unix()
+	for i := 0; i < 12; i++ {
+		fill := createTestFill("ETH", "B", 0.1, 3000.0, "0.0", now+int64(i))
+		fill.Hash = fmt.Sprintf("0xdepth%d", i)
+		pt.ProcessFill(fill)
+		clock.Advance(time.Second)
+
+		if pending := len(pt.PendingFills["ETH"]); pending >= pt.MaxPendingFills {
+			t.Fatalf("After fill %d: %d pending, want fewer than the cap", i, pending)
+		}
+	}
+
+	// Two batches of five booked at the cap, two fills still pending
+	if len(pt.TradeHistory) != 2 {
+		t.Fatalf("Trades = %d, want 2 forced flushes", len(pt.TradeHistory))
+	}
+	for _, trade := range pt.TradeHistory {
+		if math.Abs(trade.Size-0.5) > 1e-9 {
+			t.Errorf("Flushed trade size = %.4f, want 0.5000", trade.Size)
+		}
+	}
+	if pending := len(pt.PendingFills["ETH"]); pending != 2 {
+		t.Errorf("Pending = %d, want 2", pending)
+	}
+}
+
+func TestSizeEpsilonSnapsDustFlat(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.SizeDecimals = map[string]int{"BTC": 5, "MEME": 0}
+	pt.SizeEpsilon = 1e-6
+
+	now := clock.Now().Unix()
+	for i, fill := range []*Fill{
+		createTestFill("MEME", "B", 1000000.0, 0.002, "0.0", now),
+		createTestFill("MEME", "A", 999999.7, 0.003, "0.0", now+1), // 0.3 left, under half a lot
+		createTestFill("BTC", "B", 0.001, 50000.0, "0.0", now+2),
+		createTestFill("BTC", "A", 0.0009, 51000.0, "0.0", now+3), // 0.0001 left, 20 lots
+		createTestFill("XYZ", "B", 1.0, 10.0, "0.0", now+4),
+		createTestFill("XYZ", "A", 0.9999995, 11.0, "0.0", now+5), // under size_epsilon
+	} {
+		fill.Hash = fmt.Sprintf("0xdust%d", i)
+		pt.ProcessFill(fill)
+		clock.Advance(time.Second)
+	}
+
+	for _, coin := range []string{"MEME", "XYZ"} {
+		if size := pt.Positions[coin].Size; size != 0 {
+			t.Errorf("%s dust %.10f should snap flat", coin, size)
+		}
+	}
+	if last := pt.TradeHistory[1]; last.Action != "CLOSE" || last.Size != 1000000.0 {
+		t.Errorf("MEME exit = %s %.4f, want CLOSE 1000000", last.Action, last.Size)
+	}
+	if math.Abs(pt.Positions["MEME"].RealizedPnL-1000.0) > 1e-6 {
+		t.Errorf("MEME realized = %.6f, want the full 1000.00", pt.Positions["MEME"].RealizedPnL)
+	}
+
+	if size := pt.Positions["BTC"].Size; math.Abs(size-0.0001) > 1e-12 {
+		t.Errorf("Small BTC position = %.8f, want 0.0001 kept", size)
+	}
+}
+
+func TestAnnualizedReturn(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.StartTime = start
+	pt.Bankroll = 100000.0
+	pt.TotalRealizedPnL = 1200.0
+	pt.TotalFees = 200.0 // net $1000, 1% of the bankroll
+
+	// A 2-minute session is too short to extrapolate
+	clock.Advance(2 * time.Minute)
+	if annualized := pt.AnnualizedReturn(); annualized != 0 {
+		t.Errorf("Short session annualized = %.4f, want 0", annualized)
+	}
+	if output := captureStdout(t, pt.PrintPortfolioSummary); strings.Contains(output, "Annualized") {
+		t.Errorf("Short session summary should not annualize:\n%s", output)
+	}
+
+	// 1% over a fifth of a year is 5% a year
+	clock.Advance(73*24*time.Hour - 2*time.Minute)
+	if annualized := pt.AnnualizedReturn(); math.Abs(annualized-0.05) > 1e-9 {
+		t.Errorf("Annualized = %.4f, want 0.0500", annualized)
+	}
+	if output := captureStdout(t, pt.PrintPortfolioSummary); !strings.Contains(output, "Annualized Return: 5.0%") {
+		t.Errorf("Summary should show the annualized return:\n%s", output)
+	}
+}
+
+func TestMinSizeRejectsDustOpens(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.DisableDynamicSize = false
+	pt.BaseNotional = 40.0
+	pt.MinSizes = map[string]float64{"BTC": 0.001, "ETH": 0.01}
+
+	now := clock.Now().Unix()
+	for i, fill := range []*Fill{
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now),  // $40 is 0.0008 BTC
+		createTestFill("ETH", "B", 1.0, 3000.0, "0.0", now+1), // $40 is 0.0133 ETH
+	} {
+		fill.Hash = fmt.Sprintf("0xmin%d", i)
+		pt.ProcessFill(fill)
+		clock.Advance(time.Second)
+	}
+
+	if position, exists := pt.Positions["BTC"]; exists && position.Size != 0 {
+		t.Errorf("BTC opened at %.6f, below the 0.001 minimum", position.Size)
+	}
+	if size := pt.Positions["ETH"].Size; math.Abs(size-40.0/3000.0) > 1e-9 {
+		t.Errorf("ETH size = %.6f, want %.6f", size, 40.0/3000.0)
+	}
+	if pt.TotalTrades != 1 {
+		t.Errorf("Trades = %d, want only the ETH open", pt.TotalTrades)
+	}
+
+	// A reversal that would leave a sub-minimum position only closes
+	pt.DisableDynamicSize = true
+	pt.Positions["ETH"].Size = 1.0
+	reverse := createTestFill("ETH", "A", 1.005, 3100.0, "0.0", now+2)
+	reverse.Hash = "0xminreverse"
+	pt.ProcessFill(reverse)
+	if size := pt.Positions["ETH"].Size; size != 0 {
+		t.Errorf("ETH after reversal = %.6f, want flat", size)
+	}
+	if last := pt.TradeHistory[len(pt.TradeHistory)-1]; last.Action != "CLOSE" || last.Size != 1.0 {
+		t.Errorf("Reversal booked as %s %.4f, want CLOSE 1.0", last.Action, last.Size)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// defaultPerformanceWeight is the most performance weighting scales a copy
+// either way when unconfigured
+const defaultPerformanceWeight = 0.5
+
+// targetForm is the target's realized PnL with older results decayed by
+// the performance half-life
+type targetForm struct {
+	score float64 // decayed signed PnL
+	gross float64 // decayed |PnL|
+	time  int64   // fill time the sums are decayed to, ms
+}
+
+// recordTargetPnL folds a target fill's closed PnL into its form. Decay
+// follows fill times, so replays weight the same as live runs. Caller must
+// hold pt.mu.
+func (pt *PaperTrader) recordTargetPnL(fill *Fill) {
+	if pt.FormHalfLife <= 0 {
+		return
+	}
+	pnl, err := strconv.ParseFloat(fill.ClosedPnl, 64)
+	if err != nil || pnl == 0 {
+		return
+	}
+
+	form := &pt.form
+	if fill.Time > form.time {
+		if form.time != 0 {
+			elapsed := time.Duration(fill.Time-form.time) * time.Millisecond
+			decay := math.Pow(0.5, float64(elapsed)/float64(pt.FormHalfLife))
+			form.score *= decay
+			form.gross *= decay
+		}
+		form.time = fill.Time
+	}
+	form.score += pnl
+	form.gross += math.Abs(pnl)
+}
+
+// performanceFactor scales a copy by the target's recent form: 1 plus
+// FormWeight times decayed PnL over decayed gross PnL, so a target
+// on a winning streak is copied up to 1+weight times base size and one on
+// a losing streak down to 1-weight. Caller must hold pt.mu.
+func (pt *PaperTrader) performanceFactor() float64 {
+	if pt.FormHalfLife <= 0 || pt.form.gross == 0 {
+		return 1.0
+	}
+	weight := pt.FormWeight
+	if weight <= 0 {
+		weight = defaultPerformanceWeight
+	}
+	return 1 + weight*pt.form.score/pt.form.gross
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayClock is a Clock that only moves when a replay sets it. Timers fire
+// immediately since simulated time never waits.
+type ReplayClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewReplayClock(start time.Time) *ReplayClock {
+	return &ReplayClock{now: start}
+}
+
+func (c *ReplayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ReplayClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now().Add(d)
+	return ch
+}
+
+// Set moves the clock to t; it never moves backwards
+func (c *ReplayClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Replay feeds fills through pt in order, moving the clock to each fill's
+// timestamp first so aggregation, decay and sizing see historical time.
+// The same fills and start time always produce the same trades.
+func Replay(pt *PaperTrader, clock *ReplayClock, fills []*Fill) {
+	pt.Clock = clock
+	for _, fill := range fills {
+		clock.Set(time.UnixMilli(fill.Time))
+		pt.ProcessFill(fill)
+	}
+}
+
+// ScaledClock runs from start at speed times the rate of a wall clock, so a
+// replay at speed 10 covers ten minutes of history per real minute
+type ScaledClock struct {
+	start     time.Time
+	wallStart time.Time
+	speed     float64
+	wall      Clock
+}
+
+func NewScaledClock(start time.Time, speed float64, wall Clock) *ScaledClock {
+	return &ScaledClock{start: start, wallStart: wall.Now(), speed: speed, wall: wall}
+}
+
+func (c *ScaledClock) Now() time.Time {
+	elapsed := c.wall.Now().Sub(c.wallStart)
+	return c.start.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+func (c *ScaledClock) After(d time.Duration) <-chan time.Time {
+	return c.wall.After(time.Duration(float64(d) / c.speed))
+}
+
+// ReplayAtSpeed feeds fills through pt keeping their original spacing,
+// compressed by speed, on a clock that keeps moving between fills. Unlike
+// Replay, time-triggered aggregation and decay see time pass as they would
+// live. The wall clock is the system clock when nil.
+func ReplayAtSpeed(pt *PaperTrader, fills []*Fill, speed float64, wall Clock) {
+	if len(fills) == 0 {
+		return
+	}
+	if wall == nil {
+		wall = systemClock{}
+	}
+	clock := NewScaledClock(time.UnixMilli(fills[0].Time), speed, wall)
+	pt.Clock = clock
+	for _, fill := range fills {
+		if wait := time.UnixMilli(fill.Time).Sub(clock.Now()); wait > 0 {
+			<-clock.After(wait)
+		}
+		pt.ProcessFill(fill)
+	}
+}
+
+// runReplayCommand replays saved fills files through a trader configured like the
+// bot, at speed times their original pace (0 = instantly), and prints the
+// resulting summary. Replayed trades are stored under a temporary data
+// directory, away from the live files.
+func runReplayCommand(config *Config, speed float64, files []string) error {
+	var fills []*Fill
+	for _, filename := range files {
+		records, err := LoadFills(filename)
+		if err != nil {
+			return fmt.Errorf("failed to load fills from %s: %v", filename, err)
+		}
+		for i := range records {
+			fill := records[i].Fill()
+			if err := fill.Normalize(); err != nil || strings.HasPrefix(fill.Hash, "local-") {
+				continue // bot-initiated trades aren't target fills
+			}
+			fills = append(fills, fill)
+		}
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time < fills[j].Time })
+
+	dir, err := os.MkdirTemp("", "hype-copy-replay-")
+	if err != nil {
+		return fmt.Errorf("failed to create replay data dir: %w", err)
+	}
+	os.Setenv("PREFIX", dir)
+	log.Printf("replay: %d fills at %gx, storing under %s", len(fills), speed, dir)
+
+	bot, err := NewBot(config)
+	if err != nil {
+		return err
+	}
+	pt := bot.paperTrader
+	if len(fills) > 0 {
+		pt.StartTime = time.UnixMilli(fills[0].Time)
+	}
+	if speed > 0 {
+		ReplayAtSpeed(pt, fills, speed, nil)
+	} else if len(fills) > 0 {
+		Replay(pt, NewReplayClock(pt.StartTime), fills)
+	}
+	pt.PrintPortfolioSummary()
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files")
+
+// replayFixture is a fixed fill sequence exercising volume aggregation,
+// time-triggered flushes, decay, dynamic sizing and a reversal
+func replayFixture(start time.Time) []*Fill {
+	at := func(seconds int) int64 {
+		return start.Add(time.Duration(seconds) * time.Second).UnixMilli()
+	}
+	return []*Fill{
+		{Coin: "BTC", Side: SideBuy, Size: 0.01, Price: 60000, Time: at(0), Hash: "r1"},
+		{Coin: "BTC", Side: SideBuy, Size: 0.01, Price: 60100, Time: at(5), Hash: "r2"},
+		{Coin: "ETH", Side: SideBuy, Size: 0.1, Price: 3000, Time: at(20), Hash: "r3"},
+		{Coin: "ETH", Side: SideBuy, Size: 0.1, Price: 3010, Time: at(95), Hash: "r4"},
+		{Coin: "BTC", Side: SideSell, Size: 0.005, Price: 60500, Time: at(120), Hash: "r5"},
+		{Coin: "BTC", Side: SideSell, Size: 0.03, Price: 60400, Time: at(125), Hash: "r6"},
+		{Coin: "SOL", Side: SideSell, Size: 2, Price: 150, Time: at(200), Hash: "r7"},
+		{Coin: "SOL", Side: SideSell, Size: 5, Price: 149, Time: at(300), Hash: "r8"},
+		{Coin: "ETH", Side: SideSell, Size: 0.5, Price: 3050, Time: at(400), Hash: "r9"},
+		{Coin: "BTC", Side: SideBuy, Size: 0.02, Price: 59900, Time: at(500), Hash: "r10"},
+		{Coin: "SOL", Side: SideBuy, Size: 7, Price: 155, Time: at(600), Hash: "r11"},
+	}
+}
+
+// runReplay replays the fixture and renders trade history and final PnL
+// in a timezone-independent text form
+func runReplay(t *testing.T) []byte {
+	t.Helper()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	pt := NewPaperTrader(10000.0, 2.0, 1000.0)
+	pt.StartTime = start
+	Replay(pt, NewReplayClock(start), replayFixture(start))
+
+	var out bytes.Buffer
+	for _, trade := range pt.TradeHistory {
+		fmt.Fprintf(&out, "%d %s %s %s size=%.8f px=%.8f realized=%.8f pos=%.8f unrealized=%.8f\n",
+			trade.Timestamp.UnixMilli(), trade.Coin, trade.Action, trade.Side,
+			trade.Size, trade.Price, trade.RealizedPnL, trade.PositionSize, trade.UnrealizedPnL)
+	}
+	fmt.Fprintf(&out, "trades=%d realized=%.8f\n", pt.TotalTrades, pt.TotalRealizedPnL)
+	return out.Bytes()
+}
+
+func TestReplayDeterminism(t *testing.T) {
+	// Replays write fills and account snapshots
+	t.Setenv("PREFIX", t.TempDir())
+
+	first := runReplay(t)
+	second := runReplay(t)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("Replays differ:\n%s\nvs\n%s", first, second)
+	}
+
+	golden := filepath.Join("testdata", "replay_golden.txt")
+	if *updateGolden {
+		if err := os.WriteFile(golden, first, 0644); err != nil {
+			t.Fatalf("Failed to write golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(first, want) {
+		t.Errorf("Replay output differs from %s (run with -update to accept):\ngot:\n%s\nwant:\n%s",
+			golden, first, want)
+	}
+}
+
+func TestReplayAtSpeedKeepsScaledSpacing(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{0, 10 * time.Second, 40 * time.Second, 100 * time.Second}
+	var fills []*Fill
+	for i, offset := range offsets {
+		side := SideBuy
+		if i%2 == 1 {
+			side = SideSell
+		}
+		fills = append(fills, &Fill{Coin: "BTC", Side: side, Size: 0.1, Price: 50000,
+			Time: start.Add(offset).UnixMilli(), Hash: fmt.Sprintf("speed%d", i)})
+	}
+
+	wall := newFakeClock(time.Unix(1700000000, 0))
+	wallStart := wall.Now()
+	pt := NewTestPaperTrader()
+	var wallTimes, simTimes []time.Time
+	pt.OnTrade = func(trade *PaperTrade) {
+		wallTimes = append(wallTimes, wall.Now())
+		simTimes = append(simTimes, pt.now())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ReplayAtSpeed(pt, fills, 10, wall)
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			if wait, ok := wall.NextTimer(); ok {
+				wall.Advance(wait)
+			} else {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	if len(wallTimes) != len(fills) {
+		t.Fatalf("Trades = %d, want %d", len(wallTimes), len(fills))
+	}
+	for i, offset := range offsets {
+		if got, want := wallTimes[i].Sub(wallStart), offset/10; got != want {
+			t.Errorf("Fill %d ran %v into the replay, want %v", i, got, want)
+		}
+		if !simTimes[i].Equal(start.Add(offset)) {
+			t.Errorf("Fill %d saw simulated time %v, want %v", i, simTimes[i], start.Add(offset))
+		}
+	}
+}
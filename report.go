@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session report formats
+const (
+	ReportMarkdown = "markdown"
+	ReportJSON     = "json"
+)
+
+// SessionReport is everything about a session worth keeping once it ends
+type SessionReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	StartedAt   time.Time        `json:"started_at"`
+	Summary     ReportSummary    `json:"summary"`
+	Positions   []ReportPosition `json:"positions"`
+	Coins       []ReportCoin     `json:"coins"`
+	Trades      []ReportTrade    `json:"trades"`
+}
+
+// ReportSummary is the session's headline numbers
+type ReportSummary struct {
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	Fees          float64 `json:"fees"`
+	Funding       float64 `json:"funding"`
+	NetPnL        float64 `json:"net_pnl"` // realized + unrealized - fees + funding
+	Trades        int     `json:"trades"`
+	WinRate       float64 `json:"win_rate"`     // share of PnL-realizing trades that won
+	MaxDrawdown   float64 `json:"max_drawdown"` // deepest fall of net realized PnL from a peak
+}
+
+// ReportPosition is one position open at the end of the session
+type ReportPosition struct {
+	Coin          string  `json:"coin"`
+	Size          float64 `json:"size"`
+	AvgEntryPrice float64 `json:"avg_entry_price"`
+	LastPrice     float64 `json:"last_price"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+}
+
+// ReportCoin is one coin's share of the session
+type ReportCoin struct {
+	Coin          string  `json:"coin"`
+	Trades        int     `json:"trades"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	Fees          float64 `json:"fees"`
+}
+
+// ReportTrade is one booked trade
+type ReportTrade struct {
+	Time        time.Time `json:"time"`
+	Coin        string    `json:"coin"`
+	Action      string    `json:"action"`
+	Side        string    `json:"side"`
+	Size        float64   `json:"size"`
+	Price       float64   `json:"price"`
+	RealizedPnL float64   `json:"realized_pnl"`
+	Fee         float64   `json:"fee"`
+}
+
+// SessionReport builds the report for the session so far
+func (pt *PaperTrader) SessionReport() SessionReport {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	report := SessionReport{GeneratedAt: pt.now(), StartedAt: pt.StartTime}
+	summary := &report.Summary
+	summary.RealizedPnL = pt.TotalRealizedPnL
+	summary.Fees = pt.TotalFees
+	summary.Funding = pt.TotalFunding
+	summary.Trades = pt.TotalTrades
+
+	coins := make(map[string]*ReportCoin)
+	coin := func(name string) *ReportCoin {
+		if coins[name] == nil {
+			coins[name] = &ReportCoin{Coin: name}
+		}
+		return coins[name]
+	}
+
+	for name, position := range pt.Positions {
+		if position.Size == 0 {
+			continue
+		}
+		unrealized := pt.calculateUnrealizedPnL(position)
+		summary.UnrealizedPnL += unrealized
+		coin(name).UnrealizedPnL = unrealized
+		report.Positions = append(report.Positions, ReportPosition{
+			Coin:          name,
+			Size:          position.Size,
+			AvgEntryPrice: position.AvgEntryPrice,
+			LastPrice:     position.LastPrice,
+			UnrealizedPnL: unrealized,
+		})
+	}
+	sort.Slice(report.Positions, func(i, j int) bool {
+		return report.Positions[i].Coin < report.Positions[j].Coin
+	})
+	summary.NetPnL = summary.RealizedPnL + summary.UnrealizedPnL - summary.Fees + summary.Funding
+
+	wins, closes := 0, 0
+	net, peak := 0.0, 0.0
+	for _, trade := range pt.TradeHistory {
+		stats := coin(trade.Coin)
+		stats.Trades++
+		stats.RealizedPnL += trade.RealizedPnL
+		stats.Fees += trade.Fee
+
+		if trade.RealizedPnL != 0 {
+			closes++
+			if trade.RealizedPnL > 0 {
+				wins++
+			}
+		}
+		net += trade.RealizedPnL - trade.Fee
+		peak = math.Max(peak, net)
+		summary.MaxDrawdown = math.Max(summary.MaxDrawdown, peak-net)
+
+		report.Trades = append(report.Trades, ReportTrade{
+			Time:        trade.Timestamp,
+			Coin:        trade.Coin,
+			Action:      trade.Action,
+			Side:        trade.Side,
+			Size:        trade.Size,
+			Price:       trade.Price,
+			RealizedPnL: trade.RealizedPnL,
+			Fee:         trade.Fee,
+		})
+	}
+	if closes > 0 {
+		summary.WinRate = float64(wins) / float64(closes)
+	}
+
+	for _, stats := range coins {
+		report.Coins = append(report.Coins, *stats)
+	}
+	sort.Slice(report.Coins, func(i, j int) bool { return report.Coins[i].Coin < report.Coins[j].Coin })
+	return report
+}
+
+// WriteMarkdown renders the report as Markdown tables
+func (r SessionReport) WriteMarkdown(w io.Writer) {
+	s := r.Summary
+	fmt.Fprintf(w, "# Session report\n\n")
+	fmt.Fprintf(w, "%s to %s (%v)\n\n", r.StartedAt.Format(time.RFC3339), r.GeneratedAt.Format(time.RFC3339),
+		r.GeneratedAt.Sub(r.StartedAt).Round(time.Second))
+
+	fmt.Fprintf(w, "## Summary\n\n| | |\n|---|---|\n")
+	fmt.Fprintf(w, "| Realized PnL | $%.2f |\n| Unrealized PnL | $%.2f |\n", s.RealizedPnL, s.UnrealizedPnL)
+	fmt.Fprintf(w, "| Fees | $%.2f |\n| Funding | $%.2f |\n| Net PnL | $%.2f |\n", s.Fees, s.Funding, s.NetPnL)
+	fmt.Fprintf(w, "| Trades | %d |\n| Win rate | %.1f%% |\n| Max drawdown | $%.2f |\n",
+		s.Trades, s.WinRate*100, s.MaxDrawdown)
+
+	fmt.Fprintf(w, "\n## Positions\n\n")
+	if len(r.Positions) == 0 {
+		fmt.Fprintln(w, "None open.")
+	} else {
+		fmt.Fprintf(w, "| Coin | Size | Entry | Mark | Unrealized |\n|---|---|---|---|---|\n")
+		for _, p := range r.Positions {
+			fmt.Fprintf(w, "| %s | %.6f | %.4f | %.4f | $%.2f |\n",
+				p.Coin, p.Size, p.AvgEntryPrice, p.LastPrice, p.UnrealizedPnL)
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Coins\n\n| Coin | Trades | Realized | Unrealized | Fees |\n|---|---|---|---|---|\n")
+	for _, c := range r.Coins {
+		fmt.Fprintf(w, "| %s | %d | $%.2f | $%.2f | $%.2f |\n",
+			c.Coin, c.Trades, c.RealizedPnL, c.UnrealizedPnL, c.Fees)
+	}
+
+	fmt.Fprintf(w, "\n## Trades\n\n| Time | Coin | Action | Side | Size | Price | Realized | Fee |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|---|---|---|\n")
+	for _, t := range r.Trades {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %.6f | %.4f | $%.2f | $%.2f |\n",
+			t.Time.Format(time.RFC3339), t.Coin, t.Action, t.Side, t.Size, t.Price, t.RealizedPnL, t.Fee)
+	}
+}
+
+// WriteSessionReport writes the session report in format ("markdown" or
+// "json") to a timestamped file under the data directory's reports and
+// returns its path
+func (pt *PaperTrader) WriteSessionReport(format string) (string, error) {
+	report := pt.SessionReport()
+
+	ext := "md"
+	if format == ReportJSON {
+		ext = "json"
+	}
+	dir := filepath.Join(pt.dataDir(), "reports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("session-%s.%s", report.GeneratedAt.Format("20060102-150405"), ext))
+
+	var body strings.Builder
+	if format == ReportJSON {
+		encoder := json.NewEncoder(&body)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return "", fmt.Errorf("failed to encode report: %w", err)
+		}
+	} else {
+		report.WriteMarkdown(&body)
+	}
+	if err := os.WriteFile(path, []byte(body.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
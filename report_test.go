@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionReport(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.StartTime = clock.Now()
+
+	now := clock.Now().Unix()
+	for i, fill := range []*Fill{
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now),
+		createTestFill("BTC", "A", 0.5, 51000.0, "0.0", now+1), // +500
+		createTestFill("ETH", "B", 2.0, 3000.0, "0.0", now+2),
+		createTestFill("ETH", "A", 2.0, 2900.0, "0.0", now+3), // -200
+	} {
+		fill.Hash = fmt.Sprintf("0xreport%d", i)
+		pt.ProcessFill(fill)
+		clock.Advance(time.Second)
+	}
+
+	path, err := pt.WriteSessionReport(ReportMarkdown)
+	if err != nil {
+		t.Fatalf("WriteSessionReport() error = %v", err)
+	}
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Report file not written: %v", err)
+	}
+	for _, section := range []string{"# Session report", "## Summary", "## Positions", "## Coins", "## Trades"} {
+		if !strings.Contains(string(markdown), section) {
+			t.Errorf("Markdown report missing %q", section)
+		}
+	}
+
+	path, err = pt.WriteSessionReport(ReportJSON)
+	if err != nil {
+		t.Fatalf("WriteSessionReport() error = %v", err)
+	}
+	if !strings.HasSuffix(path, ".json") {
+		t.Errorf("JSON report path = %s, want a .json file", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Report file not written: %v", err)
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		t.Fatalf("JSON report doesn't parse: %v", err)
+	}
+	for _, key := range []string{"generated_at", "started_at", "summary", "positions", "coins", "trades"} {
+		if _, exists := sections[key]; !exists {
+			t.Errorf("JSON report missing %q", key)
+		}
+	}
+
+	report := pt.SessionReport()
+	if s := report.Summary; math.Abs(s.RealizedPnL-300.0) > 1e-9 || s.WinRate != 0.5 ||
+		math.Abs(s.MaxDrawdown-200.0) > 1e-9 || s.Trades != 4 {
+		t.Errorf("Summary = %+v, want $300 realized, 50%% win rate, $200 drawdown, 4 trades", s)
+	}
+	if len(report.Positions) != 1 || report.Positions[0].Coin != "BTC" || len(report.Coins) != 2 {
+		t.Errorf("Positions = %+v, coins = %+v; want BTC open, two coins", report.Positions, report.Coins)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SelfTestCheck is the outcome of one self-test check; Err is nil on pass
+type SelfTestCheck struct {
+	Name string
+	Err  error
+}
+
+// SelfTest checks everything a run depends on without trading: a real
+// /info call, signing a dummy action locally (nothing is sent), the risk
+// limits and that the data directory is writable
+func (b *Bot) SelfTest() []SelfTestCheck {
+	return []SelfTestCheck{
+		{"connectivity", b.checkConnectivity()},
+		{"signing", b.api().checkSigning()},
+		{"config", checkLimits(b.config)},
+		{"storage", checkStorage(getDataDir())},
+	}
+}
+
+func (b *Bot) checkConnectivity() error {
+	mids, err := b.api().GetAllMids()
+	if err != nil {
+		return err
+	}
+	if len(mids) == 0 {
+		return errors.New("no mid prices returned")
+	}
+	return nil
+}
+
+// checkSigning signs a dummy order action and verifies it against the
+// signer's public key
+func (c *Client) checkSigning() error {
+	if len(c.privateKey) != ed25519.PrivateKeySize {
+		return errors.New("no private key")
+	}
+	action, err := json.Marshal(map[string]interface{}{"type": "order", "orders": []interface{}{}, "grouping": "na"})
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(c.publicKey, action, ed25519.Sign(c.privateKey, action)) {
+		return fmt.Errorf("signature does not verify against signer %s", c.SignerPublicKey())
+	}
+	return nil
+}
+
+// checkLimits checks the current mode's risk limits make sense together
+func checkLimits(config *Config) error {
+	limits := config.Limits()
+	switch {
+	case config.TargetAccount == "":
+		return errors.New("no target_account")
+	case limits.Bankroll <= 0:
+		return fmt.Errorf("bankroll must be positive, got %.2f", limits.Bankroll)
+	case limits.Leverage <= 0:
+		return fmt.Errorf("leverage must be positive, got %.2f", limits.Leverage)
+	case limits.BaseNotional <= 0:
+		return fmt.Errorf("base_notional must be positive, got %.2f", limits.BaseNotional)
+	case limits.BaseNotional > limits.Bankroll*limits.Leverage:
+		return fmt.Errorf("base_notional %.2f exceeds bankroll %.2f at %.2fx leverage",
+			limits.BaseNotional, limits.Bankroll, limits.Leverage)
+	}
+	return nil
+}
+
+// checkStorage creates, writes and removes a probe file in dir
+func checkStorage(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	probe, err := os.CreateTemp(dir, ".selftest-*")
+	if err != nil {
+		return fmt.Errorf("data directory not writable: %w", err)
+	}
+	_, err = probe.WriteString("ok\n")
+	if closeErr := probe.Close(); err == nil {
+		err = closeErr
+	}
+	os.Remove(probe.Name())
+	if err != nil {
+		return fmt.Errorf("failed to write to data directory: %w", err)
+	}
+	return nil
+}
+
+// printSelfTest writes one pass/fail line per check and reports whether
+// every check passed
+func printSelfTest(w io.Writer, checks []SelfTestCheck) bool {
+	passed := true
+	for _, check := range checks {
+		if check.Err != nil {
+			passed = false
+			fmt.Fprintf(w, "FAIL %-12s %v\n", check.Name, check.Err)
+		} else {
+			fmt.Fprintf(w, "ok   %s\n", check.Name)
+		}
+	}
+	return passed
+}
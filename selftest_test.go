@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" || !healthy {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"BTC": "50000.0", "ETH": "3000.0"}`))
+	}))
+	defer server.Close()
+
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+
+	results := func() map[string]error {
+		checks := bot.SelfTest()
+		results := make(map[string]error, len(checks))
+		for _, check := range checks {
+			results[check.Name] = check.Err
+		}
+		return results
+	}
+
+	for name, err := range results() {
+		if err != nil {
+			t.Errorf("Healthy %s check failed: %v", name, err)
+		}
+	}
+	var out bytes.Buffer
+	if !printSelfTest(&out, bot.SelfTest()) || !strings.Contains(out.String(), "ok   connectivity") {
+		t.Errorf("Healthy self-test should pass:\n%s", out.String())
+	}
+
+	// Break each dependency in turn
+	healthy = false
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	bot.client.publicKey = otherKey.Public().(ed25519.PublicKey)
+	bot.config.BaseNotional = bot.config.Bankroll * bot.config.Leverage * 2
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PREFIX", blocked) // a file where the data directory should be
+
+	failed := results()
+	for _, name := range []string{"connectivity", "signing", "config", "storage"} {
+		if failed[name] == nil {
+			t.Errorf("Broken %s check passed", name)
+		}
+	}
+	if err := failed["config"]; err != nil && !strings.Contains(err.Error(), "exceeds bankroll") {
+		t.Errorf("Config check error = %v, want base_notional over bankroll", err)
+	}
+
+	out.Reset()
+	if printSelfTest(&out, bot.SelfTest()) || !strings.Contains(out.String(), "FAIL signing") {
+		t.Errorf("Broken self-test should fail:\n%s", out.String())
+	}
+
+	// Signing needs a key at all
+	bot.client.privateKey = nil
+	if err := bot.client.checkSigning(); err == nil || !strings.Contains(err.Error(), "no private key") {
+		t.Errorf("Signing without a key = %v, want no private key", err)
+	}
+}
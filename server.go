@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// newHTTPHandler exposes bot state and manual controls over HTTP
+func (b *Bot) newHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", b.handleHealth)
+	mux.HandleFunc("/livez", b.handleLive)
+	mux.HandleFunc("/readyz", b.handleReady)
+	mux.HandleFunc("/positions", b.handlePositions)
+	mux.HandleFunc("/positions/", b.handlePositionAction)
+	mux.HandleFunc("/pause", b.handlePause)
+	mux.HandleFunc("/resume", b.handlePause)
+	if b.metrics != nil {
+		mux.Handle("/metrics", b.metrics)
+	}
+	if b.config.Monitoring.HTTPAuthToken != "" {
+		// Accepting keys over an open API would let anyone swap them
+		mux.HandleFunc("/credentials", b.handleCredentials)
+	}
+	return b.requireToken(mux)
+}
+
+// probePaths are served without the token under monitoring.http_health_public
+var probePaths = map[string]bool{"/health": true, "/livez": true, "/readyz": true}
+
+// requireToken rejects requests without the configured bearer token. No
+// token configured means the API is open.
+func (b *Bot) requireToken(next http.Handler) http.Handler {
+	token := b.config.Monitoring.HTTPAuthToken
+	if token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] && b.config.Monitoring.HTTPHealthPublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startHTTPServer serves the HTTP API when monitoring.http_listen is set
+func (b *Bot) startHTTPServer() {
+	addr := b.config.Monitoring.HTTPListen
+	if addr == "" {
+		return
+	}
+
+	b.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           b.newHTTPHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("bot: http server listening on %s", addr)
+		if err := b.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error running http server: %v", err)
+		}
+	}()
+}
+
+func (b *Bot) stopHTTPServer() {
+	if b.httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Error stopping http server: %v", err)
+	}
+}
+
+func (b *Bot) handleHealth(w http.ResponseWriter, r *http.Request) {
+	leverage := b.paperTrader.CurrentLeverage()
+	latencyAvg, latencyMax := b.paperTrader.CopyLatency()
+	maxLeverage := b.config.Limits().Leverage
+	health := map[string]interface{}{
+		"status":              "ok",
+		"running":             b.running,
+		"trades":              b.paperTrader.GetTotalTrades(),
+		"leverage":            leverage,
+		"max_leverage":        maxLeverage,
+		"monitor_panics":      atomic.LoadInt64(&b.monitorPanics),
+		"copy_latency_avg_ms": latencyAvg.Milliseconds(),
+		"copy_latency_max_ms": latencyMax.Milliseconds(),
+		"reduce_only":         b.paperTrader.ReduceOnly(),
+		"paused":              b.paused.Load(),
+	}
+	switch {
+	case math.IsInf(leverage, 1):
+		health["leverage"] = nil // JSON has no infinity
+		health["warning"] = "open positions with no available capital"
+	case maxLeverage > 0 && leverage > maxLeverage*leverageWarnRatio:
+		health["warning"] = fmt.Sprintf("leverage above %.0f%% of cap", leverageWarnRatio*100)
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+// handleLive answers the liveness probe: the process is up and serving
+func (b *Bot) handleLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady answers the readiness probe: 503 until the latest fill poll
+// succeeded and while risk checks run on stale marks
+func (b *Bot) handleReady(w http.ResponseWriter, r *http.Request) {
+	reason := ""
+	switch {
+	case !b.pollOK.Load():
+		reason = "no successful poll"
+	case b.paperTrader.RiskDegraded():
+		reason = "stale marks"
+	}
+	if reason != "" {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready", "reason": reason})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+func (b *Bot) handlePositions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	b.paperTrader.mu.Lock()
+	snapshot := b.paperTrader.accountSnapshot()
+	b.paperTrader.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handlePositionAction serves POST /positions/{coin}/close
+func (b *Bot) handlePositionAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/positions/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "close" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	coin := strings.ToUpper(parts[0])
+	trade, err := b.closePosition(coin)
+	switch {
+	case errors.Is(err, ErrNoPosition):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrNoMark):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, trade)
+	}
+}
+
+// handlePause serves POST /pause and POST /resume. While paused the target's
+// fills are still fetched and deduped but not copied; marks and risk checks
+// carry on.
+func (b *Bot) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	paused := r.URL.Path == "/pause"
+	if b.paused.Swap(paused) != paused {
+		if paused {
+			log.Printf("bot: copying paused")
+		} else {
+			log.Printf("bot: copying resumed")
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+}
+
+// closePosition flattens a paper position by hand and, in real mode, the
+// matching real position
+func (b *Bot) closePosition(coin string) (*PaperTrade, error) {
+	trade, err := b.paperTrader.ClosePosition(coin)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("bot: manually closed %s at %.2f", coin, trade.Price)
+
+	if !b.config.PaperTradingOnly {
+		b.syncRealPosition(coin, trade.Price)
+	}
+	return trade, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing http response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
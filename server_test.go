@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServerBot(t *testing.T) *Bot {
+	t.Helper()
+	bot, err := NewBot(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.paperTrader = NewTestPaperTrader()
+	return bot
+}
+
+func TestClosePositionEndpoint(t *testing.T) {
+	bot := newTestServerBot(t)
+	pt := bot.paperTrader
+
+	pt.ProcessFill(createTestFill("ETH", "B", 2.0, 3000.0, "0.0", time.Now().Unix()))
+	pt.UpdateMarks(map[string]float64{"ETH": 3100.0})
+
+	handler := bot.newHTTPHandler()
+	req := httptest.NewRequest(http.MethodPost, "/positions/eth/close", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Close status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var trade PaperTrade
+	if err := json.Unmarshal(rec.Body.Bytes(), &trade); err != nil {
+		t.Fatalf("Failed to decode trade: %v", err)
+	}
+	if trade.Action != "CLOSE" || trade.Side != "SELL" {
+		t.Errorf("Trade = %s %s, want CLOSE SELL", trade.Action, trade.Side)
+	}
+
+	if size := pt.Positions["ETH"].Size; size != 0 {
+		t.Errorf("Position size after close = %.4f, want 0", size)
+	}
+	// (3100 - 3000) * 2 = 200
+	if math.Abs(pt.TotalRealizedPnL-200.0) > 0.01 {
+		t.Errorf("Realized PnL = %.2f, want 200.00", pt.TotalRealizedPnL)
+	}
+
+	// Closing again finds nothing to close
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/positions/ETH/close", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Second close status = %d, want 404", rec.Code)
+	}
+
+	// Only POST closes
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/positions/ETH/close", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET close status = %d, want 405", rec.Code)
+	}
+}
+
+func TestHTTPAuthToken(t *testing.T) {
+	bot := newTestServerBot(t)
+	bot.config.Monitoring.HTTPAuthToken = "s3cret"
+
+	tests := []struct {
+		name         string
+		path         string
+		header       string
+		healthPublic bool
+		wantStatus   int
+	}{
+		{"no header", "/positions", "", false, http.StatusUnauthorized},
+		{"wrong token", "/positions", "Bearer nope", false, http.StatusUnauthorized},
+		{"wrong scheme", "/positions", "Basic s3cret", false, http.StatusUnauthorized},
+		{"valid token", "/positions", "Bearer s3cret", false, http.StatusOK},
+		{"health protected", "/health", "", false, http.StatusUnauthorized},
+		{"health public", "/health", "", true, http.StatusOK},
+		{"public health still guards positions", "/positions", "", true, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot.config.Monitoring.HTTPHealthPublic = tt.healthPublic
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			bot.newHTTPHandler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// The close endpoint is guarded too
+	rec := httptest.NewRecorder()
+	bot.newHTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/positions/ETH/close", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Unauthenticated close status = %d, want 401", rec.Code)
+	}
+}
+
+func TestHealthReportsLeverage(t *testing.T) {
+	bot := newTestServerBot(t)
+	bot.config.Leverage = 2.0
+	bot.paperTrader.Bankroll = 10000.0
+	bot.paperTrader.Positions["BTC"] = &Position{Coin: "BTC", Size: 0.35, AvgEntryPrice: 50000, LastPrice: 50000}
+
+	rec := httptest.NewRecorder()
+	bot.newHTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var health map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to decode health: %v", err)
+	}
+	if leverage, _ := health["leverage"].(float64); math.Abs(leverage-1.75) > 1e-9 {
+		t.Errorf("Health leverage = %v, want 1.75", health["leverage"])
+	}
+	if _, warned := health["warning"]; !warned {
+		t.Errorf("1.75x of a 2x cap should warn")
+	}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	var fail bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	bot := newTestServerBot(t)
+	bot.client.baseURL = server.URL
+	handler := bot.newHTTPHandler()
+	probe := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := probe("/livez"); code != http.StatusOK {
+		t.Errorf("/livez = %d, want 200", code)
+	}
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before a poll = %d, want 503", code)
+	}
+
+	if err := bot.checkForNewTrades(); err != nil {
+		t.Fatalf("checkForNewTrades() error = %v", err)
+	}
+	if code := probe("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz after a poll = %d, want 200", code)
+	}
+
+	// A failing poll takes it out of rotation again; liveness is unaffected
+	fail = true
+	bot.checkForNewTrades()
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz after a failed poll = %d, want 503", code)
+	}
+	if code := probe("/livez"); code != http.StatusOK {
+		t.Errorf("/livez after a failed poll = %d, want 200", code)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	bot := newTestServerBot(t)
+	bot.config.CopyThreshold = 100.0
+
+	// Target filled 3s before we processed
+	fill := &Fill{Coin: "BTC", Side: SideBuy, Size: 1.0, Price: 50000.0,
+		Hash: "metrics1", Time: time.Now().Add(-3 * time.Second).UnixMilli()}
+	bot.processQueued(fill)
+
+	rec := httptest.NewRecorder()
+	bot.newHTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Metrics status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE hype_copy_process_seconds histogram",
+		`hype_copy_process_seconds_bucket{le="0.0005"}`,
+		`hype_copy_process_seconds_bucket{le="1"} 1`,
+		`hype_copy_process_seconds_bucket{le="+Inf"} 1`,
+		"hype_copy_process_seconds_count 1",
+		`hype_copy_latency_seconds_bucket{le="2"} 0`,
+		`hype_copy_latency_seconds_bucket{le="5"} 1`,
+		"hype_copy_trades 1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	bot := newTestServerBot(t)
+	bot.config.Monitoring.HTTPAuthToken = "s3cret"
+	handler := bot.newHTTPHandler()
+	post := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("/pause", ""); code != http.StatusUnauthorized || bot.paused.Load() {
+		t.Fatalf("Unauthenticated /pause = %d, paused %v; want 401, not paused", code, bot.paused.Load())
+	}
+	if code := post("/pause", "s3cret"); code != http.StatusOK {
+		t.Fatalf("/pause = %d, want 200", code)
+	}
+
+	now := time.Now().Unix()
+	paused := []*Fill{
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", now),
+		createTestFill("ETH", "B", 2.0, 3000.0, "0.0", now+1),
+	}
+	for _, fill := range paused {
+		bot.process(fill)
+	}
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 0 {
+		t.Fatalf("Copied %d trades while paused, want 0", trades)
+	}
+
+	if code := post("/resume", "s3cret"); code != http.StatusOK || bot.paused.Load() {
+		t.Fatalf("/resume = %d, paused %v; want 200, resumed", code, bot.paused.Load())
+	}
+
+	// The paused fills come back on the next poll but stay uncopied
+	for _, fill := range paused {
+		bot.process(createTestFill(fill.Coin, string(fill.Side), fill.Size, fill.Price, "0.0", fill.Time/1000))
+	}
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 0 {
+		t.Errorf("Copied %d paused fills after resuming, want 0", trades)
+	}
+	fresh := createTestFill("SOL", "B", 100.0, 150.0, "0.0", now+2)
+	bot.process(fresh)
+	if trades := bot.paperTrader.GetTotalTrades(); trades != 1 {
+		t.Errorf("Trades after resuming = %d, want the new fill copied", trades)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+)
+
+// ShadowTracker compares real executions against the paper simulation they
+// follow. Each real order is expected to fill at the paper price; real fills
+// are matched to expectations per coin in order placed. Sizes are signed.
+type ShadowTracker struct {
+	mu       sync.Mutex
+	expected map[string][]shadowOrder
+	report   ShadowReport
+}
+
+type shadowOrder struct {
+	size  float64 // still unfilled, signed
+	price float64 // paper price the order followed
+}
+
+// ShadowReport is the divergence between simulated and real execution
+type ShadowReport struct {
+	Orders        int     // real orders placed
+	Rejects       int     // real orders the exchange refused
+	Fills         int     // real fills matched to an order
+	Unmatched     int     // real fills with no order to match
+	ExpectedSize  float64 // total size ordered
+	FilledSize    float64 // total size matched
+	slippageTotal float64 // size-weighted adverse slippage in bps
+	priceDiff     float64 // size-weighted |real - paper| price
+}
+
+func NewShadowTracker() *ShadowTracker {
+	return &ShadowTracker{expected: make(map[string][]shadowOrder)}
+}
+
+// Expect records a real order placed to follow a paper trade at price
+func (s *ShadowTracker) Expect(coin string, size, price float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expected[coin] = append(s.expected[coin], shadowOrder{size: size, price: price})
+	s.report.Orders++
+	s.report.ExpectedSize += math.Abs(size)
+}
+
+// Reject records a real order the exchange refused
+func (s *ShadowTracker) Reject(coin string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report.Rejects++
+}
+
+// OnFill matches a real fill against the oldest same-direction orders
+func (s *ShadowTracker) OnFill(coin string, size, price float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remaining := size
+	kept := s.expected[coin][:0]
+	for _, order := range s.expected[coin] {
+		if math.Abs(remaining) > 1e-12 && order.size*remaining > 0 {
+			matched := math.Copysign(math.Min(math.Abs(remaining), math.Abs(order.size)), remaining)
+
+			// Paying up on buys and giving up on sells are both adverse
+			slippage := (price - order.price) / order.price * 10000
+			if matched < 0 {
+				slippage = -slippage
+			}
+			s.report.slippageTotal += slippage * math.Abs(matched)
+			s.report.priceDiff += math.Abs(price-order.price) * math.Abs(matched)
+			s.report.FilledSize += math.Abs(matched)
+
+			order.size -= matched
+			remaining -= matched
+		}
+		if math.Abs(order.size) > 1e-12 {
+			kept = append(kept, order)
+		}
+	}
+	s.expected[coin] = kept
+
+	if math.Abs(remaining-size) < 1e-12 {
+		s.report.Unmatched++
+		return
+	}
+	s.report.Fills++
+}
+
+// Report returns the divergence so far
+func (s *ShadowTracker) Report() ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report
+}
+
+// FillRatio is the share of ordered size that really filled
+func (r ShadowReport) FillRatio() float64 {
+	if r.ExpectedSize == 0 {
+		return 0
+	}
+	return r.FilledSize / r.ExpectedSize
+}
+
+// SlippageBps is the size-weighted average adverse slippage against the
+// paper price, in basis points
+func (r ShadowReport) SlippageBps() float64 {
+	if r.FilledSize == 0 {
+		return 0
+	}
+	return r.slippageTotal / r.FilledSize
+}
+
+// AvgPriceDiff is the size-weighted average |real - paper| fill price
+func (r ShadowReport) AvgPriceDiff() float64 {
+	if r.FilledSize == 0 {
+		return 0
+	}
+	return r.priceDiff / r.FilledSize
+}
+
+func (r ShadowReport) String() string {
+	parts := []string{
+		fmt.Sprintf("%d orders", r.Orders),
+		fmt.Sprintf("%d rejected", r.Rejects),
+		fmt.Sprintf("%.1f%% filled", r.FillRatio()*100),
+		fmt.Sprintf("slippage %.1f bps", r.SlippageBps()),
+		fmt.Sprintf("avg price diff $%.4f", r.AvgPriceDiff()),
+	}
+	if r.Unmatched > 0 {
+		parts = append(parts, fmt.Sprintf("%d unmatched fills", r.Unmatched))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// recordRealFill applies one of our own exchange fills to the order
+// tracker and the shadow comparison
+func (b *Bot) recordRealFill(fill *Fill) {
+	size := fill.Size
+	if !fill.Side.IsBuy() {
+		size = -size
+	}
+	if b.orders != nil {
+		b.orders.OnFill(fill.Coin, size)
+	}
+	b.shadow.OnFill(fill.Coin, size, fill.Price)
+}
+
+// checkOwnFills polls our own account's fills since the last poll
+func (b *Bot) checkOwnFills() error {
+	endTime := b.clock.Now().UnixMilli()
+	if b.ownFillsSince == 0 {
+		// Fills from before we started have no paper trade to compare to
+		b.ownFillsSince = endTime
+		return nil
+	}
+
+	fills, err := b.api().GetUserFillsByTime(b.config.AccountAddress, b.ownFillsSince, endTime)
+	if err != nil {
+		return err
+	}
+	for _, fill := range fills {
+		if err := fill.Normalize(); err != nil {
+			log.Printf("Skipping invalid own fill %s: %v", fill.Hash, err)
+			continue
+		}
+		b.recordRealFill(fill)
+		if fill.Time >= b.ownFillsSince {
+			b.ownFillsSince = fill.Time + 1
+		}
+	}
+	return nil
+}
+
+// printSummary prints the portfolio summary, fills that just missed the
+// copy threshold and, in real mode, how far the real account diverged. In
+// observe mode it prints the target's own performance instead.
+func (b *Bot) printSummary() {
+	if b.observer != nil {
+		b.observer.PrintSummary()
+		return
+	}
+	b.paperTrader.PrintPortfolioSummary()
+	if count, notional := b.nearMissStats(); count > 0 {
+		fmt.Printf("🎯 Near misses: %d fills, $%.2f within %.0f%% of the copy threshold\n",
+			count, notional, nearMissBand*100)
+	}
+	if b.shadow != nil {
+		fmt.Printf("🪞 Shadow vs real: %s\n", b.shadow.Report())
+	}
+}
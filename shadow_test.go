@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShadowDivergence(t *testing.T) {
+	var reject bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reject {
+			http.Error(w, "insufficient margin", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.PaperTradingOnly = false
+	config.CopyThreshold = 100.0
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.client.assets = testAssets
+	bot.paperTrader = NewTestPaperTrader()
+
+	now := time.Now().UnixMilli()
+	bot.process(&Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: 50000.0, Hash: "shadow1", Time: now})
+
+	// Only 0.8 of the 1.0 ordered fills, 25 above the paper price
+	bot.recordRealFill(&Fill{Coin: "BTC", Side: SideBuy, Size: 0.8, Price: 50025.0})
+
+	// On the way out, 1.0 ordered at 51000 fills in full at 50949
+	bot.process(&Fill{Coin: "BTC", Side: "A", Size: 1.0, Price: 51000.0, Hash: "shadow2", Time: now + 1})
+	reject = true
+	bot.process(&Fill{Coin: "ETH", Side: "B", Size: 1.0, Price: 3000.0, Hash: "shadow3", Time: now + 2})
+
+	report := bot.shadow.Report()
+	if report.Orders != 2 || report.Rejects != 1 {
+		t.Errorf("Orders = %d, rejects = %d; want 2, 1", report.Orders, report.Rejects)
+	}
+
+	// Paper is flat; 0.8 filled and 0.2 still working nets to a 1.0 sell
+	bot.recordRealFill(&Fill{Coin: "BTC", Side: SideSell, Size: 1.0, Price: 50949.0})
+
+	report = bot.shadow.Report()
+	if ratio := report.FillRatio(); math.Abs(ratio-0.9) > 1e-9 {
+		t.Errorf("Fill ratio = %.4f, want 0.9000", ratio)
+	}
+
+	// Buy: +25/50000 = 5 bps adverse. Sell: 51/51000 = 10 bps adverse.
+	if slippage := report.SlippageBps(); math.Abs(slippage-(5.0*0.8+10.0)/1.8) > 1e-6 {
+		t.Errorf("Slippage = %.4f bps, want %.4f", slippage, (5.0*0.8+10.0)/1.8)
+	}
+	if diff := report.AvgPriceDiff(); math.Abs(diff-(25.0*0.8+51.0)/1.8) > 1e-6 {
+		t.Errorf("Average price diff = %.4f, want %.4f", diff, (25.0*0.8+51.0)/1.8)
+	}
+
+	// A fill with no order behind it is flagged, not matched
+	bot.recordRealFill(&Fill{Coin: "SOL", Side: SideBuy, Size: 5.0, Price: 200.0})
+	report = bot.shadow.Report()
+	if report.Fills != 2 || report.Unmatched != 1 {
+		t.Errorf("Fills = %d, unmatched = %d; want 2, 1", report.Fills, report.Unmatched)
+	}
+	if !strings.Contains(report.String(), "1 rejected") {
+		t.Errorf("Report %q should mention the reject", report.String())
+	}
+}
@@ -3,8 +3,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,26 +37,145 @@ func getDataDir() string {
 	return filepath.Join(prefix, dataDir)
 }
 
+// dataDir is where the trader's records go: the data directory, or its own
+// directory under subaccounts when copying into a sub-account
+func (pt *PaperTrader) dataDir() string {
+	if pt.SubAccount == "" {
+		return getDataDir()
+	}
+	return filepath.Join(getDataDir(), "subaccounts", pt.SubAccount)
+}
+
 // SaveFill appends a fill record to daily fills file
 func (pt *PaperTrader) SaveFill(fill *Fill, action string, realizedPnL, unrealizedPnL float64) {
+	pt.saveFillRecord(pt.fillRecord(fill, action, realizedPnL, unrealizedPnL))
+}
+
+// fillRecord builds the fills file record for fill
+func (pt *PaperTrader) fillRecord(fill *Fill, action string, realizedPnL, unrealizedPnL float64) *FillRecord {
+	return &FillRecord{
+		SchemaVersion: fillSchemaVersion,
+		Time:          pt.now().UnixMilli(),
+		Coin:          fill.Coin,
+		Side:          fill.Side,
+		Size:          fill.Size,
+		Price:         fill.Price,
+		Action:        action,
+		RealizedPnL:   realizedPnL,
+		UnrealizedPnL: unrealizedPnL,
+		VolumeUSD:     fill.Size * fill.Price,
+		Hash:          fill.Hash,
+	}
+}
+
+func (pt *PaperTrader) saveFillRecord(record *FillRecord) {
 	// Skip storage during tests
 	if pt.VolumeThreshold == 0.0 {
 		return
 	}
-	record := map[string]interface{}{
-		"time":           time.Now().UnixMilli(),
-		"coin":           fill.Coin,
-		"side":           fill.Side,
-		"size":           fill.Size,
-		"price":          fill.Price,
-		"action":         action,
-		"realized_pnl":   realizedPnL,
-		"unrealized_pnl": unrealizedPnL,
-		"volume_usd":     fill.Size * fill.Price,
+	filename := fmt.Sprintf("%s/fills/%s.jl", pt.dataDir(), pt.now().Format("20060102"))
+	appendJSON(filename, record, pt.storageDecimals())
+}
+
+// fillSchemaVersion is the current fills file record layout. Bump it
+// whenever record fields change so consumers can adapt:
+//
+//	1: original records, no schema_version field
+//	2: schema_version, hash
+//	3: trade_size, trade_price on the last record of each booked trade
+const fillSchemaVersion = 3
+
+// FillRecord is one record of the daily fills file
+type FillRecord struct {
+	SchemaVersion int     `json:"schema_version"`
+	Time          int64   `json:"time"`
+	Coin          string  `json:"coin"`
+	Side          Side    `json:"side"`
+	Size          float64 `json:"size"`
+	Price         float64 `json:"price"`
+	Action        string  `json:"action"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	VolumeUSD     float64 `json:"volume_usd"`
+	Hash          string  `json:"hash,omitempty"`
+
+	// The copy trade booked from this and any preceding unbooked records,
+	// signed; zero on records that didn't complete a trade
+	TradeSize  float64 `json:"trade_size,omitempty"`
+	TradePrice float64 `json:"trade_price,omitempty"`
+}
+
+// Fill converts a saved record back into a target fill
+func (r *FillRecord) Fill() *Fill {
+	return &Fill{
+		Coin:  r.Coin,
+		Side:  r.Side,
+		Size:  r.Size,
+		Price: r.Price,
+		Time:  r.Time,
+		Hash:  r.Hash,
 	}
+}
+
+// RebuildFromFills reconstructs positions and PnL from saved fill records,
+// e.g. after losing the accounts file. Records from schema 3 on carry the
+// booked copy trade and are applied as live processing booked them, up to
+// the rounding of saved numbers; a CLOSE flattens whatever that leaves.
+// Older records only hold the target fill and are replayed through
+// ProcessFill, which matches live sizing only as far as the trader's
+// settings and marks do; those that fail Normalize are skipped.
+func (pt *PaperTrader) RebuildFromFills(records []FillRecord) {
+	for i := range records {
+		record := &records[i]
+		if record.SchemaVersion < 3 {
+			if fill := record.Fill(); fill.Normalize() == nil {
+				pt.ProcessFill(fill)
+			}
+			continue
+		}
+		if record.TradeSize == 0 {
+			continue
+		}
 
-	filename := fmt.Sprintf("%s/fills/%s.jl", getDataDir(), time.Now().Format("20060102"))
-	appendJSON(filename, record)
+		pt.mu.Lock()
+		position := pt.getPosition(record.Coin)
+		tradeSize := record.TradeSize
+		if record.Action == ActionClose.String() {
+			tradeSize = -position.Size
+		}
+		pt.updatePosition(position, tradeSize, record.TradePrice, record.RealizedPnL)
+		position.LastPrice = record.Price
+		pt.TotalTrades++
+		pt.addRealized(record.Action, record.RealizedPnL)
+		pt.LastTradeTime[record.Coin] = time.UnixMilli(record.Time)
+		pt.mu.Unlock()
+	}
+}
+
+// LoadFills reads all records from a fills file, skipping malformed lines.
+// Records without a schema_version are version 1.
+func LoadFills(filename string) ([]FillRecord, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []FillRecord
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var record FillRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			continue
+		}
+		if record.SchemaVersion == 0 {
+			record.SchemaVersion = 1
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
 }
 
 // SaveAccount appends current account state to daily accounts file
@@ -62,10 +186,53 @@ func (pt *PaperTrader) SaveAccount() {
 	}
 	// Note: Caller must already hold pt.mu.Lock()
 
-	positions := make(map[string]map[string]float64)
+	filename := accountsFile(pt.dataDir(), pt.now())
+	appendJSON(filename, pt.snapshotRecord(filename, pt.accountSnapshot()), pt.storageDecimals())
+}
+
+// snapshotRecord returns the record to write for snapshot: the snapshot
+// itself, or in diff mode only the positions changed since the last record.
+// Each file starts with a full snapshot so it can be read on its own.
+func (pt *PaperTrader) snapshotRecord(filename string, snapshot *AccountSnapshot) *AccountSnapshot {
+	last := pt.lastSnapshot
+	pt.lastSnapshot = snapshot.Positions
+
+	full := pt.FullSnapshotEvery <= 0 || filename != pt.snapshotFile ||
+		pt.diffsSinceFull >= pt.FullSnapshotEvery-1
+	pt.snapshotFile = filename
+	if full {
+		pt.diffsSinceFull = 0
+		return snapshot
+	}
+	pt.diffsSinceFull++
+
+	diff := *snapshot
+	diff.Kind = snapshotDiff
+	diff.Positions = make(map[string]AccountPosition)
+	for coin, pos := range snapshot.Positions {
+		if prev, exists := last[coin]; !exists || !reflect.DeepEqual(prev, pos) {
+			diff.Positions[coin] = pos
+		}
+	}
+	for coin := range last {
+		if _, exists := snapshot.Positions[coin]; !exists {
+			diff.Removed = append(diff.Removed, coin)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return &diff
+}
+
+// accountSnapshot captures current account state. Caller must hold pt.mu.
+func (pt *PaperTrader) accountSnapshot() *AccountSnapshot {
+	positions := make(map[string]AccountPosition)
 	totalUnrealized := 0.0
 
+	coinRealized := make(map[string]float64)
 	for coin, pos := range pt.Positions {
+		if pos.TradeCount > 0 {
+			coinRealized[coin] = pos.RealizedPnL
+		}
 		if pos.Size == 0 {
 			continue // Skip flat positions
 		}
@@ -73,30 +240,182 @@ func (pt *PaperTrader) SaveAccount() {
 		unrealized := pt.calculateUnrealizedPnL(pos)
 		totalUnrealized += unrealized
 
-		positions[coin] = map[string]float64{
-			"size":       pos.Size,
-			"avg_price":  pos.AvgEntryPrice,
-			"last_price": pos.LastPrice,
-			"realized":   pos.RealizedPnL,
-			"unrealized": unrealized,
-			"market_val": pos.Size * pos.LastPrice,
+		positions[coin] = AccountPosition{
+			Size:       pos.Size,
+			AvgPrice:   pos.AvgEntryPrice,
+			LastPrice:  pos.LastPrice,
+			Realized:   pos.RealizedPnL,
+			Unrealized: unrealized,
+			MarketVal:  pos.Size * pos.LastPrice,
+			Tags:       pos.Tags,
+		}
+	}
+
+	return &AccountSnapshot{
+		Time:          pt.now().UnixMilli(),
+		TotalPnL:      pt.TotalRealizedPnL + totalUnrealized,
+		RealizedPnL:   pt.TotalRealizedPnL,
+		GrossRealized: pt.TotalRealizedPnL,
+		TotalFees:     pt.TotalFees,
+		TotalFunding:  pt.TotalFunding,
+		NetRealized:   pt.netRealizedPnL(),
+		Positions:     positions,
+		CoinRealized:  coinRealized,
+		Evicted:       pt.EvictedRealized,
+		NumTrades:     pt.TotalTrades,
+		Bankroll:      pt.Bankroll,
+		EquityPeak:    pt.EquityPeak,
+	}
+}
+
+// snapshotDiff marks an accounts record holding only changed positions
+const snapshotDiff = "diff"
+
+// AccountSnapshot is one record of the daily accounts file. Diff records
+// carry full totals but only changed positions, plus coins gone flat.
+type AccountSnapshot struct {
+	Kind          string                     `json:"kind,omitempty"`
+	Time          int64                      `json:"time"`
+	TotalPnL      float64                    `json:"total_pnl"`
+	RealizedPnL   float64                    `json:"realized_pnl"`
+	GrossRealized float64                    `json:"gross_realized"`
+	TotalFees     float64                    `json:"total_fees"`
+	TotalFunding  float64                    `json:"total_funding"`
+	NetRealized   float64                    `json:"net_realized"`
+	Positions     map[string]AccountPosition `json:"positions"`
+	CoinRealized  map[string]float64         `json:"coin_realized,omitempty"`    // every traded coin, flat or not
+	Evicted       float64                    `json:"evicted_realized,omitempty"` // coins long flat, no longer in coin_realized
+	NumTrades     int                        `json:"num_trades"`
+	Bankroll      float64                    `json:"bankroll"` // after deposits and withdrawals
+	EquityPeak    float64                    `json:"equity_peak,omitempty"`
+	Removed       []string                   `json:"removed,omitempty"`
+}
+
+// AccountPosition is one open position within an account snapshot
+type AccountPosition struct {
+	Size       float64           `json:"size"`
+	AvgPrice   float64           `json:"avg_price"`
+	LastPrice  float64           `json:"last_price"`
+	Realized   float64           `json:"realized"`
+	Unrealized float64           `json:"unrealized"`
+	MarketVal  float64           `json:"market_val"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// accountsFile returns the daily accounts file in dir for the given day
+func accountsFile(dir string, day time.Time) string {
+	return fmt.Sprintf("%s/accounts/%s.jl", dir, day.Format("20060102"))
+}
+
+// latestAccountsFile returns the newest daily accounts file in dir, or ""
+// when none has been written
+func latestAccountsFile(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "accounts", "*.jl"))
+	if len(files) == 0 {
+		return ""
+	}
+	sort.Strings(files)
+	return files[len(files)-1]
+}
+
+// Checkpoint saves the account state now rather than at the next trade
+func (pt *PaperTrader) Checkpoint() {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.SaveAccount()
+}
+
+// RestoreEquityPeak raises the equity peak to the one in the latest saved
+// account state, so drawdown carries on from before a restart. It returns
+// the restored peak, 0 when nothing was saved.
+func (pt *PaperTrader) RestoreEquityPeak() (float64, error) {
+	filename := latestAccountsFile(pt.dataDir())
+	if filename == "" {
+		return 0, nil
+	}
+	state, err := LoadLastAccount(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.EquityPeak = math.Max(pt.EquityPeak, state.EquityPeak)
+	return state.EquityPeak, nil
+}
+
+// LoadLastAccount returns the latest account state in an accounts file,
+// applying diff records on top of the preceding full snapshot.
+// Snapshots written before fees and funding were tracked have no net
+// fields; for those gross and net realized both equal realized_pnl.
+func LoadLastAccount(filename string) (*AccountSnapshot, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var state *AccountSnapshot
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		snapshot, err := parseAccountSnapshot(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if snapshot.Kind != snapshotDiff {
+			state = snapshot
+			continue
+		}
+		if state == nil {
+			return nil, fmt.Errorf("account diff without a full snapshot in %s", filename)
 		}
+		positions := state.Positions
+		for coin, pos := range snapshot.Positions {
+			positions[coin] = pos
+		}
+		for _, coin := range snapshot.Removed {
+			delete(positions, coin)
+		}
+		state = snapshot
+		state.Kind = ""
+		state.Positions = positions
+		state.Removed = nil
+	}
+
+	if state == nil {
+		return nil, fmt.Errorf("no account snapshots in %s", filename)
+	}
+	return state, nil
+}
+
+func parseAccountSnapshot(line string) (*AccountSnapshot, error) {
+	var snapshot AccountSnapshot
+	if err := json.Unmarshal([]byte(line), &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse account snapshot: %v", err)
 	}
 
-	record := map[string]interface{}{
-		"time":         time.Now().UnixMilli(),
-		"total_pnl":    pt.TotalRealizedPnL + totalUnrealized,
-		"realized_pnl": pt.TotalRealizedPnL,
-		"positions":    positions,
-		"num_trades":   pt.TotalTrades,
+	var fields map[string]json.RawMessage
+	json.Unmarshal([]byte(line), &fields)
+	if _, ok := fields["gross_realized"]; !ok {
+		snapshot.GrossRealized = snapshot.RealizedPnL
+	}
+	if _, ok := fields["net_realized"]; !ok {
+		snapshot.NetRealized = snapshot.GrossRealized - snapshot.TotalFees + snapshot.TotalFunding
+	}
+	if snapshot.Positions == nil {
+		snapshot.Positions = make(map[string]AccountPosition)
 	}
 
-	filename := fmt.Sprintf("%s/accounts/%s.jl", getDataDir(), time.Now().Format("20060102"))
-	appendJSON(filename, record)
+	return &snapshot, nil
 }
 
-// appendJSON appends a JSON record to a file (creates dirs if needed)
-func appendJSON(filename string, data interface{}) {
+// appendJSON appends data as one JSON line to a file, floats rounded to
+// decimals (creates dirs if needed)
+func appendJSON(filename string, data interface{}, decimals int) {
 	// Create directory if needed
 	dir := filepath.Dir(filename)
 	os.MkdirAll(dir, 0755)
@@ -106,6 +425,7 @@ func appendJSON(filename string, data interface{}) {
 	if err != nil {
 		return
 	}
+	jsonBytes = roundJSONFloats(jsonBytes, decimals)
 
 	// Append to file
 	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
@@ -116,3 +436,63 @@ func appendJSON(filename string, data interface{}) {
 
 	file.WriteString(string(jsonBytes) + "\n")
 }
+
+// defaultStorageDecimals is the precision of saved floats when unconfigured
+const defaultStorageDecimals = 8
+
+func (pt *PaperTrader) storageDecimals() int {
+	if pt.StorageDecimals <= 0 {
+		return defaultStorageDecimals
+	}
+	return pt.StorageDecimals
+}
+
+// roundJSONFloats rewrites the fractional numbers in encoded JSON to at
+// most decimals places, leaving strings, integers and key order untouched
+func roundJSONFloats(data []byte, decimals int) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			out = append(out, c)
+			continue
+		}
+		if c != '-' && (c < '0' || c > '9') {
+			out = append(out, c)
+			continue
+		}
+
+		end := i
+		for end < len(data) && strings.IndexByte("0123456789+-.eE", data[end]) >= 0 {
+			end++
+		}
+		number := string(data[i:end])
+		i = end - 1
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil || !strings.ContainsAny(number, ".eE") {
+			out = append(out, number...)
+			continue
+		}
+		rounded := strconv.FormatFloat(v, 'f', decimals, 64)
+		if strings.Contains(rounded, ".") {
+			rounded = strings.TrimRight(strings.TrimRight(rounded, "0"), ".")
+		}
+		if rounded == "-0" {
+			rounded = "0"
+		}
+		out = append(out, rounded...)
+	}
+	return out
+}
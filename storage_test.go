@@ -0,0 +1,433 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountSnapshotNetFigures(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	pt := NewPaperTrader(10000.0, 1.0, 1000.0)
+	pt.TotalRealizedPnL = 500.0
+	pt.TotalFees = 35.0
+	pt.TotalFunding = -15.0
+	pt.TotalTrades = 4
+
+	pt.mu.Lock()
+	pt.SaveAccount()
+	pt.mu.Unlock()
+
+	snapshot, err := LoadLastAccount(accountsFile(getDataDir(), time.Now()))
+	if err != nil {
+		t.Fatalf("LoadLastAccount() error = %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"gross_realized", snapshot.GrossRealized, 500.0},
+		{"total_fees", snapshot.TotalFees, 35.0},
+		{"total_funding", snapshot.TotalFunding, -15.0},
+		{"net_realized", snapshot.NetRealized, 450.0},
+		{"realized_pnl", snapshot.RealizedPnL, 500.0},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %.2f, want %.2f", c.name, c.got, c.want)
+		}
+	}
+	if snapshot.NumTrades != 4 {
+		t.Errorf("num_trades = %d, want 4", snapshot.NumTrades)
+	}
+}
+
+func TestLoadLegacyAccountSnapshot(t *testing.T) {
+	// Snapshot format from before fees and funding were recorded
+	legacy := `{"num_trades":2,"positions":{},"realized_pnl":120.5,"time":1758113800549,"total_pnl":120.5}`
+	filename := filepath.Join(t.TempDir(), "20250917.jl")
+	if err := os.WriteFile(filename, []byte(legacy+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	snapshot, err := LoadLastAccount(filename)
+	if err != nil {
+		t.Fatalf("LoadLastAccount() error = %v", err)
+	}
+	if snapshot.GrossRealized != 120.5 || snapshot.NetRealized != 120.5 {
+		t.Errorf("Legacy snapshot gross/net = %.2f/%.2f, want 120.50/120.50",
+			snapshot.GrossRealized, snapshot.NetRealized)
+	}
+	if snapshot.TotalFees != 0 || snapshot.TotalFunding != 0 {
+		t.Errorf("Legacy snapshot fees/funding = %.2f/%.2f, want 0/0",
+			snapshot.TotalFees, snapshot.TotalFunding)
+	}
+}
+
+func TestPositionTagsInSnapshot(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	config := createTestConfig()
+	config.TargetAccount = "0xb8b9e3097c8b1dddf9c5ea9d48a7ebeaf09d67d2"
+	bot, err := NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	fill := &Fill{
+		Coin: "ETH", Side: "B", Size: 1.0, Price: 4000.0,
+		ClosedPnl: "0.0", Hash: "tagged", Time: time.Now().UnixMilli(),
+	}
+	if err := bot.process(fill); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+
+	pos := bot.paperTrader.Positions["ETH"]
+	if pos == nil || pos.Tags["target"] != config.TargetAccount {
+		t.Fatalf("Position tags = %v, want target %s", pos, config.TargetAccount)
+	}
+
+	snapshot, err := LoadLastAccount(accountsFile(getDataDir(), time.Now()))
+	if err != nil {
+		t.Fatalf("LoadLastAccount() error = %v", err)
+	}
+	tags := snapshot.Positions["ETH"].Tags
+	if tags["target"] != config.TargetAccount || tags["mode"] != "paper" {
+		t.Errorf("Snapshot tags = %v, want target %s mode paper", tags, config.TargetAccount)
+	}
+}
+
+func TestAccountSnapshotDiffs(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	pt := NewPaperTrader(10000.0, 1.0, 1000.0)
+	pt.Clock = NewReplayClock(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	pt.FullSnapshotEvery = 3
+
+	save := func() {
+		pt.mu.Lock()
+		pt.SaveAccount()
+		pt.mu.Unlock()
+	}
+
+	pt.Positions["BTC"] = &Position{Coin: "BTC", Size: 1.0, AvgEntryPrice: 50000, LastPrice: 50000}
+	pt.Positions["ETH"] = &Position{Coin: "ETH", Size: 2.0, AvgEntryPrice: 3000, LastPrice: 3000}
+	save() // full
+	pt.Positions["BTC"].LastPrice = 51000
+	save() // diff: BTC changed
+	pt.Positions["ETH"].Size = 0
+	pt.TotalRealizedPnL = 120
+	save() // diff: ETH removed
+	pt.Positions["BTC"].LastPrice = 52000
+	save() // full
+	pt.Positions["SOL"] = &Position{Coin: "SOL", Size: -10, AvgEntryPrice: 150, LastPrice: 149}
+	pt.TotalTrades = 3
+	save() // diff: SOL added
+
+	filename := accountsFile(getDataDir(), pt.now())
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read accounts file: %v", err)
+	}
+	var records []*AccountSnapshot
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		record, err := parseAccountSnapshot(line)
+		if err != nil {
+			t.Fatalf("parseAccountSnapshot() error = %v", err)
+		}
+		records = append(records, record)
+	}
+
+	wantKinds := []string{"", snapshotDiff, snapshotDiff, "", snapshotDiff}
+	if len(records) != len(wantKinds) {
+		t.Fatalf("Records = %d, want %d", len(records), len(wantKinds))
+	}
+	for i, want := range wantKinds {
+		if records[i].Kind != want {
+			t.Errorf("Record %d kind = %q, want %q", i, records[i].Kind, want)
+		}
+	}
+	if _, ok := records[1].Positions["BTC"]; !ok || len(records[1].Positions) != 1 {
+		t.Errorf("First diff should hold only BTC, got %v", records[1].Positions)
+	}
+	if len(records[2].Positions) != 0 || len(records[2].Removed) != 1 || records[2].Removed[0] != "ETH" {
+		t.Errorf("Second diff should only remove ETH, got %v removed %v",
+			records[2].Positions, records[2].Removed)
+	}
+
+	// Full snapshot plus diffs rebuilds the final state
+	state, err := LoadLastAccount(filename)
+	if err != nil {
+		t.Fatalf("LoadLastAccount() error = %v", err)
+	}
+	pt.mu.Lock()
+	want := pt.accountSnapshot()
+	pt.mu.Unlock()
+	if !reflect.DeepEqual(state.Positions, want.Positions) {
+		t.Errorf("Rebuilt positions = %v, want %v", state.Positions, want.Positions)
+	}
+	if state.RealizedPnL != 120 || state.NumTrades != 3 {
+		t.Errorf("Rebuilt totals = realized %.2f trades %d, want 120 and 3",
+			state.RealizedPnL, state.NumTrades)
+	}
+}
+
+func TestFillRecordSchemaVersion(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	pt := NewPaperTrader(10000.0, 1.0, 1000.0)
+	pt.SaveFill(&Fill{Coin: "BTC", Side: SideBuy, Size: 0.1, Price: 50000, Hash: "0xabc"}, "OPEN", 0, 0)
+
+	filename := filepath.Join(getDataDir(), "fills", time.Now().Format("20060102")+".jl")
+	records, err := LoadFills(filename)
+	if err != nil {
+		t.Fatalf("LoadFills() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Records = %d, want 1", len(records))
+	}
+	if records[0].SchemaVersion != fillSchemaVersion {
+		t.Errorf("New record schema_version = %d, want %d", records[0].SchemaVersion, fillSchemaVersion)
+	}
+	if records[0].Hash != "0xabc" {
+		t.Errorf("New record hash = %q, want 0xabc", records[0].Hash)
+	}
+
+	// Version 1 records predate schema_version
+	legacy := filepath.Join(t.TempDir(), "legacy.jl")
+	v1 := `{"time":1700000000000,"coin":"ETH","side":"A","size":2,"price":3000,"action":"OPEN",` +
+		`"realized_pnl":0,"unrealized_pnl":0,"volume_usd":6000}` + "\n"
+	if err := os.WriteFile(legacy, []byte(v1), 0644); err != nil {
+		t.Fatalf("Failed to write legacy fills: %v", err)
+	}
+	records, err = LoadFills(legacy)
+	if err != nil {
+		t.Fatalf("LoadFills() error = %v", err)
+	}
+	if len(records) != 1 || records[0].SchemaVersion != 1 {
+		t.Fatalf("Legacy records = %+v, want one version 1 record", records)
+	}
+	if records[0].Coin != "ETH" || records[0].Side != SideSell || records[0].Size != 2 {
+		t.Errorf("Legacy record decoded as %+v", records[0])
+	}
+}
+
+func TestRebuildFromFills(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+	clock := NewReplayClock(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	live := NewTestPaperTrader()
+	live.Clock = clock
+	live.VolumeThreshold = 60000.0
+
+	start := clock.Now().Unix()
+	for i, fill := range []*Fill{
+		createTestFill("BTC", "B", 1.0, 50000.0, "0.0", start),      // pending
+		createTestFill("BTC", "B", 0.5, 50200.0, "0.0", start+1),    // books both
+		createTestFill("ETH", "A", 20.0, 3000.0, "0.0", start+2),    // short
+		createTestFill("BTC", "A", 1.0, 51000.0, "950.0", start+3),  // reduce
+		createTestFill("ETH", "B", 40.0, 2900.0, "2000.0", start+4), // reverse
+	} {
+		fill.Hash = fmt.Sprintf("0x%d", i)
+		live.ProcessFill(fill)
+	}
+	if _, err := live.ClosePosition("BTC"); err != nil {
+		t.Fatalf("ClosePosition() error = %v", err)
+	}
+
+	filename := filepath.Join(getDataDir(), "fills", clock.Now().Format("20060102")+".jl")
+	records, err := LoadFills(filename)
+	if err != nil {
+		t.Fatalf("LoadFills() error = %v", err)
+	}
+
+	rebuilt := NewTestPaperTrader()
+	rebuilt.Clock = clock
+	rebuilt.RebuildFromFills(records)
+
+	// Saved numbers are rounded to 8 decimals
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	if rebuilt.TotalTrades != live.TotalTrades || !near(rebuilt.TotalRealizedPnL, live.TotalRealizedPnL) {
+		t.Errorf("Rebuilt totals = %d trades, realized %.2f; want %d, %.2f",
+			rebuilt.TotalTrades, rebuilt.TotalRealizedPnL, live.TotalTrades, live.TotalRealizedPnL)
+	}
+	if len(rebuilt.Positions) != len(live.Positions) {
+		t.Fatalf("Rebuilt %d positions, want %d", len(rebuilt.Positions), len(live.Positions))
+	}
+	for coin, want := range live.Positions {
+		got := rebuilt.Positions[coin]
+		if got == nil {
+			t.Errorf("Rebuilt positions missing %s", coin)
+			continue
+		}
+		if got.Size != want.Size || !near(got.AvgEntryPrice, want.AvgEntryPrice) ||
+			!near(got.RealizedPnL, want.RealizedPnL) || !near(got.LastPrice, want.LastPrice) {
+			t.Errorf("Rebuilt %s = %.4f @ %.2f realized %.2f last %.2f; want %.4f @ %.2f realized %.2f last %.2f",
+				coin, got.Size, got.AvgEntryPrice, got.RealizedPnL, got.LastPrice,
+				want.Size, want.AvgEntryPrice, want.RealizedPnL, want.LastPrice)
+		}
+	}
+	if eth := live.Positions["ETH"]; eth == nil || eth.Size <= 0 {
+		t.Fatalf("Live ETH should have reversed long, got %+v", eth)
+	}
+}
+
+func TestRebuildNormalizesLegacyRecords(t *testing.T) {
+	pt := NewTestPaperTrader()
+	pt.RebuildFromFills([]FillRecord{
+		{SchemaVersion: 2, Time: 1700000000000, Coin: "eth", Side: "buy", Size: 2.0, Price: 3000.0, Hash: "0xlegacy1"},
+		{SchemaVersion: 2, Time: 1700000001000, Coin: "ETH", Side: "B", Size: 0, Price: 3000.0, Hash: "0xlegacy2"},
+		{SchemaVersion: 2, Time: 1700000002000, Coin: "ETH", Side: "X", Size: 1.0, Price: 3000.0, Hash: "0xlegacy3"},
+	})
+
+	// The alias record books as ETH; the zero-size and bad-side ones are skipped
+	if pos := pt.Positions["ETH"]; pos == nil || pos.Size != 2.0 {
+		t.Errorf("Rebuilt ETH = %+v, want 2.0", pos)
+	}
+	if len(pt.Positions) != 1 || pt.GetTotalTrades() != 1 {
+		t.Errorf("Rebuilt %d positions, %d trades; want 1, 1", len(pt.Positions), pt.GetTotalTrades())
+	}
+}
+
+func TestSavedFloatsAreRounded(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	pt := NewPaperTrader(10000.0, 1.0, 1000.0)
+	pt.StorageDecimals = 4
+	pt.mu.Lock()
+	pt.SaveFill(&Fill{Coin: "ETH", Side: SideBuy, Size: 0.123456789, Price: 3000.123456789,
+		Time: 1700000000000, Hash: "0xround"}, "OPEN", 0, 0)
+	pt.mu.Unlock()
+
+	records, err := LoadFills(filepath.Join(getDataDir(), "fills", pt.now().Format("20060102")+".jl"))
+	if err != nil {
+		t.Fatalf("LoadFills() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Saved %d records, want 1", len(records))
+	}
+	if records[0].Price != 3000.1235 || records[0].Size != 0.1235 {
+		t.Errorf("Saved price %v, size %v; want 3000.1235, 0.1235", records[0].Price, records[0].Size)
+	}
+
+	// Strings and integers are left alone
+	raw := `{"a":"1.23456","b":-0.00001,"c":12,"d":1.5e-12}`
+	if got := string(roundJSONFloats([]byte(raw), 4)); got != `{"a":"1.23456","b":0,"c":12,"d":0}` {
+		t.Errorf("roundJSONFloats = %s", got)
+	}
+}
+
+func TestEquityPeakSurvivesRestart(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	open := func() *PaperTrader {
+		pt := NewPaperTrader(10000.0, 1.0, 1000.0)
+		pt.Positions["ETH"] = &Position{Coin: "ETH", Size: 1.0, AvgEntryPrice: 3000.0, LastPrice: 3000.0}
+		return pt
+	}
+
+	// ETH rallies to a $10,500 equity peak, saved at shutdown
+	before := open()
+	before.UpdateMarks(map[string]float64{"ETH": 3500.0})
+	if before.EquityPeak != 10500.0 {
+		t.Fatalf("EquityPeak = %.2f, want 10500.00", before.EquityPeak)
+	}
+	before.Checkpoint()
+
+	// After a restart ETH falls back; drawdown is from the old peak
+	after := open()
+	peak, err := after.RestoreEquityPeak()
+	if err != nil {
+		t.Fatalf("RestoreEquityPeak() error = %v", err)
+	}
+	if peak != 10500.0 {
+		t.Errorf("Restored peak = %.2f, want 10500.00", peak)
+	}
+	after.UpdateMarks(map[string]float64{"ETH": 3200.0})
+	if got := after.Drawdown(); math.Abs(got-300.0) > 1e-9 {
+		t.Errorf("Drawdown() = %.2f, want 300.00 from the pre-restart peak", got)
+	}
+
+	// Without the restored peak the same equity would be a fresh high
+	fresh := open()
+	fresh.UpdateMarks(map[string]float64{"ETH": 3200.0})
+	if got := fresh.Drawdown(); got != 0 {
+		t.Errorf("Drawdown() without restore = %.2f, want 0", got)
+	}
+}
+
+func TestSubAccountsAccountedSeparately(t *testing.T) {
+	t.Setenv("PREFIX", t.TempDir())
+
+	newBot := func(target, subAccount string, bankroll float64) *Bot {
+		config := createTestConfig()
+		config.TargetAccount = target
+		config.SubAccount = subAccount
+		config.Bankroll = bankroll
+		bot, err := NewBot(config)
+		if err != nil {
+			t.Fatalf("Failed to create bot: %v", err)
+		}
+		return bot
+	}
+	const (
+		subA = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		subB = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	botA := newBot("0x1111111111111111111111111111111111111111", subA, 1000000.0)
+	botB := newBot("0x2222222222222222222222222222222222222222", subB, 500000.0)
+
+	now := time.Now().UnixMilli()
+	if err := botA.process(&Fill{Coin: "BTC", Side: "B", Size: 1.0, Price: 50000.0, Hash: "sub_a", Time: now}); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+	if err := botB.process(&Fill{Coin: "ETH", Side: "A", Size: 10.0, Price: 3000.0, Hash: "sub_b", Time: now}); err != nil {
+		t.Fatalf("process() error = %v", err)
+	}
+
+	for _, tc := range []struct {
+		bot        *Bot
+		subAccount string
+		coin       string
+		other      string
+		bankroll   float64
+	}{
+		{botA, subA, "BTC", "ETH", 1000000.0},
+		{botB, subB, "ETH", "BTC", 500000.0},
+	} {
+		pt := tc.bot.paperTrader
+		if pos := pt.Positions[tc.coin]; pos == nil || pos.Size == 0 {
+			t.Errorf("%s: no %s position", tc.subAccount, tc.coin)
+		} else if pos.Tags["sub_account"] != tc.subAccount {
+			t.Errorf("%s: position tags = %v, want sub_account", tc.subAccount, pos.Tags)
+		}
+		if pos := pt.Positions[tc.other]; pos != nil {
+			t.Errorf("%s: holds the other sub-account's %s", tc.subAccount, tc.other)
+		}
+
+		// Each sub-account saves its own state, with its own bankroll
+		filename := accountsFile(filepath.Join(getDataDir(), "subaccounts", tc.subAccount), time.Now())
+		snapshot, err := LoadLastAccount(filename)
+		if err != nil {
+			t.Fatalf("LoadLastAccount() error = %v", err)
+		}
+		if snapshot.Bankroll != tc.bankroll || snapshot.NumTrades != 1 {
+			t.Errorf("%s: saved bankroll %.2f after %d trades, want %.2f after 1",
+				tc.subAccount, snapshot.Bankroll, snapshot.NumTrades, tc.bankroll)
+		}
+		if _, exists := snapshot.Positions[tc.coin]; !exists || len(snapshot.Positions) != 1 {
+			t.Errorf("%s: saved positions = %v, want only %s", tc.subAccount, snapshot.Positions, tc.coin)
+		}
+	}
+	if _, err := os.Stat(accountsFile(getDataDir(), time.Now())); !os.IsNotExist(err) {
+		t.Errorf("Main account file written (err = %v), want only sub-account files", err)
+	}
+}
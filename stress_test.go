@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"math"
-	"math/rand"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -336,39 +336,77 @@ func TestMassiveVolumeHandling(t *testing.T) {
 }
 
 func TestRandomizedTradingStress(t *testing.T) {
+	pt := runRandomizedStress(t, NewSimOptions(42, time.Now()))
+	numTrades := 100
+	coins := 5
+
+	// Final verification - check at least 95% of trades were processed (allowing for some filtering)
+	if pt.GetTotalTrades() < numTrades-5 {
+		t.Errorf("Random stress test: trades = %d, want at least %d", pt.GetTotalTrades(), numTrades-5)
+	}
+
+	// Should have positions in multiple coins
+	if len(pt.Positions) != coins {
+		t.Errorf("Random stress test: positions = %d, want %d", len(pt.Positions), coins)
+	}
+
+	// Total realized PnL should be reasonable (not zero, not infinite)
+	if math.IsNaN(pt.TotalRealizedPnL) || math.IsInf(pt.TotalRealizedPnL, 0) {
+		t.Errorf("Random stress test: total PnL corrupted: %f", pt.TotalRealizedPnL)
+	}
+}
+
+func TestRandomizedTradingStressIsReproducible(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := runRandomizedStress(t, NewSimOptions(7, start))
+	second := runRandomizedStress(t, NewSimOptions(7, start))
+
+	if first.TotalTrades != second.TotalTrades || first.TotalRealizedPnL != second.TotalRealizedPnL {
+		t.Errorf("Runs differ: %d trades %.6f realized vs %d trades %.6f realized",
+			first.TotalTrades, first.TotalRealizedPnL, second.TotalTrades, second.TotalRealizedPnL)
+	}
+	if !reflect.DeepEqual(first.Positions, second.Positions) {
+		t.Errorf("Same seed produced different positions")
+	}
+	if !reflect.DeepEqual(first.TradeHistory, second.TradeHistory) {
+		t.Errorf("Same seed produced different trade histories")
+	}
+
+	other := runRandomizedStress(t, NewSimOptions(8, start))
+	if other.TotalRealizedPnL == first.TotalRealizedPnL {
+		t.Errorf("Different seeds produced the same run")
+	}
+}
+
+// runRandomizedStress feeds 100 random fills drawn from sim's rand source,
+// timed by sim's clock, through a trader built with sim
+func runRandomizedStress(t *testing.T, sim SimOptions) *PaperTrader {
+	t.Helper()
+
 	// Create paper trader with massive bankroll for stress testing
-	pt := &PaperTrader{
-		Positions:        make(map[string]*Position),
-		StartTime:        time.Now(),
-		TradeHistory:     make([]*PaperTrade, 0),
-		LastTradeTime:    make(map[string]time.Time),
-		PendingFills:     make(map[string][]*Fill),
-		PendingVolume:    make(map[string]float64),
-		LastVolumeUpdate: make(map[string]time.Time),
-		MinTradeInterval: 1 * time.Millisecond,
-		VolumeThreshold:  0.0,
-		VolumeDecayRate:  0.5,
-		Bankroll:         1000000000.0, // $1B for stress test
-		Leverage:         100.0,         // 100x leverage
-		BaseNotional:     10000000.0,    // $10M base trade size for stress test
-	}
-	rand.Seed(42) // Deterministic randomness
+	pt := NewPaperTrader(
+		1000000000.0, // $1B for stress test
+		100.0,        // 100x leverage
+		10000000.0,   // $10M base trade size for stress test
+		sim,
+	)
+	pt.MinTradeInterval = 1 * time.Millisecond
+	pt.VolumeThreshold = 0.0
 
 	coins := []string{"BTC", "ETH", "SOL", "AVAX", "DOT"}
 	sides := []string{"B", "A"}
+	rng := sim.Rand
 
-	numTrades := 100
-
-	for i := 0; i < numTrades; i++ {
-		coin := coins[rand.Intn(len(coins))]
-		side := sides[rand.Intn(len(sides))]
-		size := rand.Float64()*100 + 0.01     // 0.01 to 100
-		price := rand.Float64()*100000 + 1000 // 1000 to 101000
-		pnl := (rand.Float64() - 0.5) * 10000 // -5000 to 5000
+	for i := 0; i < 100; i++ {
+		coin := coins[rng.Intn(len(coins))]
+		side := sides[rng.Intn(len(sides))]
+		size := rng.Float64()*100 + 0.01     // 0.01 to 100
+		price := rng.Float64()*100000 + 1000 // 1000 to 101000
+		pnl := (rng.Float64() - 0.5) * 10000 // -5000 to 5000
 
 		fill := createTestFill(
 			coin, side, size, price,
-			fmt.Sprintf("%.2f", pnl), time.Now().Unix()+int64(i),
+			fmt.Sprintf("%.2f", pnl), sim.Clock.Now().Unix()+int64(i),
 		)
 		fill.Hash = fmt.Sprintf("random_%d", i)
 
@@ -393,23 +431,7 @@ func TestRandomizedTradingStress(t *testing.T) {
 			}
 		}
 	}
-
-	// Force process any pending fills
-
-	// Final verification - check at least 95% of trades were processed (allowing for some filtering)
-	if pt.GetTotalTrades() < numTrades-5 {
-		t.Errorf("Random stress test: trades = %d, want at least %d", pt.GetTotalTrades(), numTrades-5)
-	}
-
-	// Should have positions in multiple coins
-	if len(pt.Positions) != len(coins) {
-		t.Errorf("Random stress test: positions = %d, want %d", len(pt.Positions), len(coins))
-	}
-
-	// Total realized PnL should be reasonable (not zero, not infinite)
-	if math.IsNaN(pt.TotalRealizedPnL) || math.IsInf(pt.TotalRealizedPnL, 0) {
-		t.Errorf("Random stress test: total PnL corrupted: %f", pt.TotalRealizedPnL)
-	}
+	return pt
 }
 
 // Stress test for performance under heavy load
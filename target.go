@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// defaultTargetCatchUp is how far back a newly watched target is caught up
+const defaultTargetCatchUp = 24 * time.Hour
+
+// targetAccount returns the account being copied
+func (b *Bot) targetAccount() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.target == "" {
+		return b.config.TargetAccount
+	}
+	return b.target
+}
+
+// trackTargetPosition records the target's position in fill's coin after
+// the fill. Caller must hold b.mu.
+func (b *Bot) trackTargetPosition(fill *Fill) {
+	if b.targetPosition == nil {
+		b.targetPosition = make(map[string]float64)
+	}
+	size := fill.Size
+	if !fill.Side.IsBuy() {
+		size = -size
+	}
+	b.targetPosition[fill.Coin] = fill.StartPosition + size
+}
+
+// TargetPositions returns the target's positions per coin as seen in its
+// fills, flat coins omitted
+func (b *Bot) TargetPositions() map[string]float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	positions := make(map[string]float64)
+	for coin, size := range b.targetPosition {
+		if size != 0 {
+			positions[coin] = size
+		}
+	}
+	return positions
+}
+
+// SetTarget switches to copying account. Its fills over
+// monitoring.target_catchup_minutes are read first: they establish its
+// positions and track record but are marked processed, never copied. With
+// trading.backfill_positions its open positions are then backfilled.
+func (b *Bot) SetTarget(account string) error {
+	if account == "" {
+		return errors.New("empty target account")
+	}
+	if account == b.targetAccount() {
+		return nil
+	}
+
+	lookback := time.Duration(b.config.Monitoring.TargetCatchUpMinutes) * time.Minute
+	if lookback <= 0 {
+		lookback = defaultTargetCatchUp
+	}
+	endTime := b.clock.Now().UnixMilli()
+	fills, err := b.api().GetUserFillsByTime(account, endTime-lookback.Milliseconds(), endTime)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time < fills[j].Time })
+
+	b.mu.Lock()
+	b.target = account
+	b.targetPosition = nil
+	b.targetPnL = nil
+	b.targetResults = nil
+	if b.processedFills == nil {
+		b.processedFills = make(map[string]int64)
+	}
+	for _, fill := range fills {
+		key := fill.DedupKey()
+		if err := fill.Normalize(); err != nil {
+			continue
+		}
+		fill.applyAlias(b.config.Trading.CoinAliases)
+		b.processedFills[key] = fill.Time
+		b.trackTargetPosition(fill)
+		b.targetInDrawdown(fill) // records its closed PnL
+		b.targetWinRateLow(fill) // records its result
+	}
+	b.mu.Unlock()
+
+	pt := b.paperTrader
+	pt.mu.Lock()
+	if pt.Tags != nil {
+		pt.Tags["target"] = account
+	}
+	pt.mu.Unlock()
+
+	log.Printf("bot: watching %s, caught up on %d fills", account, len(fills))
+	if b.config.Trading.BackfillPositions && b.observer == nil {
+		if err := b.backfillPositions(); err != nil {
+			log.Printf("Error backfilling target positions: %v", err)
+		}
+	}
+	return nil
+}
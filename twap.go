@@ -0,0 +1,153 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// twapExecution is a copied signal being worked in equal child slices
+// spread across the TWAP window. Sizes are signed like position sizes.
+type twapExecution struct {
+	size      float64 // total signed size to execute
+	slices    int
+	interval  time.Duration
+	next      time.Time // when the next slice is due
+	prices    []float64 // execution price of each slice so far
+	closedPnL float64
+	lastTime  int64
+	fills     []*Fill
+}
+
+// twapChild is an executed slice waiting to be placed on the exchange
+type twapChild struct {
+	coin  string
+	size  float64
+	price float64
+}
+
+// TWAPActive reports whether a sliced copy is still executing for coin
+func (pt *PaperTrader) TWAPActive(coin string) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.twaps[coin] != nil
+}
+
+// startTWAP schedules a copied trade as TWAPSlices child executions. The
+// first slice executes immediately at the signal price, the rest on the
+// first mark at or after each slice's due time. A signal arriving while
+// a previous one is still working completes the old one at its price.
+func (pt *PaperTrader) startTWAP(coin string, size, price, closedPnL float64, fills []*Fill) {
+	if pt.twaps == nil {
+		pt.twaps = make(map[string]*twapExecution)
+	}
+	if pt.twaps[coin] != nil {
+		pt.finishTWAP(coin, price)
+	}
+
+	exec := &twapExecution{
+		size:      size,
+		slices:    pt.TWAPSlices,
+		interval:  pt.TWAPWindow / time.Duration(pt.TWAPSlices),
+		next:      pt.now(),
+		closedPnL: closedPnL,
+		fills:     fills,
+	}
+	if len(fills) > 0 {
+		exec.lastTime = fills[len(fills)-1].Time
+	}
+	pt.twaps[coin] = exec
+
+	log.Printf("bot: twap %s %.4f in %d slices over %v",
+		coin, size, exec.slices, pt.TWAPWindow)
+	pt.advanceTWAP(coin, price)
+}
+
+// advanceTWAP executes the next slice for coin if it is due at price
+func (pt *PaperTrader) advanceTWAP(coin string, price float64) {
+	exec := pt.twaps[coin]
+	if exec == nil || pt.now().Before(exec.next) {
+		return
+	}
+
+	pt.executeSlice(coin, exec, price)
+	exec.next = exec.next.Add(exec.interval)
+	if len(exec.prices) == exec.slices {
+		pt.completeTWAP(coin, exec, price)
+	}
+}
+
+// finishTWAP executes all remaining slices for coin at price
+func (pt *PaperTrader) finishTWAP(coin string, price float64) {
+	exec := pt.twaps[coin]
+	if exec == nil {
+		return
+	}
+	for len(exec.prices) < exec.slices {
+		pt.executeSlice(coin, exec, price)
+	}
+	pt.completeTWAP(coin, exec, price)
+}
+
+func (pt *PaperTrader) executeSlice(coin string, exec *twapExecution, price float64) {
+	child := exec.size / float64(exec.slices)
+	exec.prices = append(exec.prices, pt.impactPrice(price, child))
+	if pt.QueueTWAPChildren {
+		pt.twapChildren = append(pt.twapChildren, twapChild{coin: coin, size: child, price: price})
+	}
+}
+
+// TakeTWAPChildren returns and clears the slices executed since the last
+// call, so orders are sent without pt.mu held
+func (pt *PaperTrader) TakeTWAPChildren() []twapChild {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	children := pt.twapChildren
+	pt.twapChildren = nil
+	return children
+}
+
+// completeTWAP books the whole sliced trade at the average slice price
+func (pt *PaperTrader) completeTWAP(coin string, exec *twapExecution, markPrice float64) {
+	delete(pt.twaps, coin)
+
+	total := 0.0
+	for _, p := range exec.prices {
+		total += p
+	}
+	avgPrice := total / float64(len(exec.prices))
+
+	side := SideBuy
+	if exec.size < 0 {
+		side = SideSell
+	}
+	log.Printf("bot: twap %s complete, %d slices avg %.4f",
+		coin, len(exec.prices), avgPrice)
+	pt.bookTrade(coin, exec.size, avgPrice, markPrice, exec.closedPnL,
+		side, exec.lastTime, exec.fills)
+}
+
+// placeTWAPChildren places the slices the trader queued, after it has
+// released pt.mu
+func (b *Bot) placeTWAPChildren() {
+	if b.orders == nil {
+		return
+	}
+	for _, child := range b.paperTrader.TakeTWAPChildren() {
+		b.placeChildOrder(child.coin, child.size, child.price)
+	}
+}
+
+// placeChildOrder routes a TWAP slice to the exchange in real mode. The
+// child is tracked as working so later syncs net against it.
+func (b *Bot) placeChildOrder(coin string, size, price float64) {
+	if b.orders == nil || math.Abs(size) < 1e-12 {
+		return
+	}
+
+	b.orders.Add(coin, size)
+	if err := b.placeOrder(coin, size, price); err != nil {
+		log.Printf("Error placing twap slice for %s: %v", coin, err)
+		b.orders.Add(coin, -size)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTWAPAveragesMarkPath(t *testing.T) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.TWAPSlices = 4
+	pt.TWAPWindow = 4 * time.Minute
+
+	pt.QueueTWAPChildren = true
+	var children []float64
+	take := func() {
+		for _, child := range pt.TakeTWAPChildren() {
+			children = append(children, child.size)
+		}
+	}
+
+	fill := createTestFill("BTC", "B", 1.0, 50000.0, "0.0", clock.Now().Unix())
+	pt.ProcessFill(fill)
+	take()
+	if len(pt.TradeHistory) != 0 {
+		t.Fatalf("Trade booked before the TWAP window completed")
+	}
+	if !pt.TWAPActive("BTC") {
+		t.Fatalf("TWAP should be active after the signal")
+	}
+
+	// Marks between slice due times don't execute extra slices
+	pt.UpdateMarks(map[string]float64{"BTC": 99999.0})
+	take()
+
+	for _, mark := range []float64{50400.0, 50800.0, 49200.0} {
+		clock.Advance(time.Minute)
+		pt.UpdateMarks(map[string]float64{"BTC": mark})
+		take()
+	}
+
+	if len(pt.TradeHistory) != 1 {
+		t.Fatalf("Trades = %d, want 1 after the last slice", len(pt.TradeHistory))
+	}
+	if pt.TWAPActive("BTC") {
+		t.Errorf("TWAP still active after the last slice")
+	}
+
+	want := (50000.0 + 50400.0 + 50800.0 + 49200.0) / 4
+	trade := pt.TradeHistory[0]
+	if math.Abs(trade.Price-want) > 1e-6 {
+		t.Errorf("TWAP fill price = %.4f, want %.4f", trade.Price, want)
+	}
+	if pos := pt.Positions["BTC"]; math.Abs(pos.Size-trade.Size) > 1e-9 || pos.LastPrice != 49200.0 {
+		t.Errorf("Position = %.4f @ last %.2f, want %.4f @ last 49200.00",
+			pos.Size, pos.LastPrice, trade.Size)
+	}
+
+	if len(children) != 4 {
+		t.Fatalf("Child orders = %d, want 4", len(children))
+	}
+	sum := 0.0
+	for _, size := range children {
+		sum += size
+	}
+	if math.Abs(sum-trade.Size) > 1e-9 {
+		t.Errorf("Child sizes sum to %.6f, want %.6f", sum, trade.Size)
+	}
+}
+
+func TestTWAPChildrenPlacedWithoutTraderLock(t *testing.T) {
+	var mu sync.Mutex
+	var placed, locked int
+	var bot *Bot
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		switch payload["type"] {
+		case "allMids":
+			w.Write([]byte(`{"BTC":"50500"}`))
+			return
+		case "order":
+			// A stuck exchange call must not hold up the trader
+			free := bot.paperTrader.mu.TryLock()
+			if free {
+				bot.paperTrader.mu.Unlock()
+			}
+			mu.Lock()
+			placed++
+			if !free {
+				locked++
+			}
+			mu.Unlock()
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.PaperTradingOnly = false
+	config.CopyThreshold = 100.0
+	var err error
+	bot, err = NewBot(config)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.client.baseURL = server.URL
+	bot.client.assets = testAssets
+
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	pt := NewTestPaperTrader()
+	pt.Clock = clock
+	pt.TWAPSlices = 2
+	pt.TWAPWindow = 2 * time.Minute
+	pt.QueueTWAPChildren = true
+	bot.paperTrader = pt
+
+	bot.process(&Fill{Coin: "BTC", Side: SideBuy, Size: 1.0, Price: 50000.0,
+		Hash: "twap_lock", Time: clock.Now().UnixMilli()})
+	clock.Advance(time.Minute)
+	bot.refreshMarks()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if placed != 2 || locked != 0 {
+		t.Errorf("Placed %d children, %d under pt.mu; want 2, none locked", placed, locked)
+	}
+	if children := pt.TakeTWAPChildren(); len(children) != 0 {
+		t.Errorf("%d children left queued after placement", len(children))
+	}
+}